@@ -17,6 +17,7 @@ package v1beta1
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -31,7 +32,9 @@ type StepClusterIssuerSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	// URL is the base URL for the step certificates instance.
+	// URL is the base URL for the step certificates instance. It is required
+	// unless Embedded is set.
+	// +optional
 	URL string `json:"url"`
 
 	// Provisioner contains the step certificates provisioner configuration.
@@ -39,8 +42,77 @@ type StepClusterIssuerSpec struct {
 
 	// CABundle is a base64 encoded TLS certificate used to verify connections
 	// to the step certificates server. If not set the system root certificates
-	// are used to validate the TLS connection.
+	// are used to validate the TLS connection. It is required unless Embedded
+	// is set.
+	// +optional
 	CABundle []byte `json:"caBundle"`
+
+	// CASource selects the certificates written to the ca field of the
+	// CertificateRequests, and to the ca.crt key of the certificate Secrets:
+	// the root that issued the certificate (Root), all the roots returned by
+	// the /federation endpoint (Federated), CABundle (TLSBundle), or nothing
+	// (None). The roots are read from the CA once per issuer. Defaults to
+	// Root.
+	// +optional
+	CASource CASource `json:"caSource,omitempty"`
+
+	// TemplateData configures the information about the CertificateRequests
+	// sent to step certificates as template data. It is available to the
+	// provisioner templates as .Insecure.User.
+	// +optional
+	TemplateData *StepClusterTemplateData `json:"templateData,omitempty"`
+
+	// Renewal enables the renewal of certificates using the previous
+	// certificate, stored in the Secret of the Certificate, to authenticate to
+	// the /renew or /rekey endpoints instead of a one-time token. If the
+	// renewal fails, the certificate is signed with the provisioner.
+	// +optional
+	Renewal *StepClusterRenewal `json:"renewal,omitempty"`
+
+	// Revocation enables the revocation of the certificates issued by this
+	// issuer when their Certificate or Secret is deleted, or when they are
+	// replaced by a renewed certificate. Only JWK and X5C provisioners can
+	// revoke certificates.
+	// +optional
+	Revocation *StepClusterRevocation `json:"revocation,omitempty"`
+
+	// Embedded runs a step certificates authority in the controller process
+	// instead of connecting to URL. The certificates are signed through the
+	// same API and provisioner checks as a step certificates server, by a JWK
+	// provisioner generated by the controller and named after
+	// spec.provisioner.name. URL, CABundle and the provisioner credentials
+	// must not be set. For development clusters only, the database of the
+	// authority and the provisioner key are lost every time the issuer is
+	// reconciled.
+	// +optional
+	Embedded *StepClusterEmbeddedAuthority `json:"embedded,omitempty"`
+
+	// Policy restricts the CertificateRequests signed by this issuer. The
+	// requests that violate it are marked as failed before they are sent to
+	// the CA.
+	// +optional
+	Policy *StepClusterPolicy `json:"policy,omitempty"`
+
+	// Validity configures the duration of the certificates. The requests are
+	// sent to the CA with the effective duration, and it is recorded in an
+	// Event on the CertificateRequest.
+	// +optional
+	Validity *StepClusterValidity `json:"validity,omitempty"`
+
+	// Verification configures the checks of the certificates issued by the
+	// CA before they are written to the CertificateRequest.
+	// +optional
+	Verification *StepClusterVerification `json:"verification,omitempty"`
+
+	// SubjectStrategy selects the subject of the tokens sent to the CA, used
+	// by the default templates as the common name of the certificates.
+	// +optional
+	SubjectStrategy *StepClusterSubjectStrategy `json:"subjectStrategy,omitempty"`
+
+	// SubjectAltNames configures the normalization of the SANs sent to the
+	// CA in the tokens.
+	// +optional
+	SubjectAltNames *StepClusterSubjectAltNames `json:"subjectAltNames,omitempty"`
 }
 
 // StepClusterIssuerStatus defines the observed state of StepClusterIssuer
@@ -50,6 +122,16 @@ type StepClusterIssuerStatus struct {
 
 	// +optional
 	Conditions []StepClusterIssuerCondition `json:"conditions,omitempty"`
+
+	// Provisioner contains the claims of the provisioner, read from the CA
+	// when the StepClusterIssuer is reconciled.
+	// +optional
+	Provisioner *StepClusterProvisionerStatus `json:"provisioner,omitempty"`
+
+	// PasswordLastLoadedTime is the last time the provisioner password was
+	// loaded from its source, a Secret, an environment variable or a file.
+	// +optional
+	PasswordLastLoadedTime *metav1.Time `json:"passwordLastLoadedTime,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -93,12 +175,15 @@ type StepClusterProvisioner struct {
 	// Names is the name of the JWK provisioner.
 	Name string `json:"name"`
 
-	// KeyID is the kid property of the JWK provisioner.
-	KeyID string `json:"kid"`
+	// KeyID is the kid property of the JWK provisioner. It is required unless
+	// another provisioner type, like OIDC or X5C, is set.
+	// +optional
+	KeyID string `json:"kid,omitempty"`
 
 	// PasswordRef is a reference to a Secret containing the provisioner
-	// password used to decrypt the provisioner private key. Exactly one of
-	// PasswordRef, PasswordEnv, or PasswordFile must be set.
+	// password used to decrypt the provisioner private key. Unless another
+	// provisioner type is set, or KeyRef contains an unencrypted key, exactly
+	// one of PasswordRef, PasswordEnv, or PasswordFile must be set.
 	// +optional
 	PasswordRef StepClusterIssuerSecretKeySelector `json:"passwordRef,omitempty"`
 
@@ -119,6 +204,200 @@ type StepClusterProvisioner struct {
 	// PasswordFile must be set.
 	// +optional
 	PasswordFile string `json:"passwordFile,omitempty"`
+
+	// KeyRef is a reference to a Secret containing the private key of the JWK
+	// provisioner, as an encrypted JWK, a plain JWK, or a PEM encoded key. When
+	// set, the key is not downloaded from the CA and one-time tokens are
+	// created offline. The password sources are only required if the key is
+	// encrypted, and KeyID must match the key.
+	// +optional
+	KeyRef *StepClusterIssuerSecretKeySelector `json:"keyRef,omitempty"`
+
+	// PKCS11 configures the JWK provisioner to sign one-time tokens with a
+	// private key held in a PKCS#11 token, so the key never leaves the token.
+	// KeyID is required and must match the key. The password sources and
+	// KeyRef must not be set. The controller must be built with cgo.
+	// +optional
+	PKCS11 *StepClusterPKCS11Key `json:"pkcs11,omitempty"`
+
+	// OIDC configures an OIDC provisioner instead of a JWK one. When set, the
+	// controller obtains an ID token from the identity provider using the
+	// client credentials grant and uses it as the one-time token to sign
+	// certificates. KeyID and the password sources must not be set.
+	// +optional
+	OIDC *StepClusterOIDCProvisioner `json:"oidc,omitempty"`
+
+	// X5C configures an X5C provisioner instead of a JWK one. When set, the
+	// one-time tokens are signed with the private key in the referenced Secret
+	// and include its certificate chain in the x5c header. KeyID and the
+	// password sources must not be set.
+	// +optional
+	X5C *StepClusterX5CProvisioner `json:"x5c,omitempty"`
+
+	// K8sSA configures a Kubernetes service account (K8sSA) provisioner
+	// instead of a JWK one. When set, the controller uses the token of a
+	// ServiceAccount, written by Kubernetes in a Secret of type
+	// kubernetes.io/service-account-token, as the one-time token. KeyID and
+	// the password sources must not be set.
+	// +optional
+	K8sSA *StepClusterK8sSAProvisioner `json:"k8sSA,omitempty"`
+
+	// ACME configures an ACME provisioner instead of a JWK one. When set,
+	// certificates are requested with ACME orders and the controller solves
+	// the http-01 challenges, which requires the controller to run with
+	// --acme-http01-bind-address and to be reachable on port 80 of every
+	// requested name. The ACME directory is derived from the URL and the
+	// provisioner name. KeyID and the password sources must not be set.
+	// +optional
+	ACME *StepClusterACMEProvisioner `json:"acme,omitempty"`
+
+	// TokenService configures an external service that creates the one-time
+	// tokens. When set, the controller sends the subject and SANs of each
+	// request to the service and uses the returned token to sign the
+	// certificate, so no provisioner key is stored in the cluster. KeyID and
+	// the password sources must not be set.
+	// +optional
+	TokenService *StepClusterTokenService `json:"tokenService,omitempty"`
+}
+
+// StepClusterPKCS11Key contains the location of a JWK provisioner key stored
+// in a PKCS#11 token.
+type StepClusterPKCS11Key struct {
+	// ModulePath is the path of the PKCS#11 module in the controller's
+	// filesystem, like /usr/lib/softhsm/libsofthsm2.so.
+	ModulePath string `json:"modulePath"`
+
+	// TokenLabel is the label of the token containing the key. Exactly one of
+	// TokenLabel or SlotID must be set.
+	// +optional
+	TokenLabel string `json:"tokenLabel,omitempty"`
+
+	// SlotID is the slot of the token containing the key. Exactly one of
+	// TokenLabel or SlotID must be set.
+	// +optional
+	SlotID *int `json:"slotID,omitempty"`
+
+	// KeyLabel is the label (CKA_LABEL) of the private key in the token.
+	KeyLabel string `json:"keyLabel"`
+
+	// PINRef is a reference to a Secret containing the user PIN of the token.
+	PINRef StepClusterIssuerSecretKeySelector `json:"pinRef"`
+}
+
+// StepClusterACMEProvisioner contains the configuration used to request certificates
+// from an ACME provisioner.
+type StepClusterACMEProvisioner struct {
+	// AccountKeyRef is a reference to a Secret containing the PEM encoded
+	// private key of the ACME account. The account is registered if it does
+	// not exist yet.
+	AccountKeyRef StepClusterIssuerSecretKeySelector `json:"accountKeyRef"`
+
+	// Email is an optional contact address for the ACME account.
+	// +optional
+	Email string `json:"email,omitempty"`
+
+	// ExternalAccountBinding binds the ACME account to an external account.
+	// It is required if the ACME provisioner requires EAB.
+	// +optional
+	ExternalAccountBinding *StepClusterACMEExternalAccountBinding `json:"externalAccountBinding,omitempty"`
+}
+
+// StepClusterACMEExternalAccountBinding contains the credentials used to bind an ACME
+// account to an external account.
+type StepClusterACMEExternalAccountBinding struct {
+	// KeyID is the key identifier provided by the CA.
+	KeyID string `json:"keyID"`
+
+	// KeySecretRef is a reference to a Secret containing the base64url encoded
+	// HMAC key provided by the CA.
+	KeySecretRef StepClusterIssuerSecretKeySelector `json:"keySecretRef"`
+}
+
+// StepClusterK8sSAProvisioner selects the ServiceAccount whose tokens are used with a
+// K8sSA provisioner. The step certificates K8sSA provisioner only accepts the
+// tokens of the Secrets of type kubernetes.io/service-account-token, so the
+// ServiceAccount must have one.
+type StepClusterK8sSAProvisioner struct {
+	// ServiceAccountName is the name of the ServiceAccount whose token is
+	// used.
+	ServiceAccountName string `json:"serviceAccountName"`
+
+	// ServiceAccountNamespace is the namespace of the ServiceAccount. It is
+	// required unless UseRequestNamespace is set.
+	// +optional
+	ServiceAccountNamespace string `json:"serviceAccountNamespace,omitempty"`
+
+	// UseRequestNamespace uses the token of the ServiceAccount with the
+	// given name in the namespace of each CertificateRequest, so every
+	// namespace presents its own identity to the CA.
+	// +optional
+	UseRequestNamespace bool `json:"useRequestNamespace,omitempty"`
+}
+
+// StepClusterTokenService contains the configuration used to request one-time tokens
+// from an external token service.
+type StepClusterTokenService struct {
+	// URL is the HTTPS endpoint of the token service. The controller sends a
+	// POST request with a JSON body with the provisioner, audience, subject,
+	// sans and namespace of the token, and expects a JSON response with the
+	// token in the "token" property.
+	URL string `json:"url"`
+
+	// CABundle is a base64 encoded PEM bundle used to verify the TLS
+	// certificate of the token service. The system roots are used if it is
+	// not set.
+	// +optional
+	CABundle []byte `json:"caBundle,omitempty"`
+
+	// BearerTokenRef is a reference to a Secret containing a bearer token sent
+	// in the Authorization header. At least one of BearerTokenRef or
+	// ClientCertSecretName must be set.
+	// +optional
+	BearerTokenRef *StepClusterIssuerSecretKeySelector `json:"bearerTokenRef,omitempty"`
+
+	// ClientCertSecretName is the name of a Secret with the client
+	// certificate and key, in the tls.crt and tls.key keys, used to
+	// authenticate to the token service with mTLS.
+	// +optional
+	ClientCertSecretName string `json:"clientCertSecretName,omitempty"`
+
+	// ClientCertSecretNamespace is the namespace of the client certificate
+	// Secret.
+	// +optional
+	ClientCertSecretNamespace string `json:"clientCertSecretNamespace,omitempty"`
+}
+
+// StepClusterX5CProvisioner contains the configuration used to create tokens for an
+// X5C provisioner.
+type StepClusterX5CProvisioner struct {
+	// SecretName is the name of a Secret with the certificate chain in the
+	// tls.crt key and its private key in the tls.key key, like the Secrets of
+	// type kubernetes.io/tls. The chain must be trusted by the step
+	// certificates X5C provisioner.
+	SecretName string `json:"secretName"`
+
+	// SecretNamespace is the namespace of the Secret.
+	SecretNamespace string `json:"secretNamespace"`
+}
+
+// StepClusterOIDCProvisioner contains the configuration used to get ID tokens for an
+// OIDC provisioner.
+type StepClusterOIDCProvisioner struct {
+	// IssuerURL is the URL of the OpenID Connect issuer. Its discovery document
+	// is used to find the token endpoint.
+	IssuerURL string `json:"issuerURL"`
+
+	// ClientID is the OAuth client ID. It must match the client ID configured
+	// in the step certificates OIDC provisioner.
+	ClientID string `json:"clientID"`
+
+	// ClientSecretRef is a reference to a Secret containing the OAuth client
+	// secret.
+	ClientSecretRef StepClusterIssuerSecretKeySelector `json:"clientSecretRef"`
+
+	// Scopes is a list of additional scopes to request along with openid.
+	// +optional
+	Scopes []string `json:"scopes,omitempty"`
 }
 
 // StepClusterIssuerCondition contains condition information for the step issuer.
@@ -145,3 +424,240 @@ type StepClusterIssuerCondition struct {
 	// +optional
 	Message string `json:"message,omitempty"`
 }
+
+// StepClusterTemplateData configures the CertificateRequest metadata sent as template
+// data with the sign requests.
+type StepClusterTemplateData struct {
+	// RequestMetadata includes the namespace, name, owning Certificate,
+	// username and groups of the CertificateRequest as the template variables
+	// namespace, name, certificate, username and groups.
+	// +optional
+	RequestMetadata bool `json:"requestMetadata,omitempty"`
+
+	// Annotations is the list of CertificateRequest annotations included in
+	// the annotations template variable. Other annotations are not sent.
+	// +optional
+	Annotations []string `json:"annotations,omitempty"`
+}
+
+// StepClusterRenewal configures the renewal of certificates with the previous
+// certificate.
+type StepClusterRenewal struct {
+	// Rekey renews the certificates whose request has a new private key
+	// using the /rekey endpoint. If false, only the requests with the public
+	// key of the previous certificate are renewed.
+	// +optional
+	Rekey bool `json:"rekey,omitempty"`
+}
+
+// StepClusterRevocation configures the revocation of the issued certificates.
+type StepClusterRevocation struct {
+	// ReasonCode is the RFC 5280 reason code sent with the revocation
+	// requests. If not set, certificates are revoked with
+	// cessationOfOperation (5) when deleted, and with superseded (4) when
+	// renewed.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=10
+	// +optional
+	ReasonCode *int `json:"reasonCode,omitempty"`
+
+	// Reason is a description of the revocation reason.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// StepClusterEmbeddedAuthority configures a step certificates authority running in
+// the controller process.
+type StepClusterEmbeddedAuthority struct {
+	// SecretName is the name of a Secret with the root certificate in the
+	// ca.crt key, the intermediate certificate in the tls.crt key and its
+	// unencrypted private key in the tls.key key, like the Secrets of
+	// cert-manager CA Certificates.
+	SecretName string `json:"secretName"`
+
+	// SecretNamespace is the namespace of the Secret.
+	SecretNamespace string `json:"secretNamespace"`
+
+	// Options are the options of the generated provisioner, in the format of
+	// the options of the provisioners in the ca.json file, like X.509
+	// templates and policies.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	Options *runtime.RawExtension `json:"options,omitempty"`
+
+	// Claims are the claims of the generated provisioner, in the format of
+	// the claims of the provisioners in the ca.json file, like the minimum,
+	// maximum and default durations of the certificates.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	Claims *runtime.RawExtension `json:"claims,omitempty"`
+}
+
+// StepClusterPolicy contains the rules that the CertificateRequests must satisfy. The
+// string rules can contain {{namespace}}, replaced by the namespace of the
+// CertificateRequest.
+type StepClusterPolicy struct {
+	// DNSNames are the allowed DNS names. A * as the leftmost label matches
+	// exactly one label, like *.{{namespace}}.svc.cluster.local.
+	// +optional
+	DNSNames []string `json:"dnsNames,omitempty"`
+
+	// IPRanges are the allowed IP addresses, in CIDR notation.
+	// +optional
+	IPRanges []string `json:"ipRanges,omitempty"`
+
+	// URIPrefixes are the allowed prefixes of the URIs, like
+	// spiffe://cluster.local/ns/{{namespace}}/.
+	// +optional
+	URIPrefixes []string `json:"uriPrefixes,omitempty"`
+
+	// EmailDomains are the allowed domains of the email addresses, with the
+	// same patterns as DNSNames.
+	// +optional
+	EmailDomains []string `json:"emailDomains,omitempty"`
+
+	// KeyAlgorithms are the allowed public key algorithms and their minimum
+	// sizes. Any key is allowed if empty.
+	// +optional
+	KeyAlgorithms []StepClusterPolicyKeyAlgorithm `json:"keyAlgorithms,omitempty"`
+
+	// MaxDuration is the maximum duration of the certificates. The requests
+	// without a duration are checked against the default duration of the
+	// provisioner, and rejected if it is unknown.
+	// +optional
+	MaxDuration *metav1.Duration `json:"maxDuration,omitempty"`
+}
+
+// StepClusterPolicyKeyAlgorithm is a public key algorithm allowed by a policy.
+type StepClusterPolicyKeyAlgorithm struct {
+	// Algorithm is the public key algorithm.
+	Algorithm KeyAlgorithm `json:"algorithm"`
+
+	// MinSize is the minimum size in bits of the RSA modulus or the ECDSA
+	// curve. It is ignored for Ed25519 keys.
+	// +optional
+	MinSize int `json:"minSize,omitempty"`
+}
+
+// StepClusterValidity configures the duration of the certificates of an issuer.
+type StepClusterValidity struct {
+	// DefaultDuration is the duration of the certificates requested without
+	// one. If not set, the CA uses the default duration of the provisioner.
+	// +optional
+	DefaultDuration *metav1.Duration `json:"defaultDuration,omitempty"`
+
+	// MinDuration is the minimum duration of the certificates.
+	// +optional
+	MinDuration *metav1.Duration `json:"minDuration,omitempty"`
+
+	// MaxDuration is the maximum duration of the certificates.
+	// +optional
+	MaxDuration *metav1.Duration `json:"maxDuration,omitempty"`
+
+	// Mode selects what happens to the requests with a duration outside of
+	// MinDuration and MaxDuration: they are signed with the closest allowed
+	// duration (Clamp), or marked as failed (Reject). Defaults to Clamp.
+	// +optional
+	Mode ValidityMode `json:"mode,omitempty"`
+
+	// Backdate sets the start of the validity of the certificates this long
+	// before they are signed, to tolerate the clock skew of the workloads.
+	// The duration of the certificates is counted from the start of their
+	// validity. Not supported by ACME provisioners.
+	// +optional
+	Backdate *metav1.Duration `json:"backdate,omitempty"`
+}
+
+// StepClusterProvisionerStatus contains the claims of a provisioner, as returned by
+// the /provisioners endpoint of the CA. The claims that are not set in the
+// provisioner use the global claims of the CA, which are not published, and
+// are left empty.
+type StepClusterProvisionerStatus struct {
+	// Name of the provisioner.
+	Name string `json:"name"`
+
+	// Type of the provisioner, e.g. JWK or OIDC.
+	Type string `json:"type"`
+
+	// MinTLSCertDuration is the minimum duration of the certificates.
+	// +optional
+	MinTLSCertDuration *metav1.Duration `json:"minTLSCertDuration,omitempty"`
+
+	// MaxTLSCertDuration is the maximum duration of the certificates.
+	// +optional
+	MaxTLSCertDuration *metav1.Duration `json:"maxTLSCertDuration,omitempty"`
+
+	// DefaultTLSCertDuration is the duration of the certificates requested
+	// without one.
+	// +optional
+	DefaultTLSCertDuration *metav1.Duration `json:"defaultTLSCertDuration,omitempty"`
+
+	// DisableRenewal is true if the provisioner does not renew certificates.
+	// +optional
+	DisableRenewal *bool `json:"disableRenewal,omitempty"`
+
+	// AllowRenewalAfterExpiry is true if the provisioner renews expired
+	// certificates.
+	// +optional
+	AllowRenewalAfterExpiry *bool `json:"allowRenewalAfterExpiry,omitempty"`
+
+	// Template is true if the provisioner has an X.509 certificate template.
+	// +optional
+	Template bool `json:"template,omitempty"`
+
+	// LastUpdateTime is the time the claims were read from the CA.
+	// +optional
+	LastUpdateTime *metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// StepClusterVerification configures the checks of the issued certificates. The
+// public key of the certificate must match the request, its chain must verify
+// to the roots of the issuer, and it must not be expired.
+type StepClusterVerification struct {
+	// SubjectAltNames selects how the SANs of the certificate are compared
+	// with the request: the certificate must have all the requested SANs
+	// (Superset), or exactly the requested SANs (Exact). Defaults to
+	// Superset.
+	// +optional
+	SubjectAltNames SANMatch `json:"subjectAltNames,omitempty"`
+}
+
+// StepClusterSubjectStrategy selects the subject of the tokens sent to the CA.
+type StepClusterSubjectStrategy struct {
+	// Type is the strategy used to select the subject: the common name, or
+	// the first SAN that is not localhost or 127.0.0.1 if it is empty
+	// (Default), the common name (CommonName), the first DNS name
+	// (FirstDNSName), the first URI (FirstURI), or the result of Template
+	// (Template). Defaults to Default.
+	// +optional
+	Type SubjectStrategyType `json:"type,omitempty"`
+
+	// Template is a Go template that renders the subject, required by the
+	// Template type. It can use the fields of the certificate request
+	// (.CommonName, .DNSNames, .IPAddresses, .URIs, .EmailAddresses and
+	// .SANs), the metadata of the CertificateRequest (.Namespace, .Name,
+	// .Certificate and .Annotations), and the functions of the step
+	// certificates templates.
+	// +optional
+	Template string `json:"template,omitempty"`
+
+	// RejectEmpty marks the requests as failed if the strategy does not
+	// select a subject. By default, step-issuer-certificate is used.
+	// +optional
+	RejectEmpty bool `json:"rejectEmpty,omitempty"`
+}
+
+// StepClusterSubjectAltNames configures the normalization of the SANs of the
+// requests. The normalized SANs are sent to the CA in the tokens, and the
+// issued certificates are verified against them.
+type StepClusterSubjectAltNames struct {
+	// Mode selects how the SANs are normalized: Normalize lowercases the DNS
+	// names and the domains of the email addresses, converts them to
+	// punycode, and removes the loopback names and addresses and the
+	// duplicates; Strip only removes the loopback names and addresses and
+	// the duplicates; Reject marks the requests that Normalize would change
+	// as failed. The CA requires the SANs of the JWK and X5C tokens to match
+	// the request, so Normalize and Strip are only supported with a token
+	// service.
+	Mode SANMode `json:"mode"`
+}
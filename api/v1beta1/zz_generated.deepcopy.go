@@ -20,9 +20,109 @@ limitations under the License.
 package v1beta1
 
 import (
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepACMEExternalAccountBinding) DeepCopyInto(out *StepACMEExternalAccountBinding) {
+	*out = *in
+	out.KeySecretRef = in.KeySecretRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepACMEExternalAccountBinding.
+func (in *StepACMEExternalAccountBinding) DeepCopy() *StepACMEExternalAccountBinding {
+	if in == nil {
+		return nil
+	}
+	out := new(StepACMEExternalAccountBinding)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepACMEProvisioner) DeepCopyInto(out *StepACMEProvisioner) {
+	*out = *in
+	out.AccountKeyRef = in.AccountKeyRef
+	if in.ExternalAccountBinding != nil {
+		in, out := &in.ExternalAccountBinding, &out.ExternalAccountBinding
+		*out = new(StepACMEExternalAccountBinding)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepACMEProvisioner.
+func (in *StepACMEProvisioner) DeepCopy() *StepACMEProvisioner {
+	if in == nil {
+		return nil
+	}
+	out := new(StepACMEProvisioner)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepClusterACMEExternalAccountBinding) DeepCopyInto(out *StepClusterACMEExternalAccountBinding) {
+	*out = *in
+	out.KeySecretRef = in.KeySecretRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepClusterACMEExternalAccountBinding.
+func (in *StepClusterACMEExternalAccountBinding) DeepCopy() *StepClusterACMEExternalAccountBinding {
+	if in == nil {
+		return nil
+	}
+	out := new(StepClusterACMEExternalAccountBinding)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepClusterACMEProvisioner) DeepCopyInto(out *StepClusterACMEProvisioner) {
+	*out = *in
+	out.AccountKeyRef = in.AccountKeyRef
+	if in.ExternalAccountBinding != nil {
+		in, out := &in.ExternalAccountBinding, &out.ExternalAccountBinding
+		*out = new(StepClusterACMEExternalAccountBinding)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepClusterACMEProvisioner.
+func (in *StepClusterACMEProvisioner) DeepCopy() *StepClusterACMEProvisioner {
+	if in == nil {
+		return nil
+	}
+	out := new(StepClusterACMEProvisioner)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepClusterEmbeddedAuthority) DeepCopyInto(out *StepClusterEmbeddedAuthority) {
+	*out = *in
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Claims != nil {
+		in, out := &in.Claims, &out.Claims
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepClusterEmbeddedAuthority.
+func (in *StepClusterEmbeddedAuthority) DeepCopy() *StepClusterEmbeddedAuthority {
+	if in == nil {
+		return nil
+	}
+	out := new(StepClusterEmbeddedAuthority)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StepClusterIssuer) DeepCopyInto(out *StepClusterIssuer) {
 	*out = *in
@@ -119,12 +219,57 @@ func (in *StepClusterIssuerSecretKeySelector) DeepCopy() *StepClusterIssuerSecre
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StepClusterIssuerSpec) DeepCopyInto(out *StepClusterIssuerSpec) {
 	*out = *in
-	out.Provisioner = in.Provisioner
+	in.Provisioner.DeepCopyInto(&out.Provisioner)
 	if in.CABundle != nil {
 		in, out := &in.CABundle, &out.CABundle
 		*out = make([]byte, len(*in))
 		copy(*out, *in)
 	}
+	if in.TemplateData != nil {
+		in, out := &in.TemplateData, &out.TemplateData
+		*out = new(StepClusterTemplateData)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Renewal != nil {
+		in, out := &in.Renewal, &out.Renewal
+		*out = new(StepClusterRenewal)
+		**out = **in
+	}
+	if in.Revocation != nil {
+		in, out := &in.Revocation, &out.Revocation
+		*out = new(StepClusterRevocation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Embedded != nil {
+		in, out := &in.Embedded, &out.Embedded
+		*out = new(StepClusterEmbeddedAuthority)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Policy != nil {
+		in, out := &in.Policy, &out.Policy
+		*out = new(StepClusterPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Validity != nil {
+		in, out := &in.Validity, &out.Validity
+		*out = new(StepClusterValidity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Verification != nil {
+		in, out := &in.Verification, &out.Verification
+		*out = new(StepClusterVerification)
+		**out = **in
+	}
+	if in.SubjectStrategy != nil {
+		in, out := &in.SubjectStrategy, &out.SubjectStrategy
+		*out = new(StepClusterSubjectStrategy)
+		**out = **in
+	}
+	if in.SubjectAltNames != nil {
+		in, out := &in.SubjectAltNames, &out.SubjectAltNames
+		*out = new(StepClusterSubjectAltNames)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepClusterIssuerSpec.
@@ -147,6 +292,15 @@ func (in *StepClusterIssuerStatus) DeepCopyInto(out *StepClusterIssuerStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Provisioner != nil {
+		in, out := &in.Provisioner, &out.Provisioner
+		*out = new(StepClusterProvisionerStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PasswordLastLoadedTime != nil {
+		in, out := &in.PasswordLastLoadedTime, &out.PasswordLastLoadedTime
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepClusterIssuerStatus.
@@ -159,10 +313,162 @@ func (in *StepClusterIssuerStatus) DeepCopy() *StepClusterIssuerStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepClusterK8sSAProvisioner) DeepCopyInto(out *StepClusterK8sSAProvisioner) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepClusterK8sSAProvisioner.
+func (in *StepClusterK8sSAProvisioner) DeepCopy() *StepClusterK8sSAProvisioner {
+	if in == nil {
+		return nil
+	}
+	out := new(StepClusterK8sSAProvisioner)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepClusterOIDCProvisioner) DeepCopyInto(out *StepClusterOIDCProvisioner) {
+	*out = *in
+	out.ClientSecretRef = in.ClientSecretRef
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepClusterOIDCProvisioner.
+func (in *StepClusterOIDCProvisioner) DeepCopy() *StepClusterOIDCProvisioner {
+	if in == nil {
+		return nil
+	}
+	out := new(StepClusterOIDCProvisioner)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepClusterPKCS11Key) DeepCopyInto(out *StepClusterPKCS11Key) {
+	*out = *in
+	if in.SlotID != nil {
+		in, out := &in.SlotID, &out.SlotID
+		*out = new(int)
+		**out = **in
+	}
+	out.PINRef = in.PINRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepClusterPKCS11Key.
+func (in *StepClusterPKCS11Key) DeepCopy() *StepClusterPKCS11Key {
+	if in == nil {
+		return nil
+	}
+	out := new(StepClusterPKCS11Key)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepClusterPolicy) DeepCopyInto(out *StepClusterPolicy) {
+	*out = *in
+	if in.DNSNames != nil {
+		in, out := &in.DNSNames, &out.DNSNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPRanges != nil {
+		in, out := &in.IPRanges, &out.IPRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.URIPrefixes != nil {
+		in, out := &in.URIPrefixes, &out.URIPrefixes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.EmailDomains != nil {
+		in, out := &in.EmailDomains, &out.EmailDomains
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.KeyAlgorithms != nil {
+		in, out := &in.KeyAlgorithms, &out.KeyAlgorithms
+		*out = make([]StepClusterPolicyKeyAlgorithm, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxDuration != nil {
+		in, out := &in.MaxDuration, &out.MaxDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepClusterPolicy.
+func (in *StepClusterPolicy) DeepCopy() *StepClusterPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(StepClusterPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepClusterPolicyKeyAlgorithm) DeepCopyInto(out *StepClusterPolicyKeyAlgorithm) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepClusterPolicyKeyAlgorithm.
+func (in *StepClusterPolicyKeyAlgorithm) DeepCopy() *StepClusterPolicyKeyAlgorithm {
+	if in == nil {
+		return nil
+	}
+	out := new(StepClusterPolicyKeyAlgorithm)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StepClusterProvisioner) DeepCopyInto(out *StepClusterProvisioner) {
 	*out = *in
 	out.PasswordRef = in.PasswordRef
+	if in.KeyRef != nil {
+		in, out := &in.KeyRef, &out.KeyRef
+		*out = new(StepClusterIssuerSecretKeySelector)
+		**out = **in
+	}
+	if in.PKCS11 != nil {
+		in, out := &in.PKCS11, &out.PKCS11
+		*out = new(StepClusterPKCS11Key)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OIDC != nil {
+		in, out := &in.OIDC, &out.OIDC
+		*out = new(StepClusterOIDCProvisioner)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.X5C != nil {
+		in, out := &in.X5C, &out.X5C
+		*out = new(StepClusterX5CProvisioner)
+		**out = **in
+	}
+	if in.K8sSA != nil {
+		in, out := &in.K8sSA, &out.K8sSA
+		*out = new(StepClusterK8sSAProvisioner)
+		**out = **in
+	}
+	if in.ACME != nil {
+		in, out := &in.ACME, &out.ACME
+		*out = new(StepClusterACMEProvisioner)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TokenService != nil {
+		in, out := &in.TokenService, &out.TokenService
+		*out = new(StepClusterTokenService)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepClusterProvisioner.
@@ -175,6 +481,250 @@ func (in *StepClusterProvisioner) DeepCopy() *StepClusterProvisioner {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepClusterProvisionerStatus) DeepCopyInto(out *StepClusterProvisionerStatus) {
+	*out = *in
+	if in.MinTLSCertDuration != nil {
+		in, out := &in.MinTLSCertDuration, &out.MinTLSCertDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxTLSCertDuration != nil {
+		in, out := &in.MaxTLSCertDuration, &out.MaxTLSCertDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.DefaultTLSCertDuration != nil {
+		in, out := &in.DefaultTLSCertDuration, &out.DefaultTLSCertDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.DisableRenewal != nil {
+		in, out := &in.DisableRenewal, &out.DisableRenewal
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AllowRenewalAfterExpiry != nil {
+		in, out := &in.AllowRenewalAfterExpiry, &out.AllowRenewalAfterExpiry
+		*out = new(bool)
+		**out = **in
+	}
+	if in.LastUpdateTime != nil {
+		in, out := &in.LastUpdateTime, &out.LastUpdateTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepClusterProvisionerStatus.
+func (in *StepClusterProvisionerStatus) DeepCopy() *StepClusterProvisionerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(StepClusterProvisionerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepClusterRenewal) DeepCopyInto(out *StepClusterRenewal) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepClusterRenewal.
+func (in *StepClusterRenewal) DeepCopy() *StepClusterRenewal {
+	if in == nil {
+		return nil
+	}
+	out := new(StepClusterRenewal)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepClusterRevocation) DeepCopyInto(out *StepClusterRevocation) {
+	*out = *in
+	if in.ReasonCode != nil {
+		in, out := &in.ReasonCode, &out.ReasonCode
+		*out = new(int)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepClusterRevocation.
+func (in *StepClusterRevocation) DeepCopy() *StepClusterRevocation {
+	if in == nil {
+		return nil
+	}
+	out := new(StepClusterRevocation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepClusterSubjectAltNames) DeepCopyInto(out *StepClusterSubjectAltNames) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepClusterSubjectAltNames.
+func (in *StepClusterSubjectAltNames) DeepCopy() *StepClusterSubjectAltNames {
+	if in == nil {
+		return nil
+	}
+	out := new(StepClusterSubjectAltNames)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepClusterSubjectStrategy) DeepCopyInto(out *StepClusterSubjectStrategy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepClusterSubjectStrategy.
+func (in *StepClusterSubjectStrategy) DeepCopy() *StepClusterSubjectStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(StepClusterSubjectStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepClusterTemplateData) DeepCopyInto(out *StepClusterTemplateData) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepClusterTemplateData.
+func (in *StepClusterTemplateData) DeepCopy() *StepClusterTemplateData {
+	if in == nil {
+		return nil
+	}
+	out := new(StepClusterTemplateData)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepClusterTokenService) DeepCopyInto(out *StepClusterTokenService) {
+	*out = *in
+	if in.CABundle != nil {
+		in, out := &in.CABundle, &out.CABundle
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	if in.BearerTokenRef != nil {
+		in, out := &in.BearerTokenRef, &out.BearerTokenRef
+		*out = new(StepClusterIssuerSecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepClusterTokenService.
+func (in *StepClusterTokenService) DeepCopy() *StepClusterTokenService {
+	if in == nil {
+		return nil
+	}
+	out := new(StepClusterTokenService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepClusterValidity) DeepCopyInto(out *StepClusterValidity) {
+	*out = *in
+	if in.DefaultDuration != nil {
+		in, out := &in.DefaultDuration, &out.DefaultDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MinDuration != nil {
+		in, out := &in.MinDuration, &out.MinDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxDuration != nil {
+		in, out := &in.MaxDuration, &out.MaxDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Backdate != nil {
+		in, out := &in.Backdate, &out.Backdate
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepClusterValidity.
+func (in *StepClusterValidity) DeepCopy() *StepClusterValidity {
+	if in == nil {
+		return nil
+	}
+	out := new(StepClusterValidity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepClusterVerification) DeepCopyInto(out *StepClusterVerification) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepClusterVerification.
+func (in *StepClusterVerification) DeepCopy() *StepClusterVerification {
+	if in == nil {
+		return nil
+	}
+	out := new(StepClusterVerification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepClusterX5CProvisioner) DeepCopyInto(out *StepClusterX5CProvisioner) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepClusterX5CProvisioner.
+func (in *StepClusterX5CProvisioner) DeepCopy() *StepClusterX5CProvisioner {
+	if in == nil {
+		return nil
+	}
+	out := new(StepClusterX5CProvisioner)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepEmbeddedAuthority) DeepCopyInto(out *StepEmbeddedAuthority) {
+	*out = *in
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Claims != nil {
+		in, out := &in.Claims, &out.Claims
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepEmbeddedAuthority.
+func (in *StepEmbeddedAuthority) DeepCopy() *StepEmbeddedAuthority {
+	if in == nil {
+		return nil
+	}
+	out := new(StepEmbeddedAuthority)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StepIssuer) DeepCopyInto(out *StepIssuer) {
 	*out = *in
@@ -271,12 +821,57 @@ func (in *StepIssuerSecretKeySelector) DeepCopy() *StepIssuerSecretKeySelector {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StepIssuerSpec) DeepCopyInto(out *StepIssuerSpec) {
 	*out = *in
-	out.Provisioner = in.Provisioner
+	in.Provisioner.DeepCopyInto(&out.Provisioner)
 	if in.CABundle != nil {
 		in, out := &in.CABundle, &out.CABundle
 		*out = make([]byte, len(*in))
 		copy(*out, *in)
 	}
+	if in.TemplateData != nil {
+		in, out := &in.TemplateData, &out.TemplateData
+		*out = new(StepTemplateData)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Renewal != nil {
+		in, out := &in.Renewal, &out.Renewal
+		*out = new(StepRenewal)
+		**out = **in
+	}
+	if in.Revocation != nil {
+		in, out := &in.Revocation, &out.Revocation
+		*out = new(StepRevocation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Embedded != nil {
+		in, out := &in.Embedded, &out.Embedded
+		*out = new(StepEmbeddedAuthority)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Policy != nil {
+		in, out := &in.Policy, &out.Policy
+		*out = new(StepPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Validity != nil {
+		in, out := &in.Validity, &out.Validity
+		*out = new(StepValidity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Verification != nil {
+		in, out := &in.Verification, &out.Verification
+		*out = new(StepVerification)
+		**out = **in
+	}
+	if in.SubjectStrategy != nil {
+		in, out := &in.SubjectStrategy, &out.SubjectStrategy
+		*out = new(StepSubjectStrategy)
+		**out = **in
+	}
+	if in.SubjectAltNames != nil {
+		in, out := &in.SubjectAltNames, &out.SubjectAltNames
+		*out = new(StepSubjectAltNames)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepIssuerSpec.
@@ -299,6 +894,15 @@ func (in *StepIssuerStatus) DeepCopyInto(out *StepIssuerStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Provisioner != nil {
+		in, out := &in.Provisioner, &out.Provisioner
+		*out = new(StepProvisionerStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PasswordLastLoadedTime != nil {
+		in, out := &in.PasswordLastLoadedTime, &out.PasswordLastLoadedTime
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepIssuerStatus.
@@ -311,10 +915,162 @@ func (in *StepIssuerStatus) DeepCopy() *StepIssuerStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepK8sSAProvisioner) DeepCopyInto(out *StepK8sSAProvisioner) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepK8sSAProvisioner.
+func (in *StepK8sSAProvisioner) DeepCopy() *StepK8sSAProvisioner {
+	if in == nil {
+		return nil
+	}
+	out := new(StepK8sSAProvisioner)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepOIDCProvisioner) DeepCopyInto(out *StepOIDCProvisioner) {
+	*out = *in
+	out.ClientSecretRef = in.ClientSecretRef
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepOIDCProvisioner.
+func (in *StepOIDCProvisioner) DeepCopy() *StepOIDCProvisioner {
+	if in == nil {
+		return nil
+	}
+	out := new(StepOIDCProvisioner)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepPKCS11Key) DeepCopyInto(out *StepPKCS11Key) {
+	*out = *in
+	if in.SlotID != nil {
+		in, out := &in.SlotID, &out.SlotID
+		*out = new(int)
+		**out = **in
+	}
+	out.PINRef = in.PINRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepPKCS11Key.
+func (in *StepPKCS11Key) DeepCopy() *StepPKCS11Key {
+	if in == nil {
+		return nil
+	}
+	out := new(StepPKCS11Key)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepPolicy) DeepCopyInto(out *StepPolicy) {
+	*out = *in
+	if in.DNSNames != nil {
+		in, out := &in.DNSNames, &out.DNSNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPRanges != nil {
+		in, out := &in.IPRanges, &out.IPRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.URIPrefixes != nil {
+		in, out := &in.URIPrefixes, &out.URIPrefixes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.EmailDomains != nil {
+		in, out := &in.EmailDomains, &out.EmailDomains
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.KeyAlgorithms != nil {
+		in, out := &in.KeyAlgorithms, &out.KeyAlgorithms
+		*out = make([]StepPolicyKeyAlgorithm, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxDuration != nil {
+		in, out := &in.MaxDuration, &out.MaxDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepPolicy.
+func (in *StepPolicy) DeepCopy() *StepPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(StepPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepPolicyKeyAlgorithm) DeepCopyInto(out *StepPolicyKeyAlgorithm) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepPolicyKeyAlgorithm.
+func (in *StepPolicyKeyAlgorithm) DeepCopy() *StepPolicyKeyAlgorithm {
+	if in == nil {
+		return nil
+	}
+	out := new(StepPolicyKeyAlgorithm)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StepProvisioner) DeepCopyInto(out *StepProvisioner) {
 	*out = *in
 	out.PasswordRef = in.PasswordRef
+	if in.KeyRef != nil {
+		in, out := &in.KeyRef, &out.KeyRef
+		*out = new(StepIssuerSecretKeySelector)
+		**out = **in
+	}
+	if in.PKCS11 != nil {
+		in, out := &in.PKCS11, &out.PKCS11
+		*out = new(StepPKCS11Key)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OIDC != nil {
+		in, out := &in.OIDC, &out.OIDC
+		*out = new(StepOIDCProvisioner)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.X5C != nil {
+		in, out := &in.X5C, &out.X5C
+		*out = new(StepX5CProvisioner)
+		**out = **in
+	}
+	if in.K8sSA != nil {
+		in, out := &in.K8sSA, &out.K8sSA
+		*out = new(StepK8sSAProvisioner)
+		**out = **in
+	}
+	if in.ACME != nil {
+		in, out := &in.ACME, &out.ACME
+		*out = new(StepACMEProvisioner)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TokenService != nil {
+		in, out := &in.TokenService, &out.TokenService
+		*out = new(StepTokenService)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepProvisioner.
@@ -326,3 +1082,222 @@ func (in *StepProvisioner) DeepCopy() *StepProvisioner {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepProvisionerStatus) DeepCopyInto(out *StepProvisionerStatus) {
+	*out = *in
+	if in.MinTLSCertDuration != nil {
+		in, out := &in.MinTLSCertDuration, &out.MinTLSCertDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxTLSCertDuration != nil {
+		in, out := &in.MaxTLSCertDuration, &out.MaxTLSCertDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.DefaultTLSCertDuration != nil {
+		in, out := &in.DefaultTLSCertDuration, &out.DefaultTLSCertDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.DisableRenewal != nil {
+		in, out := &in.DisableRenewal, &out.DisableRenewal
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AllowRenewalAfterExpiry != nil {
+		in, out := &in.AllowRenewalAfterExpiry, &out.AllowRenewalAfterExpiry
+		*out = new(bool)
+		**out = **in
+	}
+	if in.LastUpdateTime != nil {
+		in, out := &in.LastUpdateTime, &out.LastUpdateTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepProvisionerStatus.
+func (in *StepProvisionerStatus) DeepCopy() *StepProvisionerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(StepProvisionerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepRenewal) DeepCopyInto(out *StepRenewal) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepRenewal.
+func (in *StepRenewal) DeepCopy() *StepRenewal {
+	if in == nil {
+		return nil
+	}
+	out := new(StepRenewal)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepRevocation) DeepCopyInto(out *StepRevocation) {
+	*out = *in
+	if in.ReasonCode != nil {
+		in, out := &in.ReasonCode, &out.ReasonCode
+		*out = new(int)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepRevocation.
+func (in *StepRevocation) DeepCopy() *StepRevocation {
+	if in == nil {
+		return nil
+	}
+	out := new(StepRevocation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepSubjectAltNames) DeepCopyInto(out *StepSubjectAltNames) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepSubjectAltNames.
+func (in *StepSubjectAltNames) DeepCopy() *StepSubjectAltNames {
+	if in == nil {
+		return nil
+	}
+	out := new(StepSubjectAltNames)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepSubjectStrategy) DeepCopyInto(out *StepSubjectStrategy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepSubjectStrategy.
+func (in *StepSubjectStrategy) DeepCopy() *StepSubjectStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(StepSubjectStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepTemplateData) DeepCopyInto(out *StepTemplateData) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepTemplateData.
+func (in *StepTemplateData) DeepCopy() *StepTemplateData {
+	if in == nil {
+		return nil
+	}
+	out := new(StepTemplateData)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepTokenService) DeepCopyInto(out *StepTokenService) {
+	*out = *in
+	if in.CABundle != nil {
+		in, out := &in.CABundle, &out.CABundle
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	if in.BearerTokenRef != nil {
+		in, out := &in.BearerTokenRef, &out.BearerTokenRef
+		*out = new(StepIssuerSecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepTokenService.
+func (in *StepTokenService) DeepCopy() *StepTokenService {
+	if in == nil {
+		return nil
+	}
+	out := new(StepTokenService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepValidity) DeepCopyInto(out *StepValidity) {
+	*out = *in
+	if in.DefaultDuration != nil {
+		in, out := &in.DefaultDuration, &out.DefaultDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MinDuration != nil {
+		in, out := &in.MinDuration, &out.MinDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxDuration != nil {
+		in, out := &in.MaxDuration, &out.MaxDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Backdate != nil {
+		in, out := &in.Backdate, &out.Backdate
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepValidity.
+func (in *StepValidity) DeepCopy() *StepValidity {
+	if in == nil {
+		return nil
+	}
+	out := new(StepValidity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepVerification) DeepCopyInto(out *StepVerification) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepVerification.
+func (in *StepVerification) DeepCopy() *StepVerification {
+	if in == nil {
+		return nil
+	}
+	out := new(StepVerification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepX5CProvisioner) DeepCopyInto(out *StepX5CProvisioner) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepX5CProvisioner.
+func (in *StepX5CProvisioner) DeepCopy() *StepX5CProvisioner {
+	if in == nil {
+		return nil
+	}
+	out := new(StepX5CProvisioner)
+	in.DeepCopyInto(out)
+	return out
+}
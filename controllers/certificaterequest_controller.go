@@ -18,7 +18,10 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	apiutil "github.com/cert-manager/cert-manager/pkg/api/util"
 	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
@@ -33,7 +36,12 @@ import (
 	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 // CertificateRequestReconciler reconciles a StepIssuer object.
@@ -44,6 +52,25 @@ type CertificateRequestReconciler struct {
 
 	Clock                  clock.Clock
 	CheckApprovedCondition bool
+
+	// MemoryCA creates in-memory CAs for the issuers that are not loaded
+	// yet, as the issuer reconcilers do with the same option.
+	MemoryCA bool
+
+	// ACMEHTTP01Solver reports whether the controller answers the http-01
+	// challenges of ACME provisioners, as the issuer reconcilers do with the
+	// same option.
+	ACMEHTTP01Solver bool
+
+	// durationEvents holds the last Duration Event of the pending requests by
+	// NamespacedName, so it is only emitted again if the duration changes.
+	durationEvents sync.Map
+}
+
+// durationEvent is the value stored in durationEvents.
+type durationEvent struct {
+	uid     types.UID
+	message string
 }
 
 // +kubebuilder:rbac:groups=cert-manager.io,resources=certificaterequests,verbs=get;list;watch;update
@@ -60,6 +87,7 @@ func (r *CertificateRequestReconciler) Reconcile(ctx context.Context, req ctrl.R
 	cr := new(cmapi.CertificateRequest)
 	if err := r.Client.Get(ctx, req.NamespacedName, cr); err != nil {
 		if apierrors.IsNotFound(err) {
+			r.durationEvents.Delete(req.NamespacedName)
 			return ctrl.Result{}, nil
 		}
 
@@ -123,20 +151,46 @@ func (r *CertificateRequestReconciler) Reconcile(ctx context.Context, req ctrl.R
 			return ctrl.Result{}, err
 		}
 
-		// Load the provisioner that will sign the CertificateRequest
-		provisioner, ok := provisioners.Load(issNamespaceName)
-		if !ok {
-			err := fmt.Errorf("provisioner %s not found", issNamespaceName)
-			log.Error(err, "failed to provisioner for StepClusterIssuer resource")
-			_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonPending, "Failed to load provisioner for StepClusterIssuer resource %s", issNamespaceName)
+		// Apply the validity of the issuer and reject the requests that violate
+		// its policy before sending them to the CA
+		policy, err := provisioners.NewPolicyFromStepClusterIssuer(&iss)
+		if err != nil {
+			log.Error(err, "failed to load policy for StepClusterIssuer resource")
+			_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonPending, "Failed to load policy for StepClusterIssuer resource %s: %v", issNamespaceName, err)
+			return ctrl.Result{}, err
+		}
+		toSign, err := r.prepareRequest(ctx, log, cr, provisioners.NewValidityFromStepClusterIssuer(&iss), policy,
+			provisioners.NewProvisionerClaimsFromStepClusterIssuer(&iss))
+		if toSign == nil {
 			return ctrl.Result{}, err
 		}
 
-		// Sign CertificateRequest
-		signedPEM, trustedCAs, err := provisioner.Sign(ctx, cr)
+		// Load the provisioner that will sign the CertificateRequest, or create
+		// it if the StepClusterIssuer has not been reconciled since the controller
+		// started or it changed since the provisioner was created. It is
+		// validated and created as the StepClusterIssuer reconciler does.
+		provisioner, release, err := provisioners.LoadOrCreate(issNamespaceName, iss.Generation, func() (provisioners.Signer, error) {
+			log.Info("initializing provisioner for StepClusterIssuer resource", "issuer", issNamespaceName)
+			p, _, err := newStepClusterIssuerSigner(ctx, r.Client, &iss, r.signerOptions())
+			return p, err
+		})
 		if err != nil {
-			log.Error(err, "failed to sign certificate request")
-			return ctrl.Result{}, r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonFailed, "Failed to sign certificate request: %v", err)
+			log.Error(err, "failed to load provisioner for StepClusterIssuer resource")
+			_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonPending, "Failed to load provisioner for StepClusterIssuer resource %s: %v", issNamespaceName, err)
+			return ctrl.Result{}, err
+		}
+		// The provisioner is not closed while it signs the request, even if
+		// the StepClusterIssuer is updated or deleted in the meantime.
+		defer release()
+
+		// Sign CertificateRequest, renewing the previous certificate if enabled
+		var opts []provisioners.SignOption
+		if iss.Spec.Renewal != nil {
+			opts = r.renewalOptions(ctx, log, cr)
+		}
+		signedPEM, trustedCAs, err := provisioner.Sign(ctx, toSign, opts...)
+		if err != nil {
+			return ctrl.Result{}, r.signFailed(ctx, log, cr, err)
 		}
 		cr.Status.Certificate = signedPEM
 		cr.Status.CA = trustedCAs
@@ -164,20 +218,46 @@ func (r *CertificateRequestReconciler) Reconcile(ctx context.Context, req ctrl.R
 		return ctrl.Result{}, err
 	}
 
-	// Load the provisioner that will sign the CertificateRequest
-	provisioner, ok := provisioners.Load(issNamespaceName)
-	if !ok {
-		err := fmt.Errorf("provisioner %s not found", issNamespaceName)
-		log.Error(err, "failed to provisioner for StepIssuer resource")
-		_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonPending, "Failed to load provisioner for StepIssuer resource %s", issNamespaceName)
+	// Apply the validity of the issuer and reject the requests that violate
+	// its policy before sending them to the CA
+	policy, err := provisioners.NewPolicyFromStepIssuer(&iss)
+	if err != nil {
+		log.Error(err, "failed to load policy for StepIssuer resource")
+		_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonPending, "Failed to load policy for StepIssuer resource %s: %v", issNamespaceName, err)
+		return ctrl.Result{}, err
+	}
+	toSign, err := r.prepareRequest(ctx, log, cr, provisioners.NewValidityFromStepIssuer(&iss), policy,
+		provisioners.NewProvisionerClaimsFromStepIssuer(&iss))
+	if toSign == nil {
 		return ctrl.Result{}, err
 	}
 
-	// Sign CertificateRequest
-	signedPEM, trustedCAs, err := provisioner.Sign(ctx, cr)
+	// Load the provisioner that will sign the CertificateRequest, or create
+	// it if the StepIssuer has not been reconciled since the controller
+	// started or it changed since the provisioner was created. It is validated
+	// and created as the StepIssuer reconciler does.
+	provisioner, release, err := provisioners.LoadOrCreate(issNamespaceName, iss.Generation, func() (provisioners.Signer, error) {
+		log.Info("initializing provisioner for StepIssuer resource", "issuer", issNamespaceName)
+		p, _, err := newStepIssuerSigner(ctx, r.Client, &iss, r.signerOptions())
+		return p, err
+	})
 	if err != nil {
-		log.Error(err, "failed to sign certificate request")
-		return ctrl.Result{}, r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonFailed, "Failed to sign certificate request: %v", err)
+		log.Error(err, "failed to load provisioner for StepIssuer resource")
+		_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonPending, "Failed to load provisioner for StepIssuer resource %s: %v", issNamespaceName, err)
+		return ctrl.Result{}, err
+	}
+	// The provisioner is not closed while it signs the request, even if
+	// the StepIssuer is updated or deleted in the meantime.
+	defer release()
+
+	// Sign CertificateRequest, renewing the previous certificate if enabled
+	var opts []provisioners.SignOption
+	if iss.Spec.Renewal != nil {
+		opts = r.renewalOptions(ctx, log, cr)
+	}
+	signedPEM, trustedCAs, err := provisioner.Sign(ctx, toSign, opts...)
+	if err != nil {
+		return ctrl.Result{}, r.signFailed(ctx, log, cr, err)
 	}
 	cr.Status.Certificate = signedPEM
 	cr.Status.CA = trustedCAs
@@ -185,14 +265,167 @@ func (r *CertificateRequestReconciler) Reconcile(ctx context.Context, req ctrl.R
 	return ctrl.Result{}, r.setStatus(ctx, cr, cmmeta.ConditionTrue, cmapi.CertificateRequestReasonIssued, "Certificate issued")
 }
 
+// signerOptions returns the options used to create the signers of the
+// issuers that have not been reconciled yet.
+func (r *CertificateRequestReconciler) signerOptions() signerOptions {
+	return signerOptions{memoryCA: r.MemoryCA, acmeHTTP01Solver: r.ACMEHTTP01Solver}
+}
+
 // SetupWithManager initializes the CertificateRequest controller into the
-// controller runtime.
+// controller runtime. The pending CertificateRequests are reconciled as soon
+// as their issuer becomes Ready, instead of waiting for their backoff.
 func (r *CertificateRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &cmapi.CertificateRequest{}, issuerRefField, indexIssuerRef); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&cmapi.CertificateRequest{}).
+		Watches(&api.StepIssuer{}, handler.EnqueueRequestsFromMapFunc(r.pendingRequestsFor("StepIssuer")),
+			builder.WithPredicates(becameReady(func(obj client.Object) bool {
+				iss, ok := obj.(*api.StepIssuer)
+				return ok && stepIssuerHasCondition(*iss, api.StepIssuerCondition{Type: api.ConditionReady, Status: api.ConditionTrue})
+			}))).
+		Watches(&api.StepClusterIssuer{}, handler.EnqueueRequestsFromMapFunc(r.pendingRequestsFor("StepClusterIssuer")),
+			builder.WithPredicates(becameReady(func(obj client.Object) bool {
+				iss, ok := obj.(*api.StepClusterIssuer)
+				return ok && stepClusterIssuerHasCondition(*iss, api.StepClusterIssuerCondition{Type: api.ConditionReady, Status: api.ConditionTrue})
+			}))).
 		Complete(r)
 }
 
+// pendingRequestsFor returns a map function that enqueues the pending
+// CertificateRequests that reference an issuer of the given kind. The
+// requests are pending until they are signed, failed or denied.
+func (r *CertificateRequestReconciler) pendingRequestsFor(kind string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		crs := new(cmapi.CertificateRequestList)
+		if err := r.Client.List(ctx, crs, client.MatchingFields{
+			issuerRefField: issuerRefKey(kind, obj.GetNamespace(), obj.GetName()),
+		}); err != nil {
+			r.Log.Error(err, "failed to list CertificateRequests", "kind", kind, "namespace", obj.GetNamespace(), "name", obj.GetName())
+			return nil
+		}
+		var requests []reconcile.Request
+		for i := range crs.Items {
+			cr := &crs.Items[i]
+			if len(cr.Status.Certificate) > 0 || cr.Status.FailureTime != nil || apiutil.CertificateRequestIsDenied(cr) {
+				continue
+			}
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cr.Namespace, Name: cr.Name}})
+		}
+		return requests
+	}
+}
+
+// becameReady returns a predicate that only accepts the updates of the
+// issuers that set their Ready condition to True.
+func becameReady(ready func(client.Object) bool) predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		UpdateFunc:  func(e event.UpdateEvent) bool { return !ready(e.ObjectOld) && ready(e.ObjectNew) },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+	}
+}
+
+// prepareRequest returns a copy of the CertificateRequest with the duration
+// chosen by the validity settings of the issuer, and records it in an Event.
+// If the request violates the validity settings or the policy of the issuer,
+// or its duration is not allowed by the claims of the provisioner published
+// in the issuer status, it is marked as failed and prepareRequest returns nil.
+// If the policy needs the claims and they are not published yet, the request
+// is left pending and prepareRequest returns nil and an error to retry it.
+func (r *CertificateRequestReconciler) prepareRequest(ctx context.Context, log logr.Logger, cr *cmapi.CertificateRequest,
+	validity *provisioners.Validity, policy *provisioners.Policy, claims *provisioners.ProvisionerClaims) (*cmapi.CertificateRequest, error) {
+	toSign := cr
+	if validity != nil {
+		duration, reason, err := validity.Duration(cr)
+		if err != nil {
+			return nil, r.failRequest(ctx, log, cr, err)
+		}
+		toSign = cr.DeepCopy()
+		if duration > 0 {
+			toSign.Spec.Duration = &metav1.Duration{Duration: duration}
+			r.recordDuration(cr, fmt.Sprintf("Certificate requested with a duration of %s, %s", duration, reason))
+		} else {
+			r.recordDuration(cr, fmt.Sprintf("Certificate requested with %s", reason))
+		}
+	}
+	if claims != nil {
+		if err := claims.ValidateDuration(toSign); err != nil {
+			return nil, r.failRequest(ctx, log, cr, err)
+		}
+	}
+	if policy != nil {
+		// The requests without a duration get the default of the
+		// provisioner, they wait until its claims are known, e.g. if the
+		// CA could not be reached.
+		if claims == nil && policy.NeedsDefaultDuration(toSign) {
+			err := fmt.Errorf("the claims of the provisioner are not known, the default duration cannot be checked against spec.policy.maxDuration")
+			log.Error(err, "failed to evaluate policy")
+			_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonPending, "Waiting for the provisioner claims in the issuer status: %v", err)
+			return nil, err
+		}
+		var defaultDuration time.Duration
+		if claims != nil {
+			defaultDuration = claims.DefaultDuration
+		}
+		csr, err := provisioners.DecodeCSR(cr.Spec.Request)
+		if err == nil {
+			err = policy.Evaluate(toSign, csr, defaultDuration)
+		}
+		if err != nil {
+			return nil, r.failRequest(ctx, log, cr, err)
+		}
+	}
+	return toSign, nil
+}
+
+// recordDuration records the duration chosen for a CertificateRequest in an
+// Event, unless it was already recorded for the same request.
+func (r *CertificateRequestReconciler) recordDuration(cr *cmapi.CertificateRequest, message string) {
+	e := durationEvent{uid: cr.UID, message: message}
+	if old, loaded := r.durationEvents.Swap(client.ObjectKeyFromObject(cr), e); loaded && old == e {
+		return
+	}
+	r.Recorder.Event(cr, core.EventTypeNormal, "Duration", message)
+}
+
+// failRequest marks a CertificateRequest that cannot be signed as failed.
+func (r *CertificateRequestReconciler) failRequest(ctx context.Context, log logr.Logger, cr *cmapi.CertificateRequest, err error) error {
+	log.Error(err, "failed to sign certificate request")
+	if cr.Status.FailureTime == nil {
+		nowTime := metav1.NewTime(r.Clock.Now())
+		cr.Status.FailureTime = &nowTime
+	}
+	return r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonFailed, "Failed to sign certificate request: %v", err)
+}
+
+// signFailed marks a CertificateRequest as failed after an error signing it.
+// If the certificate issued by the CA failed verification, a Warning Event
+// with the reason of the failure is recorded as well.
+func (r *CertificateRequestReconciler) signFailed(ctx context.Context, log logr.Logger, cr *cmapi.CertificateRequest, err error) error {
+	log.Error(err, "failed to sign certificate request")
+	var reason string
+	switch {
+	case errors.Is(err, provisioners.ErrPublicKeyMismatch):
+		reason = "PublicKeyMismatch"
+	case errors.Is(err, provisioners.ErrSANMismatch):
+		reason = "SANMismatch"
+	case errors.Is(err, provisioners.ErrUntrustedChain):
+		reason = "UntrustedChain"
+	case errors.Is(err, provisioners.ErrCertificateExpired):
+		reason = "CertificateExpired"
+	case errors.Is(err, provisioners.ErrCertificateMismatch):
+		reason = "UsageMismatch"
+	}
+	if reason != "" {
+		r.Recorder.Eventf(cr, core.EventTypeWarning, reason, "Issued certificate failed verification: %v", err)
+		return r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonFailed, "Failed to verify issued certificate (%s): %v", reason, err)
+	}
+	return r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonFailed, "Failed to sign certificate request: %v", err)
+}
+
 // stepIssuerHasCondition will return true if the given StepIssuer resource has
 // a condition matching the provided StepIssuerCondition. Only the Type and
 // Status field will be used in the comparison, meaning that this function will
@@ -234,5 +467,11 @@ func (r *CertificateRequestReconciler) setStatus(ctx context.Context, cr *cmapi.
 	}
 	r.Recorder.Event(cr, eventType, reason, completeMessage)
 
+	// The requests issued, failed or denied are not sent to the CA again.
+	switch reason {
+	case cmapi.CertificateRequestReasonIssued, cmapi.CertificateRequestReasonFailed, cmapi.CertificateRequestReasonDenied:
+		r.durationEvents.Delete(client.ObjectKeyFromObject(cr))
+	}
+
 	return r.Client.Status().Update(ctx, cr)
 }
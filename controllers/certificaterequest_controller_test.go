@@ -0,0 +1,300 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"slices"
+	"testing"
+	"time"
+
+	apiutil "github.com/cert-manager/cert-manager/pkg/api/util"
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	"github.com/go-logr/logr"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	"github.com/smallstep/step-issuer/provisioners"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestPendingRequestsFor(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = cmapi.AddToScheme(scheme)
+	_ = api.AddToScheme(scheme)
+
+	newCR := func(namespace, name string, ref cmmeta.ObjectReference) *cmapi.CertificateRequest {
+		return &cmapi.CertificateRequest{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       cmapi.CertificateRequestSpec{IssuerRef: ref},
+		}
+	}
+	issuerRef := cmmeta.ObjectReference{Group: api.GroupVersion.Group, Kind: "StepIssuer", Name: "step-issuer"}
+	clusterIssuerRef := cmmeta.ObjectReference{Group: api.GroupVersion.Group, Kind: "StepClusterIssuer", Name: "step-issuer"}
+
+	signed := newCR("default", "signed", issuerRef)
+	signed.Status.Certificate = []byte("certificate")
+	failed := newCR("default", "failed", issuerRef)
+	now := metav1.Now()
+	failed.Status.FailureTime = &now
+
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithIndex(&cmapi.CertificateRequest{}, issuerRefField, indexIssuerRef).
+		WithObjects(
+			newCR("default", "pending", issuerRef),
+			newCR("default", "no-group", cmmeta.ObjectReference{Name: "step-issuer"}),
+			newCR("other", "other-namespace", issuerRef),
+			newCR("default", "cluster", clusterIssuerRef),
+			newCR("other", "cluster", clusterIssuerRef),
+			newCR("default", "other-group", cmmeta.ObjectReference{Group: "cert-manager.io", Kind: "Issuer", Name: "step-issuer"}),
+			signed, failed,
+		).Build()
+	r := &CertificateRequestReconciler{Client: c, Log: logr.Discard()}
+
+	names := func(kind string, obj client.Object) []string {
+		var names []string
+		for _, req := range r.pendingRequestsFor(kind)(context.Background(), obj) {
+			names = append(names, req.String())
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	iss := &api.StepIssuer{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "step-issuer"}}
+	if got, want := names("StepIssuer", iss), []string{"default/no-group", "default/pending"}; !slices.Equal(got, want) {
+		t.Errorf("StepIssuer: expected %v, got %v", want, got)
+	}
+	clusterIss := &api.StepClusterIssuer{ObjectMeta: metav1.ObjectMeta{Name: "step-issuer"}}
+	if got, want := names("StepClusterIssuer", clusterIss), []string{"default/cluster", "other/cluster"}; !slices.Equal(got, want) {
+		t.Errorf("StepClusterIssuer: expected %v, got %v", want, got)
+	}
+}
+
+func TestBecameReady(t *testing.T) {
+	newIssuer := func(status api.ConditionStatus) *api.StepIssuer {
+		return &api.StepIssuer{Status: api.StepIssuerStatus{
+			Conditions: []api.StepIssuerCondition{{Type: api.ConditionReady, Status: status}},
+		}}
+	}
+	p := becameReady(func(obj client.Object) bool {
+		iss, ok := obj.(*api.StepIssuer)
+		return ok && stepIssuerHasCondition(*iss, api.StepIssuerCondition{Type: api.ConditionReady, Status: api.ConditionTrue})
+	})
+
+	tests := []struct {
+		name     string
+		old, new *api.StepIssuer
+		want     bool
+	}{
+		{name: "not ready to ready", old: newIssuer(api.ConditionFalse), new: newIssuer(api.ConditionTrue), want: true},
+		{name: "no condition to ready", old: &api.StepIssuer{}, new: newIssuer(api.ConditionTrue), want: true},
+		{name: "ready to ready", old: newIssuer(api.ConditionTrue), new: newIssuer(api.ConditionTrue)},
+		{name: "ready to not ready", old: newIssuer(api.ConditionTrue), new: newIssuer(api.ConditionFalse)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Update(event.UpdateEvent{ObjectOld: tt.old, ObjectNew: tt.new}); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+	if p.Create(event.CreateEvent{Object: newIssuer(api.ConditionTrue)}) {
+		t.Error("expected create events to be ignored")
+	}
+}
+
+func TestPrepareRequestDurationEvent(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	r := &CertificateRequestReconciler{Log: logr.Discard(), Recorder: recorder}
+	cr := &cmapi.CertificateRequest{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cr", UID: "1"}}
+
+	prepare := func(defaultDuration time.Duration) {
+		iss := &api.StepIssuer{Spec: api.StepIssuerSpec{Validity: &api.StepValidity{
+			DefaultDuration: &metav1.Duration{Duration: defaultDuration},
+		}}}
+		if _, err := r.prepareRequest(context.Background(), r.Log, cr, provisioners.NewValidityFromStepIssuer(iss), nil, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	events := func() []string {
+		var events []string
+		for {
+			select {
+			case e := <-recorder.Events:
+				events = append(events, e)
+			default:
+				return events
+			}
+		}
+	}
+
+	// The Event is emitted once while the duration does not change.
+	prepare(time.Hour)
+	prepare(time.Hour)
+	if got, want := events(), []string{"Normal Duration Certificate requested with a duration of 1h0m0s, spec.validity.defaultDuration"}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	prepare(2 * time.Hour)
+	if got, want := events(), []string{"Normal Duration Certificate requested with a duration of 2h0m0s, spec.validity.defaultDuration"}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// A new request with the same name gets its own Event.
+	cr.UID = "2"
+	prepare(2 * time.Hour)
+	if got := events(); len(got) != 1 {
+		t.Errorf("expected one event, got %v", got)
+	}
+}
+
+func TestPrepareRequestUnknownClaims(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = cmapi.AddToScheme(scheme)
+	cr := &cmapi.CertificateRequest{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cr"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr).WithStatusSubresource(cr).Build()
+	r := &CertificateRequestReconciler{Client: c, Log: logr.Discard(), Recorder: record.NewFakeRecorder(10), Clock: clock.RealClock{}}
+
+	policy, err := provisioners.NewPolicyFromStepIssuer(&api.StepIssuer{Spec: api.StepIssuerSpec{Policy: &api.StepPolicy{
+		MaxDuration: &metav1.Duration{Duration: 24 * time.Hour},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The request waits for the claims of the provisioner instead of failing.
+	toSign, err := r.prepareRequest(context.Background(), r.Log, cr, nil, policy, nil)
+	if toSign != nil || err == nil {
+		t.Fatalf("expected the request to be retried, got %v, %v", toSign, err)
+	}
+	got := new(cmapi.CertificateRequest)
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(cr), got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.FailureTime != nil {
+		t.Error("expected no failure time")
+	}
+	if cond := apiutil.GetCertificateRequestCondition(got, cmapi.CertificateRequestConditionReady); cond == nil || cond.Reason != cmapi.CertificateRequestReasonPending {
+		t.Errorf("expected a Pending condition, got %v", cond)
+	}
+
+	// Once the claims are published, a provisioner without a default
+	// duration violates the policy.
+	toSign, err = r.prepareRequest(context.Background(), r.Log, cr, nil, policy, &provisioners.ProvisionerClaims{})
+	if toSign != nil || err != nil {
+		t.Fatalf("expected the request to fail, got %v, %v", toSign, err)
+	}
+	if cr.Status.FailureTime == nil {
+		t.Error("expected a failure time")
+	}
+}
+
+func TestReconcileMemoryCA(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = cmapi.AddToScheme(scheme)
+	_ = api.AddToScheme(scheme)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "test.example.com"},
+		DNSNames: []string{"test.example.com"},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iss := &api.StepIssuer{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "memory-ca"},
+		Spec: api.StepIssuerSpec{
+			URL:      "https://ca.example.com",
+			CABundle: []byte("bundle"),
+			Provisioner: api.StepProvisioner{
+				Name:        "admin",
+				KeyID:       "kid",
+				PasswordRef: api.StepIssuerSecretKeySelector{Name: "missing", Key: "password"},
+			},
+		},
+		Status: api.StepIssuerStatus{Conditions: []api.StepIssuerCondition{
+			{Type: api.ConditionReady, Status: api.ConditionTrue},
+		}},
+	}
+	cr := &cmapi.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cr"},
+		Spec: cmapi.CertificateRequestSpec{
+			Request:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}),
+			Duration:  &metav1.Duration{Duration: time.Hour},
+			IssuerRef: cmmeta.ObjectReference{Group: api.GroupVersion.Group, Kind: "StepIssuer", Name: iss.Name},
+		},
+	}
+	apiutil.SetCertificateRequestCondition(cr, cmapi.CertificateRequestConditionApproved, cmmeta.ConditionTrue, "Approved", "approved")
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(iss, cr).WithStatusSubresource(iss, cr).Build()
+	r := &CertificateRequestReconciler{
+		Client:                 c,
+		Log:                    logr.Discard(),
+		Recorder:               record.NewFakeRecorder(10),
+		Clock:                  clock.RealClock{},
+		CheckApprovedCondition: true,
+		MemoryCA:               true,
+	}
+
+	// The StepIssuer has not been reconciled, the request creates its
+	// in-memory CA without reading the provisioner password.
+	issKey := client.ObjectKeyFromObject(iss)
+	t.Cleanup(func() { provisioners.Delete(issKey) })
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(cr)}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := new(cmapi.CertificateRequest)
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(cr), got); err != nil {
+		t.Fatal(err)
+	}
+	if cond := apiutil.GetCertificateRequestCondition(got, cmapi.CertificateRequestConditionReady); cond == nil || cond.Reason != cmapi.CertificateRequestReasonIssued {
+		t.Fatalf("expected an Issued condition, got %v", cond)
+	}
+	block, _ := pem.Decode(got.Status.Certificate)
+	if block == nil {
+		t.Fatalf("expected a PEM certificate, got %q", got.Status.Certificate)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(cert.DNSNames, []string{"test.example.com"}) {
+		t.Errorf("expected the DNS names of the request, got %v", cert.DNSNames)
+	}
+	if len(got.Status.CA) == 0 {
+		t.Error("expected the CA certificate")
+	}
+	if _, ok := provisioners.Load(issKey); !ok {
+		t.Error("expected the in-memory CA to be stored")
+	}
+}
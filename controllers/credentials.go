@@ -0,0 +1,267 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	"github.com/smallstep/step-issuer/provisioners"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// secretRef references a Kubernetes Secret, or a key in it, used by the
+// provisioner of an issuer.
+type secretRef struct {
+	namespace string
+	name      string
+	key       string
+}
+
+// issuerSecretRef returns the reference of a Secret in the namespace of a
+// StepIssuer.
+func issuerSecretRef(namespace string, ref api.StepIssuerSecretKeySelector) *secretRef {
+	return &secretRef{namespace: namespace, name: ref.Name, key: ref.Key}
+}
+
+// clusterIssuerSecretRef returns the reference of a Secret used by a
+// StepClusterIssuer.
+func clusterIssuerSecretRef(ref api.StepClusterIssuerSecretKeySelector) *secretRef {
+	return &secretRef{namespace: ref.Namespace, name: ref.Name, key: ref.Key}
+}
+
+// credentialRefs holds the Secrets and the password sources of the provisioner
+// of an issuer, with the namespaces resolved for its kind.
+type credentialRefs struct {
+	embedded                *secretRef
+	clientSecret            *secretRef
+	x5c                     *secretRef
+	k8sSA                   bool
+	tokenService            bool
+	tokenServiceBearerToken *secretRef
+	tokenServiceClientCert  *secretRef
+	acmeAccountKey          *secretRef
+	acmeEABKey              *secretRef
+	pkcs11PIN               *secretRef
+	jwkKey                  *secretRef
+	password                secretRef
+	passwordEnv             string
+	passwordFile            string
+}
+
+// resolveStepIssuerCredentials loads the secret material required by the
+// provisioner of a StepIssuer. Secrets are read from the issuer's namespace.
+//
+// The returned bool reports whether a failure is a "not found" condition.
+func resolveStepIssuerCredentials(ctx context.Context, c client.Client, iss *api.StepIssuer) (provisioners.Credentials, bool, error) {
+	ns := iss.Namespace
+	p := iss.Spec.Provisioner
+	refs := credentialRefs{
+		k8sSA:        p.K8sSA != nil,
+		tokenService: p.TokenService != nil,
+		password:     *issuerSecretRef(ns, p.PasswordRef),
+		passwordEnv:  p.PasswordEnv,
+		passwordFile: p.PasswordFile,
+	}
+	if e := iss.Spec.Embedded; e != nil {
+		refs.embedded = &secretRef{namespace: ns, name: e.SecretName}
+	}
+	if p.OIDC != nil {
+		refs.clientSecret = issuerSecretRef(ns, p.OIDC.ClientSecretRef)
+	}
+	if p.X5C != nil {
+		refs.x5c = &secretRef{namespace: ns, name: p.X5C.SecretName}
+	}
+	if t := p.TokenService; t != nil {
+		if t.BearerTokenRef != nil {
+			refs.tokenServiceBearerToken = issuerSecretRef(ns, *t.BearerTokenRef)
+		}
+		if t.ClientCertSecretName != "" {
+			refs.tokenServiceClientCert = &secretRef{namespace: ns, name: t.ClientCertSecretName}
+		}
+	}
+	if a := p.ACME; a != nil {
+		refs.acmeAccountKey = issuerSecretRef(ns, a.AccountKeyRef)
+		if a.ExternalAccountBinding != nil {
+			refs.acmeEABKey = issuerSecretRef(ns, a.ExternalAccountBinding.KeySecretRef)
+		}
+	}
+	if p.PKCS11 != nil {
+		refs.pkcs11PIN = issuerSecretRef(ns, p.PKCS11.PINRef)
+	}
+	if p.KeyRef != nil {
+		refs.jwkKey = issuerSecretRef(ns, *p.KeyRef)
+	}
+	return resolveCredentials(ctx, c, refs)
+}
+
+// resolveStepClusterIssuerCredentials loads the secret material required by
+// the provisioner of a StepClusterIssuer. Secrets are read from the namespace
+// set in each reference.
+//
+// The returned bool reports whether a failure is a "not found" condition.
+func resolveStepClusterIssuerCredentials(ctx context.Context, c client.Client, iss *api.StepClusterIssuer) (provisioners.Credentials, bool, error) {
+	p := iss.Spec.Provisioner
+	refs := credentialRefs{
+		k8sSA:        p.K8sSA != nil,
+		tokenService: p.TokenService != nil,
+		password:     *clusterIssuerSecretRef(p.PasswordRef),
+		passwordEnv:  p.PasswordEnv,
+		passwordFile: p.PasswordFile,
+	}
+	if e := iss.Spec.Embedded; e != nil {
+		refs.embedded = &secretRef{namespace: e.SecretNamespace, name: e.SecretName}
+	}
+	if p.OIDC != nil {
+		refs.clientSecret = clusterIssuerSecretRef(p.OIDC.ClientSecretRef)
+	}
+	if p.X5C != nil {
+		refs.x5c = &secretRef{namespace: p.X5C.SecretNamespace, name: p.X5C.SecretName}
+	}
+	if t := p.TokenService; t != nil {
+		if t.BearerTokenRef != nil {
+			refs.tokenServiceBearerToken = clusterIssuerSecretRef(*t.BearerTokenRef)
+		}
+		if t.ClientCertSecretName != "" {
+			refs.tokenServiceClientCert = &secretRef{namespace: t.ClientCertSecretNamespace, name: t.ClientCertSecretName}
+		}
+	}
+	if a := p.ACME; a != nil {
+		refs.acmeAccountKey = clusterIssuerSecretRef(a.AccountKeyRef)
+		if a.ExternalAccountBinding != nil {
+			refs.acmeEABKey = clusterIssuerSecretRef(a.ExternalAccountBinding.KeySecretRef)
+		}
+	}
+	if p.PKCS11 != nil {
+		refs.pkcs11PIN = clusterIssuerSecretRef(p.PKCS11.PINRef)
+	}
+	if p.KeyRef != nil {
+		refs.jwkKey = clusterIssuerSecretRef(*p.KeyRef)
+	}
+	return resolveCredentials(ctx, c, refs)
+}
+
+// resolveCredentials loads the secret material required by the provisioner of
+// an issuer of any kind.
+//
+// The returned bool reports whether a failure is a "not found" condition.
+func resolveCredentials(ctx context.Context, c client.Client, refs credentialRefs) (creds provisioners.Credentials, notFound bool, err error) {
+	switch {
+	case refs.embedded != nil:
+		creds.EmbeddedRoot, creds.EmbeddedIntermediate, creds.EmbeddedIntermediateKey, notFound, err = resolveCASecret(ctx, c, refs.embedded.namespace, refs.embedded.name)
+		return creds, notFound, err
+	case refs.clientSecret != nil:
+		creds.ClientSecret, notFound, err = resolveSecretKey(ctx, c, refs.clientSecret.namespace, refs.clientSecret.name, refs.clientSecret.key)
+		return creds, notFound, err
+	case refs.x5c != nil:
+		creds.X5CCertificate, creds.X5CKey, notFound, err = resolveTLSSecret(ctx, c, refs.x5c.namespace, refs.x5c.name)
+		return creds, notFound, err
+	case refs.k8sSA:
+		creds.ServiceAccountToken = serviceAccountTokenReader(c)
+		return creds, false, nil
+	case refs.tokenService:
+		if t := refs.tokenServiceBearerToken; t != nil {
+			creds.TokenServiceBearerToken, notFound, err = resolveSecretKey(ctx, c, t.namespace, t.name, t.key)
+			if err != nil {
+				return creds, notFound, err
+			}
+		}
+		if t := refs.tokenServiceClientCert; t != nil {
+			creds.TokenServiceClientCertificate, creds.TokenServiceClientKey, notFound, err = resolveTLSSecret(ctx, c, t.namespace, t.name)
+		}
+		return creds, notFound, err
+	case refs.acmeAccountKey != nil:
+		a := refs.acmeAccountKey
+		creds.ACMEAccountKey, notFound, err = resolveSecretKey(ctx, c, a.namespace, a.name, a.key)
+		if err != nil || refs.acmeEABKey == nil {
+			return creds, notFound, err
+		}
+		eab := refs.acmeEABKey
+		creds.ACMEEABKey, notFound, err = resolveSecretKey(ctx, c, eab.namespace, eab.name, eab.key)
+		return creds, notFound, err
+	case refs.pkcs11PIN != nil:
+		creds.PKCS11PIN, notFound, err = resolveSecretKey(ctx, c, refs.pkcs11PIN.namespace, refs.pkcs11PIN.name, refs.pkcs11PIN.key)
+		return creds, notFound, err
+	case refs.jwkKey != nil:
+		creds.JWKKey, notFound, err = resolveSecretKey(ctx, c, refs.jwkKey.namespace, refs.jwkKey.name, refs.jwkKey.key)
+		if err != nil || (refs.password.name == "" && refs.passwordEnv == "" && refs.passwordFile == "") {
+			return creds, notFound, err
+		}
+		creds.Password, notFound, err = resolveProvisionerPassword(ctx, c, refs.password.namespace,
+			refs.password.name, refs.password.key, refs.passwordEnv, refs.passwordFile)
+		return creds, notFound, err
+	default:
+		creds.Password, notFound, err = resolveProvisionerPassword(ctx, c, refs.password.namespace,
+			refs.password.name, refs.password.key, refs.passwordEnv, refs.passwordFile)
+		return creds, notFound, err
+	}
+}
+
+// resolveSecretKey returns the value of a key in a Kubernetes Secret.
+//
+// The returned bool reports whether a failure is a "not found" condition, for
+// both a missing Secret and a missing key.
+func resolveSecretKey(ctx context.Context, c client.Client, namespace, name, key string) ([]byte, bool, error) {
+	var secret core.Secret
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &secret); err != nil {
+		return nil, apierrors.IsNotFound(err), fmt.Errorf("failed to retrieve provisioner secret: %w", err)
+	}
+	v, ok := secret.Data[key]
+	if !ok {
+		return nil, true, fmt.Errorf("secret %s does not contain key %s", secret.Name, key)
+	}
+	return v, false, nil
+}
+
+// resolveTLSSecret returns the certificate and private key in a Secret with
+// the layout of the kubernetes.io/tls type.
+//
+// The returned bool reports whether a failure is a "not found" condition.
+func resolveTLSSecret(ctx context.Context, c client.Client, namespace, name string) (crt, key []byte, notFound bool, err error) {
+	var secret core.Secret
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &secret); err != nil {
+		return nil, nil, apierrors.IsNotFound(err), fmt.Errorf("failed to retrieve provisioner secret: %w", err)
+	}
+	for _, k := range []string{core.TLSCertKey, core.TLSPrivateKeyKey} {
+		if _, ok := secret.Data[k]; !ok {
+			return nil, nil, true, fmt.Errorf("secret %s does not contain key %s", secret.Name, k)
+		}
+	}
+	return secret.Data[core.TLSCertKey], secret.Data[core.TLSPrivateKeyKey], false, nil
+}
+
+// resolveCASecret returns the root certificate, the CA certificate and its
+// private key in a Secret with the layout of the Secrets of cert-manager CA
+// Certificates.
+//
+// The returned bool reports whether a failure is a "not found" condition.
+func resolveCASecret(ctx context.Context, c client.Client, namespace, name string) (root, crt, key []byte, notFound bool, err error) {
+	var secret core.Secret
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &secret); err != nil {
+		return nil, nil, nil, apierrors.IsNotFound(err), fmt.Errorf("failed to retrieve embedded authority secret: %w", err)
+	}
+	for _, k := range []string{cmmeta.TLSCAKey, core.TLSCertKey, core.TLSPrivateKeyKey} {
+		if len(secret.Data[k]) == 0 {
+			return nil, nil, nil, true, fmt.Errorf("secret %s does not contain key %s", secret.Name, k)
+		}
+	}
+	return secret.Data[cmmeta.TLSCAKey], secret.Data[core.TLSCertKey], secret.Data[core.TLSPrivateKeyKey], false, nil
+}
@@ -0,0 +1,198 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"slices"
+
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/go-logr/logr"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// issuerRefField indexes the CertificateRequests by the step issuer in their
+// spec.issuerRef.
+const issuerRefField = "spec.issuerRef"
+
+// issuerRefKey returns the value of the issuerRefField index for an issuer.
+// The namespace is empty for the StepClusterIssuers.
+func issuerRefKey(kind, namespace, name string) string {
+	if kind == "StepClusterIssuer" {
+		return kind + "/" + name
+	}
+	return "StepIssuer/" + namespace + "/" + name
+}
+
+// indexIssuerRef returns the value of the issuerRefField index of a
+// CertificateRequest. The requests for other issuer groups are not indexed.
+// As in the CertificateRequest reconciler, any kind but StepClusterIssuer
+// references a StepIssuer in the namespace of the request.
+func indexIssuerRef(obj client.Object) []string {
+	cr, ok := obj.(*cmapi.CertificateRequest)
+	if !ok {
+		return nil
+	}
+	ref := cr.Spec.IssuerRef
+	if ref.Group != "" && ref.Group != api.GroupVersion.Group {
+		return nil
+	}
+	return []string{issuerRefKey(ref.Kind, cr.Namespace, ref.Name)}
+}
+
+// secretRefField indexes the issuers by the Secrets they reference, as
+// namespace/name.
+const secretRefField = "spec.secretRefs"
+
+// secretRefs collects the Secrets referenced by an issuer.
+type secretRefs []string
+
+func (s *secretRefs) add(namespace, name string) {
+	if name != "" {
+		*s = append(*s, namespace+"/"+name)
+	}
+}
+
+func (s secretRefs) values() []string {
+	slices.Sort(s)
+	return slices.Compact(s)
+}
+
+// indexStepIssuerSecretRefs returns the value of the secretRefField index of a
+// StepIssuer. All the Secrets are in the namespace of the issuer.
+func indexStepIssuerSecretRefs(obj client.Object) []string {
+	iss, ok := obj.(*api.StepIssuer)
+	if !ok {
+		return nil
+	}
+	var refs secretRefs
+	ns, p := iss.Namespace, iss.Spec.Provisioner
+	refs.add(ns, p.PasswordRef.Name)
+	if p.KeyRef != nil {
+		refs.add(ns, p.KeyRef.Name)
+	}
+	if p.PKCS11 != nil {
+		refs.add(ns, p.PKCS11.PINRef.Name)
+	}
+	if p.X5C != nil {
+		refs.add(ns, p.X5C.SecretName)
+	}
+	if t := p.TokenService; t != nil {
+		if t.BearerTokenRef != nil {
+			refs.add(ns, t.BearerTokenRef.Name)
+		}
+		refs.add(ns, t.ClientCertSecretName)
+	}
+	if p.ACME != nil {
+		refs.add(ns, p.ACME.AccountKeyRef.Name)
+		if eab := p.ACME.ExternalAccountBinding; eab != nil {
+			refs.add(ns, eab.KeySecretRef.Name)
+		}
+	}
+	if p.OIDC != nil {
+		refs.add(ns, p.OIDC.ClientSecretRef.Name)
+	}
+	if iss.Spec.Embedded != nil {
+		refs.add(ns, iss.Spec.Embedded.SecretName)
+	}
+	return refs.values()
+}
+
+// indexStepClusterIssuerSecretRefs returns the value of the secretRefField
+// index of a StepClusterIssuer. The Secrets are in the namespace set in each
+// reference.
+func indexStepClusterIssuerSecretRefs(obj client.Object) []string {
+	iss, ok := obj.(*api.StepClusterIssuer)
+	if !ok {
+		return nil
+	}
+	var refs secretRefs
+	p := iss.Spec.Provisioner
+	refs.add(p.PasswordRef.Namespace, p.PasswordRef.Name)
+	if p.KeyRef != nil {
+		refs.add(p.KeyRef.Namespace, p.KeyRef.Name)
+	}
+	if p.PKCS11 != nil {
+		refs.add(p.PKCS11.PINRef.Namespace, p.PKCS11.PINRef.Name)
+	}
+	if p.X5C != nil {
+		refs.add(p.X5C.SecretNamespace, p.X5C.SecretName)
+	}
+	if t := p.TokenService; t != nil {
+		if t.BearerTokenRef != nil {
+			refs.add(t.BearerTokenRef.Namespace, t.BearerTokenRef.Name)
+		}
+		refs.add(t.ClientCertSecretNamespace, t.ClientCertSecretName)
+	}
+	if p.ACME != nil {
+		refs.add(p.ACME.AccountKeyRef.Namespace, p.ACME.AccountKeyRef.Name)
+		if eab := p.ACME.ExternalAccountBinding; eab != nil {
+			refs.add(eab.KeySecretRef.Namespace, eab.KeySecretRef.Name)
+		}
+	}
+	if p.OIDC != nil {
+		refs.add(p.OIDC.ClientSecretRef.Namespace, p.OIDC.ClientSecretRef.Name)
+	}
+	if e := iss.Spec.Embedded; e != nil {
+		refs.add(e.SecretNamespace, e.SecretName)
+	}
+	return refs.values()
+}
+
+// issuersForSecret returns a map function that enqueues the issuers that
+// reference a Secret, listed with the given list object.
+func issuersForSecret(c client.Client, log logr.Logger, list client.ObjectList) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		l, ok := list.DeepCopyObject().(client.ObjectList)
+		if !ok {
+			return nil
+		}
+		if err := c.List(ctx, l, client.MatchingFields{secretRefField: obj.GetNamespace() + "/" + obj.GetName()}); err != nil {
+			log.Error(err, "failed to list issuers referencing Secret", "namespace", obj.GetNamespace(), "name", obj.GetName())
+			return nil
+		}
+		var requests []reconcile.Request
+		_ = meta.EachListItem(l, func(o runtime.Object) error {
+			if iss, ok := o.(client.Object); ok {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(iss)})
+			}
+			return nil
+		})
+		return requests
+	}
+}
+
+// secretDataChanged is a predicate that ignores the updates of the Secrets
+// that do not change their data.
+var secretDataChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		o, ok1 := e.ObjectOld.(*core.Secret)
+		n, ok2 := e.ObjectNew.(*core.Secret)
+		if !ok1 || !ok2 {
+			return true
+		}
+		return !reflect.DeepEqual(o.Data, n.Data)
+	},
+}
@@ -0,0 +1,147 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/go-logr/logr"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestIndexSecretRefs(t *testing.T) {
+	tests := []struct {
+		name string
+		obj  client.Object
+		want []string
+	}{
+		{name: "password", obj: &api.StepIssuer{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "jwk"},
+			Spec: api.StepIssuerSpec{Provisioner: api.StepProvisioner{
+				PasswordRef: api.StepIssuerSecretKeySelector{Name: "password"},
+				KeyRef:      &api.StepIssuerSecretKeySelector{Name: "key"},
+			}},
+		}, want: []string{"default/key", "default/password"}},
+		{name: "token service", obj: &api.StepIssuer{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "token-service"},
+			Spec: api.StepIssuerSpec{Provisioner: api.StepProvisioner{
+				TokenService: &api.StepTokenService{
+					BearerTokenRef:       &api.StepIssuerSecretKeySelector{Name: "token"},
+					ClientCertSecretName: "token",
+				},
+			}},
+		}, want: []string{"default/token"}},
+		{name: "no secrets", obj: &api.StepIssuer{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "env"},
+			Spec:       api.StepIssuerSpec{Provisioner: api.StepProvisioner{PasswordEnv: "STEP_PASSWORD"}},
+		}},
+		{name: "cluster password", obj: &api.StepClusterIssuer{
+			ObjectMeta: metav1.ObjectMeta{Name: "jwk"},
+			Spec: api.StepClusterIssuerSpec{Provisioner: api.StepClusterProvisioner{
+				PasswordRef: api.StepClusterIssuerSecretKeySelector{Namespace: "step", Name: "password"},
+			}},
+		}, want: []string{"step/password"}},
+		{name: "cluster acme", obj: &api.StepClusterIssuer{
+			ObjectMeta: metav1.ObjectMeta{Name: "acme"},
+			Spec: api.StepClusterIssuerSpec{Provisioner: api.StepClusterProvisioner{
+				ACME: &api.StepClusterACMEProvisioner{
+					AccountKeyRef: api.StepClusterIssuerSecretKeySelector{Namespace: "step", Name: "account"},
+					ExternalAccountBinding: &api.StepClusterACMEExternalAccountBinding{
+						KeySecretRef: api.StepClusterIssuerSecretKeySelector{Namespace: "other", Name: "eab"},
+					},
+				},
+			}},
+		}, want: []string{"other/eab", "step/account"}},
+		{name: "cluster embedded", obj: &api.StepClusterIssuer{
+			ObjectMeta: metav1.ObjectMeta{Name: "embedded"},
+			Spec: api.StepClusterIssuerSpec{
+				Embedded: &api.StepClusterEmbeddedAuthority{SecretNamespace: "step", SecretName: "ca"},
+			},
+		}, want: []string{"step/ca"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			switch tt.obj.(type) {
+			case *api.StepIssuer:
+				got = indexStepIssuerSecretRefs(tt.obj)
+			case *api.StepClusterIssuer:
+				got = indexStepClusterIssuerSecretRefs(tt.obj)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIssuersForSecret(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = api.AddToScheme(scheme)
+
+	newIssuer := func(namespace, name, secret string) *api.StepIssuer {
+		return &api.StepIssuer{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec: api.StepIssuerSpec{Provisioner: api.StepProvisioner{
+				PasswordRef: api.StepIssuerSecretKeySelector{Name: secret},
+			}},
+		}
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithIndex(&api.StepIssuer{}, secretRefField, indexStepIssuerSecretRefs).
+		WithObjects(
+			newIssuer("default", "first", "password"),
+			newIssuer("default", "second", "password"),
+			newIssuer("default", "other", "other-password"),
+			newIssuer("other", "first", "password"),
+		).Build()
+
+	secret := &core.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "password"}}
+	var got []string
+	for _, req := range issuersForSecret(c, logr.Discard(), &api.StepIssuerList{})(context.Background(), secret) {
+		got = append(got, req.String())
+	}
+	slices.Sort(got)
+	if want := []string{"default/first", "default/second"}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestSecretDataChanged(t *testing.T) {
+	old := &core.Secret{Data: map[string][]byte{"password": []byte("old")}}
+	relabeled := old.DeepCopy()
+	relabeled.Labels = map[string]string{"app": "step"}
+	rotated := old.DeepCopy()
+	rotated.Data["password"] = []byte("new")
+
+	if secretDataChanged.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: relabeled}) {
+		t.Error("expected updates without data changes to be ignored")
+	}
+	if !secretDataChanged.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: rotated}) {
+		t.Error("expected data changes to be accepted")
+	}
+	if !secretDataChanged.Delete(event.DeleteEvent{Object: old}) {
+		t.Error("expected deletions to be accepted")
+	}
+}
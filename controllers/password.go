@@ -21,9 +21,6 @@ import (
 	"fmt"
 	"os"
 
-	core "k8s.io/api/core/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -39,18 +36,9 @@ import (
 func resolveProvisionerPassword(ctx context.Context, c client.Client, secretNamespace, secretName, secretKey, passwordEnv, passwordFile string) (password []byte, notFound bool, err error) {
 	switch {
 	case secretName != "":
-		var secret core.Secret
-		key := types.NamespacedName{Namespace: secretNamespace, Name: secretName}
-		if err := c.Get(ctx, key, &secret); err != nil {
-			return nil, apierrors.IsNotFound(err), fmt.Errorf("failed to retrieve provisioner secret: %w", err)
-		}
 		// The Secret source is intentionally returned verbatim to preserve
 		// existing behavior.
-		v, ok := secret.Data[secretKey]
-		if !ok {
-			return nil, true, fmt.Errorf("secret %s does not contain key %s", secret.Name, secretKey)
-		}
-		return v, false, nil
+		return resolveSecretKey(ctx, c, secretNamespace, secretName, secretKey)
 	case passwordEnv != "":
 		v, ok := os.LookupEnv(passwordEnv)
 		if !ok {
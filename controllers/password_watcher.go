@@ -0,0 +1,246 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// passwordWatcherIssuer identifies an issuer with a passwordFile.
+type passwordWatcherIssuer struct {
+	kind string
+	name types.NamespacedName
+}
+
+// watchedPasswordFile is a password file and the issuers that read it.
+type watchedPasswordFile struct {
+	sum     [sha256.Size]byte
+	issuers map[passwordWatcherIssuer]struct{}
+}
+
+// PasswordWatcher watches the passwordFile of the issuers and reconciles them
+// again when the content of the file changes, e.g. when Vault Agent renders
+// it again. The directories of the files are watched instead of the files, as
+// the files are usually replaced instead of written.
+type PasswordWatcher struct {
+	log     logr.Logger
+	watcher *fsnotify.Watcher
+
+	mu      sync.Mutex
+	files   map[string]*watchedPasswordFile
+	issuers map[passwordWatcherIssuer]string
+	dirs    map[string]int
+
+	stepIssuers        chan event.GenericEvent
+	stepClusterIssuers chan event.GenericEvent
+}
+
+// NewPasswordWatcher creates a new PasswordWatcher. It must be added to the
+// manager to start watching the files.
+func NewPasswordWatcher(log logr.Logger) (*PasswordWatcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("error creating file watcher: %w", err)
+	}
+	return &PasswordWatcher{
+		log:                log,
+		watcher:            w,
+		files:              make(map[string]*watchedPasswordFile),
+		issuers:            make(map[passwordWatcherIssuer]string),
+		dirs:               make(map[string]int),
+		stepIssuers:        make(chan event.GenericEvent),
+		stepClusterIssuers: make(chan event.GenericEvent),
+	}, nil
+}
+
+// Source returns the source of the events of the issuers of the given kind
+// whose password file changed.
+func (w *PasswordWatcher) Source(kind string) source.Source {
+	ch := w.stepIssuers
+	if kind == "StepClusterIssuer" {
+		ch = w.stepClusterIssuers
+	}
+	return source.Channel(ch, &handler.EnqueueRequestForObject{})
+}
+
+// Watch watches the password file of an issuer, replacing the file watched
+// before. If path is empty the issuer is not watched anymore. It does nothing
+// if the PasswordWatcher is nil.
+func (w *PasswordWatcher) Watch(kind string, name types.NamespacedName, path string) error {
+	if w == nil {
+		return nil
+	}
+	key := passwordWatcherIssuer{kind: kind, name: name}
+	if path != "" {
+		path = filepath.Clean(path)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if old, ok := w.issuers[key]; ok {
+		if old == path {
+			return nil
+		}
+		w.forget(key, old)
+	}
+	if path == "" {
+		return nil
+	}
+
+	dir := filepath.Dir(path)
+	if w.dirs[dir] == 0 {
+		if err := w.watcher.Add(dir); err != nil {
+			return fmt.Errorf("error watching directory %s: %w", dir, err)
+		}
+	}
+	w.dirs[dir]++
+	f, ok := w.files[path]
+	if !ok {
+		f = &watchedPasswordFile{issuers: make(map[passwordWatcherIssuer]struct{})}
+		f.sum, _ = sumFile(path)
+		w.files[path] = f
+	}
+	f.issuers[key] = struct{}{}
+	w.issuers[key] = path
+	return nil
+}
+
+// Forget stops watching the password file of an issuer. It does nothing if
+// the PasswordWatcher is nil.
+func (w *PasswordWatcher) Forget(kind string, name types.NamespacedName) {
+	if w == nil {
+		return
+	}
+	key := passwordWatcherIssuer{kind: kind, name: name}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if path, ok := w.issuers[key]; ok {
+		w.forget(key, path)
+	}
+}
+
+// forget removes an issuer from a file. It must be called with the lock held.
+func (w *PasswordWatcher) forget(key passwordWatcherIssuer, path string) {
+	delete(w.issuers, key)
+	if f, ok := w.files[path]; ok {
+		delete(f.issuers, key)
+		if len(f.issuers) == 0 {
+			delete(w.files, path)
+		}
+	}
+	dir := filepath.Dir(path)
+	if w.dirs[dir]--; w.dirs[dir] <= 0 {
+		delete(w.dirs, dir)
+		_ = w.watcher.Remove(dir)
+	}
+}
+
+// Start watches the files until the context is done. It implements the
+// manager.Runnable interface.
+func (w *PasswordWatcher) Start(ctx context.Context) error {
+	defer w.watcher.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-w.watcher.Events:
+			if !ok {
+				return nil
+			}
+			for _, key := range w.changed(filepath.Dir(ev.Name)) {
+				if err := w.send(ctx, key); err != nil {
+					return nil
+				}
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return nil
+			}
+			w.log.Error(err, "failed to watch provisioner password files")
+		}
+	}
+}
+
+// changed returns the issuers of the files in a directory whose content
+// changed since they were last read.
+func (w *PasswordWatcher) changed(dir string) []passwordWatcherIssuer {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var keys []passwordWatcherIssuer
+	for path, f := range w.files {
+		if filepath.Dir(path) != dir {
+			continue
+		}
+		sum, err := sumFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			w.log.Error(err, "failed to read provisioner password file", "path", path)
+			continue
+		}
+		if sum == f.sum {
+			continue
+		}
+		f.sum = sum
+		w.log.Info("provisioner password file changed", "path", path)
+		for key := range f.issuers {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// send enqueues an issuer in its controller.
+func (w *PasswordWatcher) send(ctx context.Context, key passwordWatcherIssuer) error {
+	var obj client.Object
+	ch := w.stepIssuers
+	meta := metav1.ObjectMeta{Namespace: key.name.Namespace, Name: key.name.Name}
+	if key.kind == "StepClusterIssuer" {
+		ch = w.stepClusterIssuers
+		obj = &api.StepClusterIssuer{ObjectMeta: meta}
+	} else {
+		obj = &api.StepIssuer{ObjectMeta: meta}
+	}
+	select {
+	case ch <- event.GenericEvent{Object: obj}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sumFile returns the hash of the content of a file. A missing file has the
+// hash of the empty content, so its deletion is reported as a change.
+func sumFile(path string) ([sha256.Size]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return sha256.Sum256(nil), err
+	}
+	return sha256.Sum256(b), nil
+}
@@ -0,0 +1,117 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestPasswordWatcher(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "password")
+	if err := os.WriteFile(path, []byte("password\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewPasswordWatcher(logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := types.NamespacedName{Namespace: "default", Name: "step-issuer"}
+	clusterIssuer := types.NamespacedName{Name: "step-cluster-issuer"}
+	if err := w.Watch("StepIssuer", issuer, path); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Watch("StepClusterIssuer", clusterIssuer, path); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = w.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	// receive returns the issuers enqueued until none is enqueued for a
+	// while.
+	receive := func() []string {
+		var got []string
+		for {
+			var ev event.GenericEvent
+			select {
+			case ev = <-w.stepIssuers:
+			case ev = <-w.stepClusterIssuers:
+			case <-time.After(time.Second):
+				slices.Sort(got)
+				return got
+			}
+			got = append(got, fmt.Sprintf("%T %s", ev.Object, client.ObjectKeyFromObject(ev.Object)))
+		}
+	}
+	// The files rendered by Vault Agent are replaced, not written.
+	replace := func(content string) {
+		tmp := filepath.Join(dir, ".password.tmp")
+		if err := os.WriteFile(tmp, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replace("rotated\n")
+	if got, want := receive(), []string{"*v1beta1.StepClusterIssuer /step-cluster-issuer", "*v1beta1.StepIssuer default/step-issuer"}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// The issuers are not reconciled if the content does not change, or if
+	// they are not watched anymore.
+	w.Forget("StepClusterIssuer", clusterIssuer)
+	replace("rotated\n")
+	if got := receive(); len(got) != 0 {
+		t.Errorf("unexpected events %v", got)
+	}
+	replace("rotated again\n")
+	if got, want := receive(), []string{"*v1beta1.StepIssuer default/step-issuer"}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// The directory is not watched when no issuer reads a file in it.
+	if err := w.Watch("StepIssuer", issuer, ""); err != nil {
+		t.Fatal(err)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.files) != 0 || len(w.dirs) != 0 || len(w.issuers) != 0 {
+		t.Errorf("expected no watched files, got %v, %v, %v", w.files, w.dirs, w.issuers)
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2019 The cert-manager authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/go-logr/logr"
+	"github.com/smallstep/step-issuer/provisioners"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// +kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch
+
+// renewalOptions returns the sign options with the certificate and key stored
+// in the Secret of the Certificate that owns the CertificateRequest. No
+// options are returned if the request does not belong to a Certificate, or if
+// the Secret was not issued by the same issuer. Errors are logged and do not
+// fail the request, as the certificate can still be signed with the
+// provisioner.
+func (r *CertificateRequestReconciler) renewalOptions(ctx context.Context, log logr.Logger, cr *cmapi.CertificateRequest) []provisioners.SignOption {
+	name := cr.Annotations[cmapi.CertificateNameKey]
+	if name == "" {
+		return nil
+	}
+
+	crt := new(cmapi.Certificate)
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: cr.Namespace, Name: name}, crt); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error(err, "failed to retrieve Certificate resource for renewal", "name", name)
+		}
+		return nil
+	}
+
+	secret := new(core.Secret)
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: cr.Namespace, Name: crt.Spec.SecretName}, secret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error(err, "failed to retrieve Secret resource for renewal", "name", crt.Spec.SecretName)
+		}
+		return nil
+	}
+	if secret.Annotations[cmapi.IssuerNameAnnotationKey] != cr.Spec.IssuerRef.Name ||
+		secret.Annotations[cmapi.IssuerKindAnnotationKey] != cr.Spec.IssuerRef.Kind {
+		return nil
+	}
+
+	certPEM, keyPEM := secret.Data[core.TLSCertKey], secret.Data[core.TLSPrivateKeyKey]
+	if len(certPEM) == 0 || len(keyPEM) == 0 {
+		return nil
+	}
+	return []provisioners.SignOption{provisioners.WithPreviousCertificate(certPEM, keyPEM)}
+}
@@ -0,0 +1,344 @@
+/*
+Copyright 2019 The cert-manager authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"time"
+
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/go-logr/logr"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	"github.com/smallstep/step-issuer/provisioners"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// revocationFinalizer delays the deletion of the certificate Secrets
+	// until their certificate is revoked.
+	revocationFinalizer = "certmanager.step.sm/revocation"
+
+	// serialAnnotation is the serial number of the certificate in the Secret
+	// the last time it was reconciled. It is used to revoke the certificate
+	// when it is replaced.
+	serialAnnotation = "certmanager.step.sm/serial"
+
+	// serialIssuerAnnotation is the issuer of the certificate in the
+	// serialAnnotation, as kind/namespace/name or kind/name for the
+	// StepClusterIssuers. The certificate is revoked with this issuer when it
+	// is replaced, even if the Secret is issued by another one since.
+	serialIssuerAnnotation = "certmanager.step.sm/serial-issuer"
+
+	// revokedSerialAnnotation is the serial number of the certificate in the
+	// Secret that was revoked because its Certificate was deleted.
+	revokedSerialAnnotation = "certmanager.step.sm/revoked-serial"
+
+	// revocationDeletionTimeout is the time the deletion of a Secret waits
+	// for its certificate to be revoked. After it, the finalizer is removed
+	// without revoking the certificate, so the Secret and its namespace can
+	// be deleted if the issuer is broken for good.
+	revocationDeletionTimeout = 10 * time.Minute
+
+	// RFC 5280 reason codes used if the issuer does not configure one.
+	reasonSuperseded           = 4
+	reasonCessationOfOperation = 5
+)
+
+// RevocationReconciler revokes the certificates issued by the StepIssuers and
+// StepClusterIssuers with revocation enabled, when their Certificate or
+// Secret is deleted, or when they are replaced by a renewed certificate.
+type RevocationReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Clock    clock.Clock
+	Recorder record.EventRecorder
+}
+
+// revocationSettings are the revocation settings of a StepIssuer or a
+// StepClusterIssuer.
+type revocationSettings struct {
+	reasonCode *int
+	reason     string
+}
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch
+
+// Reconcile tracks the serial number of the certificate in a Secret issued by
+// a step issuer and revokes the certificates that are no longer used.
+func (r *RevocationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("secret", req.NamespacedName)
+
+	secret := new(core.Secret)
+	if err := r.Client.Get(ctx, req.NamespacedName, secret); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if secret.Annotations[cmapi.IssuerGroupAnnotationKey] != api.GroupVersion.Group {
+		return ctrl.Result{}, nil
+	}
+
+	settings, p, release, err := r.loadIssuer(ctx, secret.Annotations[cmapi.IssuerKindAnnotationKey], secret.Namespace,
+		secret.Annotations[cmapi.IssuerNameAnnotationKey])
+	defer release()
+	if err != nil {
+		log.Error(err, "failed to retrieve issuer resource")
+		return ctrl.Result{}, err
+	}
+	if settings == nil {
+		// Revocation is disabled or the issuer was deleted.
+		return ctrl.Result{}, r.removeFinalizer(ctx, secret)
+	}
+
+	serial := certificateSerial(secret)
+	pending := serial != "" && serial != secret.Annotations[revokedSerialAnnotation]
+
+	if !secret.DeletionTimestamp.IsZero() {
+		if pending {
+			remaining := revocationDeletionTimeout - r.Clock.Since(secret.DeletionTimestamp.Time)
+			var err error
+			if p == nil {
+				err = errors.New("provisioner not ready")
+			} else {
+				err = r.revoke(ctx, secret, p, settings, serial, reasonCessationOfOperation, "Secret deleted")
+			}
+			switch {
+			case err != nil && remaining <= 0:
+				r.Recorder.Eventf(secret, core.EventTypeWarning, "RevocationSkipped",
+					"Skipped revocation of certificate with serial number %s after waiting %s for the Secret deletion: %v", serial, revocationDeletionTimeout, err)
+			case err != nil && p == nil:
+				log.V(4).Info("provisioner not ready, waiting to revoke certificates")
+				return ctrl.Result{RequeueAfter: min(30*time.Second, remaining)}, nil
+			case err != nil:
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, r.removeFinalizer(ctx, secret)
+	}
+
+	if p == nil {
+		log.V(4).Info("provisioner not ready, waiting to revoke certificates")
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+	}
+
+	patch := client.MergeFrom(secret.DeepCopy())
+	controllerutil.AddFinalizer(secret, revocationFinalizer)
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+
+	// Revoke the previous certificate if it was replaced by a renewal.
+	if tracked := secret.Annotations[serialAnnotation]; tracked != "" && tracked != serial {
+		if result, err := r.revokeSuperseded(ctx, log, secret, tracked); err != nil || result.RequeueAfter > 0 {
+			return result, err
+		}
+	}
+
+	// Revoke the certificate if its Certificate was deleted but the Secret
+	// is kept.
+	if name := secret.Annotations[cmapi.CertificateNameKey]; name != "" && pending {
+		err := r.Client.Get(ctx, types.NamespacedName{Namespace: secret.Namespace, Name: name}, new(cmapi.Certificate))
+		switch {
+		case apierrors.IsNotFound(err):
+			if err := r.revoke(ctx, secret, p, settings, serial, reasonCessationOfOperation, "Certificate deleted"); err != nil {
+				return ctrl.Result{}, err
+			}
+			secret.Annotations[revokedSerialAnnotation] = serial
+		case err != nil:
+			log.Error(err, "failed to retrieve Certificate resource", "name", name)
+			return ctrl.Result{}, err
+		}
+	}
+
+	if serial == "" {
+		delete(secret.Annotations, serialAnnotation)
+		delete(secret.Annotations, serialIssuerAnnotation)
+	} else {
+		secret.Annotations[serialAnnotation] = serial
+		secret.Annotations[serialIssuerAnnotation] = issuerRefKey(secret.Annotations[cmapi.IssuerKindAnnotationKey], secret.Namespace,
+			secret.Annotations[cmapi.IssuerNameAnnotationKey])
+	}
+	return ctrl.Result{}, r.Client.Patch(ctx, secret, patch)
+}
+
+// revokeSuperseded revokes the previous certificate of a Secret with the
+// issuer that issued it. If that issuer was deleted or has revocation
+// disabled, the certificate is not revoked and an Event is recorded.
+func (r *RevocationReconciler) revokeSuperseded(ctx context.Context, log logr.Logger, secret *core.Secret, serial string) (ctrl.Result, error) {
+	kind, namespace, name := serialIssuer(secret)
+	settings, p, release, err := r.loadIssuer(ctx, kind, namespace, name)
+	defer release()
+	switch {
+	case err != nil:
+		log.Error(err, "failed to retrieve issuer resource of the superseded certificate")
+		return ctrl.Result{}, err
+	case settings == nil:
+		r.Recorder.Eventf(secret, core.EventTypeWarning, "RevocationSkipped",
+			"Skipped revocation of superseded certificate with serial number %s: issuer %s was deleted or has revocation disabled",
+			serial, issuerRefKey(kind, namespace, name))
+		return ctrl.Result{}, nil
+	case p == nil:
+		log.V(4).Info("provisioner of the superseded certificate not ready, waiting to revoke certificates")
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+	default:
+		return ctrl.Result{}, r.revoke(ctx, secret, p, settings, serial, reasonSuperseded, "certificate renewed")
+	}
+}
+
+// serialIssuer returns the kind, namespace and name of the issuer of the
+// certificate tracked in the Secret. The Secrets tracked before the issuer
+// was recorded use the current issuer of the Secret.
+func serialIssuer(secret *core.Secret) (kind, namespace, name string) {
+	v, ok := secret.Annotations[serialIssuerAnnotation]
+	if !ok {
+		return secret.Annotations[cmapi.IssuerKindAnnotationKey], secret.Namespace, secret.Annotations[cmapi.IssuerNameAnnotationKey]
+	}
+	kind, rest, _ := strings.Cut(v, "/")
+	if kind == "StepClusterIssuer" {
+		return kind, "", rest
+	}
+	namespace, name, _ = strings.Cut(rest, "/")
+	return kind, namespace, name
+}
+
+// loadIssuer returns the revocation settings and the provisioner of an
+// issuer, and a function to release the provisioner. The namespace is only
+// used by StepIssuers. The settings are nil if the issuer does not exist or
+// has revocation disabled, and the provisioner is nil if it is not ready.
+func (r *RevocationReconciler) loadIssuer(ctx context.Context, kind, namespace, name string) (*revocationSettings, provisioners.Signer, func(), error) {
+	noop := func() {}
+	var settings *revocationSettings
+	key := types.NamespacedName{Name: name}
+	switch kind {
+	case "StepClusterIssuer":
+		iss := new(api.StepClusterIssuer)
+		if err := r.Client.Get(ctx, key, iss); err != nil {
+			return nil, nil, noop, client.IgnoreNotFound(err)
+		}
+		if rev := iss.Spec.Revocation; rev != nil {
+			settings = &revocationSettings{reasonCode: rev.ReasonCode, reason: rev.Reason}
+		}
+	case "StepIssuer":
+		key.Namespace = namespace
+		iss := new(api.StepIssuer)
+		if err := r.Client.Get(ctx, key, iss); err != nil {
+			return nil, nil, noop, client.IgnoreNotFound(err)
+		}
+		if rev := iss.Spec.Revocation; rev != nil {
+			settings = &revocationSettings{reasonCode: rev.ReasonCode, reason: rev.Reason}
+		}
+	default:
+		return nil, nil, noop, nil
+	}
+	if settings == nil {
+		return nil, nil, noop, nil
+	}
+	p, release, ok := provisioners.Acquire(key)
+	if !ok {
+		return settings, nil, noop, nil
+	}
+	return settings, p, release, nil
+}
+
+// revoke revokes the certificate with the given serial and records the
+// outcome as an Event on the Secret. Requests rejected by the CA, or by
+// signers that cannot revoke certificates, are not retried.
+func (r *RevocationReconciler) revoke(ctx context.Context, secret *core.Secret, p provisioners.Signer, settings *revocationSettings, serial string, defaultReasonCode int, why string) error {
+	reasonCode := defaultReasonCode
+	if settings.reasonCode != nil {
+		reasonCode = *settings.reasonCode
+	}
+	reason := settings.reason
+	if reason == "" {
+		reason = why
+	}
+
+	err := provisioners.ErrRevocationNotSupported
+	if revoker, ok := p.(provisioners.Revoker); ok {
+		err = revoker.Revoke(ctx, serial, reasonCode, reason)
+	}
+	switch {
+	case err == nil:
+		r.Recorder.Eventf(secret, core.EventTypeNormal, "Revoked", "Revoked certificate with serial number %s: %s", serial, why)
+		return nil
+	case errors.Is(err, provisioners.ErrRevocationRejected), errors.Is(err, provisioners.ErrRevocationNotSupported):
+		r.Recorder.Eventf(secret, core.EventTypeWarning, "RevocationFailed", "Failed to revoke certificate with serial number %s: %v", serial, err)
+		return nil
+	default:
+		r.Recorder.Eventf(secret, core.EventTypeWarning, "RevocationFailed", "Failed to revoke certificate with serial number %s, retrying: %v", serial, err)
+		return err
+	}
+}
+
+// removeFinalizer removes the revocation finalizer from the Secret if
+// present.
+func (r *RevocationReconciler) removeFinalizer(ctx context.Context, secret *core.Secret) error {
+	if !controllerutil.ContainsFinalizer(secret, revocationFinalizer) {
+		return nil
+	}
+	patch := client.MergeFrom(secret.DeepCopy())
+	controllerutil.RemoveFinalizer(secret, revocationFinalizer)
+	return client.IgnoreNotFound(r.Client.Patch(ctx, secret, patch))
+}
+
+// certificateSerial returns the serial number, in decimal format, of the
+// certificate in the Secret, or an empty string if the Secret does not
+// contain a valid certificate.
+func certificateSerial(secret *core.Secret) string {
+	block, _ := pem.Decode(secret.Data[core.TLSCertKey])
+	if block == nil || block.Type != "CERTIFICATE" {
+		return ""
+	}
+	crt, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return ""
+	}
+	return crt.SerialNumber.String()
+}
+
+// SetupWithManager initializes the revocation controller into the controller
+// runtime. Only the Secrets issued by step issuers are reconciled, and the
+// deletion of a Certificate reconciles its Secret.
+func (r *RevocationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("revocation").
+		For(&core.Secret{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			return obj.GetAnnotations()[cmapi.IssuerGroupAnnotationKey] == api.GroupVersion.Group
+		}))).
+		Watches(&cmapi.Certificate{}, handler.EnqueueRequestsFromMapFunc(func(_ context.Context, obj client.Object) []reconcile.Request {
+			crt, ok := obj.(*cmapi.Certificate)
+			if !ok || crt.Spec.SecretName == "" {
+				return nil
+			}
+			return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: crt.Namespace, Name: crt.Spec.SecretName}}}
+		})).
+		Complete(r)
+}
@@ -0,0 +1,191 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/go-logr/logr"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	"github.com/smallstep/step-issuer/provisioners"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// newTestCertificatePEM returns a self-signed certificate with the given
+// serial number.
+func newTestCertificatePEM(t *testing.T, serial int64) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}, &x509.Certificate{SerialNumber: big.NewInt(1)}, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestRevocationReconciler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = cmapi.AddToScheme(scheme)
+	_ = api.AddToScheme(scheme)
+
+	enabled := &api.StepIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "enabled", Namespace: "default"},
+		Spec:       api.StepIssuerSpec{Revocation: &api.StepRevocation{}},
+	}
+	disabled := &api.StepIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "disabled", Namespace: "default"},
+	}
+	// The provisioner of this issuer is never loaded.
+	notReady := &api.StepIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "not-ready", Namespace: "default"},
+		Spec:       api.StepIssuerSpec{Revocation: &api.StepRevocation{}},
+	}
+	now := time.Now()
+	deleted := func(s *core.Secret, ago time.Duration) *core.Secret {
+		s.DeletionTimestamp = &metav1.Time{Time: now.Add(-ago)}
+		return s
+	}
+	crt := &cmapi.Certificate{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"},
+		Spec:       cmapi.CertificateSpec{SecretName: "example-tls"},
+	}
+	// The provisioner cannot create revocation tokens, so revocation
+	// requests fail without a CA.
+	provisioners.Store(types.NamespacedName{Namespace: "default", Name: "enabled"}, 1, new(provisioners.Step))
+
+	newSecret := func(issuer string, serial int64, annotations map[string]string, finalizers ...string) *core.Secret {
+		s := &core.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example-tls",
+				Namespace: "default",
+				Annotations: map[string]string{
+					cmapi.IssuerNameAnnotationKey:  issuer,
+					cmapi.IssuerKindAnnotationKey:  "StepIssuer",
+					cmapi.IssuerGroupAnnotationKey: api.GroupVersion.Group,
+					cmapi.CertificateNameKey:       "example",
+				},
+				Finalizers: finalizers,
+			},
+			Data: map[string][]byte{core.TLSCertKey: newTestCertificatePEM(t, serial)},
+		}
+		for k, v := range annotations {
+			s.Annotations[k] = v
+		}
+		return s
+	}
+
+	tests := []struct {
+		name          string
+		secret        *core.Secret
+		wantErr       bool
+		wantFinalizer bool
+		wantSerial    string
+		wantIssuer    string
+		wantEvent     bool
+		wantRequeue   bool
+		wantDeleted   bool
+	}{
+		{name: "track serial", secret: newSecret("enabled", 10, nil),
+			wantFinalizer: true, wantSerial: "10", wantIssuer: "StepIssuer/default/enabled"},
+		{name: "revoke superseded", secret: newSecret("enabled", 11, map[string]string{serialAnnotation: "10"}, revocationFinalizer),
+			wantFinalizer: true, wantSerial: "11", wantIssuer: "StepIssuer/default/enabled", wantEvent: true},
+		{name: "revoke superseded with its issuer", secret: newSecret("enabled", 11, map[string]string{
+			serialAnnotation: "10", serialIssuerAnnotation: "StepIssuer/default/not-ready",
+		}, revocationFinalizer), wantFinalizer: true, wantSerial: "10", wantIssuer: "StepIssuer/default/not-ready", wantRequeue: true},
+		{name: "superseded issuer deleted", secret: newSecret("enabled", 11, map[string]string{
+			serialAnnotation: "10", serialIssuerAnnotation: "StepIssuer/default/deleted",
+		}, revocationFinalizer), wantFinalizer: true, wantSerial: "11", wantIssuer: "StepIssuer/default/enabled", wantEvent: true},
+		{name: "revocation disabled", secret: newSecret("disabled", 10, map[string]string{serialAnnotation: "10"}, revocationFinalizer),
+			wantSerial: "10"},
+		{name: "issuer deleted", secret: newSecret("deleted", 10, nil, revocationFinalizer)},
+		{name: "secret deleted", secret: deleted(newSecret("enabled", 10, nil, revocationFinalizer), time.Minute),
+			wantDeleted: true, wantEvent: true},
+		{name: "secret deleted with provisioner not ready", secret: deleted(newSecret("not-ready", 10, nil, revocationFinalizer), time.Minute),
+			wantFinalizer: true, wantRequeue: true},
+		{name: "secret deleted with provisioner not ready for too long", secret: deleted(newSecret("not-ready", 10, nil, revocationFinalizer), time.Hour),
+			wantDeleted: true, wantEvent: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(enabled, disabled, notReady, crt, tt.secret).
+				Build()
+			recorder := record.NewFakeRecorder(10)
+			r := &RevocationReconciler{Client: c, Log: logr.Discard(), Clock: clocktesting.NewFakeClock(now), Recorder: recorder}
+
+			key := types.NamespacedName{Namespace: "default", Name: "example-tls"}
+			result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Reconcile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (result.RequeueAfter > 0) != tt.wantRequeue {
+				t.Errorf("unexpected result %v", result)
+			}
+			if (len(recorder.Events) > 0) != tt.wantEvent {
+				t.Errorf("unexpected events %d", len(recorder.Events))
+			}
+
+			// The Secrets being deleted are removed with their finalizer.
+			got := new(core.Secret)
+			err = c.Get(context.Background(), key, got)
+			if tt.wantDeleted {
+				if !apierrors.IsNotFound(err) {
+					t.Fatalf("expected the Secret to be deleted, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if controllerutil.ContainsFinalizer(got, revocationFinalizer) != tt.wantFinalizer {
+				t.Errorf("unexpected finalizers %v", got.Finalizers)
+			}
+			if got.Annotations[serialAnnotation] != tt.wantSerial {
+				t.Errorf("expected serial %q, got %q", tt.wantSerial, got.Annotations[serialAnnotation])
+			}
+			if got.Annotations[serialIssuerAnnotation] != tt.wantIssuer {
+				t.Errorf("expected serial issuer %q, got %q", tt.wantIssuer, got.Annotations[serialIssuerAnnotation])
+			}
+		})
+	}
+}
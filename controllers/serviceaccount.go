@@ -0,0 +1,51 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/smallstep/step-issuer/provisioners"
+	core "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// serviceAccountTokenReader returns a function that reads the token of a
+// ServiceAccount from the Secrets of type kubernetes.io/service-account-token
+// in its namespace. If the ServiceAccount has more than one, the first one by
+// name with a token is used.
+func serviceAccountTokenReader(c client.Client) provisioners.ServiceAccountTokenFunc {
+	return func(ctx context.Context, namespace, name string) (string, error) {
+		secrets := new(core.SecretList)
+		if err := c.List(ctx, secrets, client.InNamespace(namespace)); err != nil {
+			return "", fmt.Errorf("failed to list Secrets in namespace %s: %w", namespace, err)
+		}
+		sort.Slice(secrets.Items, func(i, j int) bool {
+			return secrets.Items[i].Name < secrets.Items[j].Name
+		})
+		for _, s := range secrets.Items {
+			if s.Type != core.SecretTypeServiceAccountToken || s.Annotations[core.ServiceAccountNameKey] != name {
+				continue
+			}
+			if token := s.Data[core.ServiceAccountTokenKey]; len(token) > 0 {
+				return string(token), nil
+			}
+		}
+		return "", fmt.Errorf("no Secret of type %s with a token for service account %s/%s", core.SecretTypeServiceAccountToken, namespace, name)
+	}
+}
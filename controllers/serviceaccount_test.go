@@ -0,0 +1,77 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestServiceAccountTokenReader(t *testing.T) {
+	newSecret := func(namespace, name, serviceAccount string, typ core.SecretType, token string) *core.Secret {
+		return &core.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   namespace,
+				Name:        name,
+				Annotations: map[string]string{core.ServiceAccountNameKey: serviceAccount},
+			},
+			Type: typ,
+			Data: map[string][]byte{core.ServiceAccountTokenKey: []byte(token)},
+		}
+	}
+	c := fake.NewClientBuilder().WithObjects(
+		newSecret("step", "opaque", "step-issuer", core.SecretTypeOpaque, "opaque-token"),
+		newSecret("step", "other", "other", core.SecretTypeServiceAccountToken, "other-token"),
+		newSecret("step", "step-issuer-b", "step-issuer", core.SecretTypeServiceAccountToken, "token-b"),
+		newSecret("step", "step-issuer-a", "step-issuer", core.SecretTypeServiceAccountToken, "token-a"),
+		newSecret("step", "step-issuer-0", "step-issuer", core.SecretTypeServiceAccountToken, ""),
+		newSecret("workload", "step-issuer", "step-issuer", core.SecretTypeServiceAccountToken, "workload-token"),
+	).Build()
+	read := serviceAccountTokenReader(c)
+
+	tests := []struct {
+		namespace, name string
+		want            string
+		wantErr         bool
+	}{
+		// The first Secret by name with a token is used.
+		{namespace: "step", name: "step-issuer", want: "token-a"},
+		{namespace: "workload", name: "step-issuer", want: "workload-token"},
+		{namespace: "step", name: "missing", wantErr: true},
+		{namespace: "other", name: "step-issuer", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.namespace+"/"+tt.name, func(t *testing.T) {
+			got, err := read(context.Background(), tt.namespace, tt.name)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
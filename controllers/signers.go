@@ -0,0 +1,203 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	"github.com/smallstep/step-issuer/provisioners"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// signerOptions are the options of the controller used to create the signers
+// of the issuers.
+type signerOptions struct {
+	memoryCA         bool
+	acmeHTTP01Solver bool
+}
+
+// signerError is an error creating the signer of an issuer, with the reason
+// and the message of the Ready condition of the issuer.
+type signerError struct {
+	reason  string
+	message string
+	err     error
+}
+
+func (e *signerError) Error() string {
+	return fmt.Sprintf("%s: %v", e.message, e.err)
+}
+
+func (e *signerError) Unwrap() error {
+	return e.err
+}
+
+// signerErrorReason returns the reason of the Ready condition of an issuer
+// whose signer could not be created.
+func signerErrorReason(err error) string {
+	var e *signerError
+	if errors.As(err, &e) {
+		return e.reason
+	}
+	return "Error"
+}
+
+// issuerSigner holds what newSigner needs from an issuer of any kind.
+type issuerSigner struct {
+	key        types.NamespacedName
+	generation int64
+	caBundle   []byte
+	acme       bool
+	pkcs11     bool
+
+	// validate validates the spec of the issuer.
+	validate func() error
+	// resolve returns the credentials of the provisioner and whether a
+	// failure is a "not found" condition.
+	resolve func() (provisioners.Credentials, bool, error)
+	// build creates the provisioner with the caBundle in PEM format.
+	build func(caBundle []byte, creds provisioners.Credentials) (*provisioners.Step, error)
+}
+
+// newStepIssuerSigner validates a StepIssuer and creates its signer. It is
+// used by the StepIssuer reconciler and by the CertificateRequests reconciled
+// before their issuer, e.g. after a restart or a change of leader. The running
+// signer is kept while the issuer and its credentials do not change. It also
+// returns the credentials of the provisioner, and the errors are
+// *signerError.
+func newStepIssuerSigner(ctx context.Context, c client.Client, iss *api.StepIssuer, opts signerOptions) (provisioners.Signer, provisioners.Credentials, error) {
+	return newSigner(opts, issuerSigner{
+		key:        types.NamespacedName{Namespace: iss.Namespace, Name: iss.Name},
+		generation: iss.Generation,
+		caBundle:   iss.Spec.CABundle,
+		acme:       iss.Spec.Provisioner.ACME != nil,
+		pkcs11:     iss.Spec.Provisioner.PKCS11 != nil,
+		validate:   func() error { return validateStepIssuerSpec(iss.Spec) },
+		resolve: func() (provisioners.Credentials, bool, error) {
+			return resolveStepIssuerCredentials(ctx, c, iss)
+		},
+		build: func(caBundle []byte, creds provisioners.Credentials) (*provisioners.Step, error) {
+			iss := iss.DeepCopy()
+			iss.Spec.CABundle = caBundle
+			return provisioners.NewFromStepIssuer(ctx, iss, creds)
+		},
+	})
+}
+
+// newStepClusterIssuerSigner validates a StepClusterIssuer and creates its
+// signer, as newStepIssuerSigner does for a StepIssuer.
+func newStepClusterIssuerSigner(ctx context.Context, c client.Client, iss *api.StepClusterIssuer, opts signerOptions) (provisioners.Signer, provisioners.Credentials, error) {
+	return newSigner(opts, issuerSigner{
+		key:        types.NamespacedName{Name: iss.Name},
+		generation: iss.Generation,
+		caBundle:   iss.Spec.CABundle,
+		acme:       iss.Spec.Provisioner.ACME != nil,
+		pkcs11:     iss.Spec.Provisioner.PKCS11 != nil,
+		validate:   func() error { return validateStepClusterIssuerSpec(iss.Spec) },
+		resolve: func() (provisioners.Credentials, bool, error) {
+			return resolveStepClusterIssuerCredentials(ctx, c, iss)
+		},
+		build: func(caBundle []byte, creds provisioners.Credentials) (*provisioners.Step, error) {
+			iss := iss.DeepCopy()
+			iss.Spec.CABundle = caBundle
+			return provisioners.NewFromStepClusterIssuer(ctx, iss, creds)
+		},
+	})
+}
+
+// newSigner validates an issuer of any kind and creates its signer.
+func newSigner(opts signerOptions, iss issuerSigner) (provisioners.Signer, provisioners.Credentials, error) {
+	if err := iss.validate(); err != nil {
+		return nil, provisioners.Credentials{}, &signerError{reason: "Validation", message: "Failed to validate resource", err: err}
+	}
+	if opts.memoryCA {
+		p, err := loadOrCreateMemoryCA(iss.key)
+		return p, provisioners.Credentials{}, err
+	}
+	if err := validateProvisionerSupport(iss.acme, opts.acmeHTTP01Solver, iss.pkcs11, provisioners.PKCS11Supported); err != nil {
+		return nil, provisioners.Credentials{}, &signerError{reason: "Validation", message: "Failed to validate resource", err: err}
+	}
+
+	// The JWK provisioner password comes from the configured source: a
+	// Kubernetes Secret, an environment variable, or a file on the
+	// controller's filesystem.
+	creds, notFound, err := iss.resolve()
+	if err != nil {
+		return nil, creds, credentialsError(err, notFound)
+	}
+
+	caBundle, err := pemCABundle(iss.caBundle)
+	if err != nil {
+		return nil, creds, &signerError{reason: "Validation", message: "Failed to parse caBundle", err: err}
+	}
+	// The signer is kept while the issuer and its credentials do not change,
+	// e.g. when the issuers with a passwordEnv are reconciled again.
+	if p, ok := provisioners.LoadUnchanged(iss.key, iss.generation, creds); ok {
+		return p, creds, nil
+	}
+	p, err := iss.build(caBundle, creds)
+	if err != nil {
+		return nil, creds, provisionerError(err)
+	}
+	return p, creds, nil
+}
+
+// loadOrCreateMemoryCA returns the in-memory CA of an issuer. The CA is
+// created once and kept while the controller runs, so its root does not
+// change every time the issuer is reconciled.
+func loadOrCreateMemoryCA(key types.NamespacedName) (provisioners.Signer, error) {
+	if p, ok := provisioners.Load(key); ok {
+		return p, nil
+	}
+	ca, err := provisioners.NewMemoryCA(key.Name)
+	if err != nil {
+		return nil, &signerError{reason: "Error", message: "Failed to create in-memory CA", err: err}
+	}
+	return ca, nil
+}
+
+// pemCABundle returns the caBundle of an issuer in PEM format, converting it
+// from DER if necessary.
+func pemCABundle(caBundle []byte) ([]byte, error) {
+	if len(caBundle) == 0 || isPEMFormat(caBundle) {
+		return caBundle, nil
+	}
+	return convertToPemFormat(caBundle)
+}
+
+// credentialsError returns the error of the credentials of a provisioner that
+// could not be retrieved.
+func credentialsError(err error, notFound bool) error {
+	reason := "Error"
+	if notFound {
+		reason = "NotFound"
+	}
+	return &signerError{reason: reason, message: "Failed to retrieve provisioner credentials", err: err}
+}
+
+// provisionerError returns the error of a provisioner that could not be
+// initialized.
+func provisionerError(err error) error {
+	reason := "Error"
+	if errors.Is(err, provisioners.ErrKeyIDMismatch) {
+		reason = "KeyIDMismatch"
+	}
+	return &signerError{reason: reason, message: "Failed to initialize provisioner", err: err}
+}
@@ -0,0 +1,82 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNewStepIssuerSigner(t *testing.T) {
+	jwk := api.StepProvisioner{
+		Name:        "admin",
+		KeyID:       "kid",
+		PasswordRef: api.StepIssuerSecretKeySelector{Name: "missing", Key: "password"},
+	}
+	acme := api.StepProvisioner{
+		Name: "acme",
+		ACME: &api.StepACMEProvisioner{AccountKeyRef: api.StepIssuerSecretKeySelector{Name: "missing", Key: "key"}},
+	}
+	c := fake.NewClientBuilder().Build()
+
+	tests := []struct {
+		name        string
+		provisioner api.StepProvisioner
+		opts        signerOptions
+		wantReason  string
+	}{
+		// The in-memory CA does not need the provisioner credentials, but the
+		// issuer is still validated.
+		{name: "memory CA", provisioner: jwk, opts: signerOptions{memoryCA: true}},
+		{name: "memory CA without kid", provisioner: api.StepProvisioner{Name: "admin"}, opts: signerOptions{memoryCA: true}, wantReason: "Validation"},
+		{name: "without kid", provisioner: api.StepProvisioner{Name: "admin"}, wantReason: "Validation"},
+		{name: "acme without solver", provisioner: acme, wantReason: "Validation"},
+		{name: "acme without account key", provisioner: acme, opts: signerOptions{acmeHTTP01Solver: true}, wantReason: "NotFound"},
+		{name: "without password", provisioner: jwk, wantReason: "NotFound"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iss := &api.StepIssuer{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "issuer"},
+				Spec: api.StepIssuerSpec{
+					URL:         "https://ca.example.com",
+					CABundle:    []byte("bundle"),
+					Provisioner: tt.provisioner,
+				},
+			}
+			p, _, err := newStepIssuerSigner(context.Background(), c, iss, tt.opts)
+			if tt.wantReason == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if p == nil {
+					t.Fatal("expected a signer, got nil")
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+			if got := signerErrorReason(err); got != tt.wantReason {
+				t.Errorf("expected reason %q, got %q: %v", tt.wantReason, got, err)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/go-logr/logr"
 	api "github.com/smallstep/step-issuer/api/v1beta1"
+	"github.com/smallstep/step-issuer/provisioners"
 	core "k8s.io/api/core/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -88,3 +89,38 @@ func (r *stepStatusClusterReconciler) setCondition(status api.ConditionStatus, r
 	r.issuer.Status.Conditions = append(r.issuer.Status.Conditions, c)
 	r.logger.Info("setting lastTransitionTime for StepIssuer condition", "condition", api.ConditionReady, "time", now.Time)
 }
+
+// setPasswordLoaded records on the given api.StepClusterIssuer resource that the
+// provisioner password was loaded now.
+func (r *stepStatusClusterReconciler) setPasswordLoaded() {
+	now := meta.NewTime(r.Clock.Now())
+	r.issuer.Status.PasswordLastLoadedTime = &now
+}
+
+// setProvisionerClaims sets the claims of the provisioner on the given
+// api.StepClusterIssuer resource, or removes them if they are nil.
+func (r *stepStatusClusterReconciler) setProvisionerClaims(c *provisioners.ProvisionerClaims) {
+	if c == nil {
+		r.issuer.Status.Provisioner = nil
+		return
+	}
+	now := meta.NewTime(r.Clock.Now())
+	status := &api.StepClusterProvisionerStatus{
+		Name:                    c.Name,
+		Type:                    c.Type,
+		DisableRenewal:          c.DisableRenewal,
+		AllowRenewalAfterExpiry: c.AllowRenewalAfterExpiry,
+		Template:                c.Template,
+		LastUpdateTime:          &now,
+	}
+	if c.MinDuration > 0 {
+		status.MinTLSCertDuration = &meta.Duration{Duration: c.MinDuration}
+	}
+	if c.MaxDuration > 0 {
+		status.MaxTLSCertDuration = &meta.Duration{Duration: c.MaxDuration}
+	}
+	if c.DefaultDuration > 0 {
+		status.DefaultTLSCertDuration = &meta.Duration{Duration: c.DefaultDuration}
+	}
+	r.issuer.Status.Provisioner = status
+}
@@ -18,14 +18,20 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
 	api "github.com/smallstep/step-issuer/api/v1beta1"
 	"github.com/smallstep/step-issuer/provisioners"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
 // StepClusterIssuerReconciler reconciles a StepClusterIssuer object
@@ -34,6 +40,25 @@ type StepClusterIssuerReconciler struct {
 	Log      logr.Logger
 	Clock    clock.Clock
 	Recorder record.EventRecorder
+
+	// MemoryCA signs the certificates with an in-memory CA instead of the
+	// step certificates server in the spec. For development clusters and
+	// tests only.
+	MemoryCA bool
+
+	// PasswordWatcher reconciles the issuers again when their passwordFile
+	// changes. It is optional.
+	PasswordWatcher *PasswordWatcher
+
+	// ACMEHTTP01Solver reports whether the controller answers the http-01
+	// challenges of ACME provisioners. The issuers with an ACME provisioner
+	// are not ready without it.
+	ACMEHTTP01Solver bool
+
+	// PasswordEnvResyncPeriod is the period to reconcile again the issuers
+	// with a passwordEnv. Their signer is kept while their credentials do
+	// not change. It is disabled if zero.
+	PasswordEnvResyncPeriod time.Duration
 }
 
 // +kubebuilder:rbac:groups=certmanager.step.sm,resources=stepclusterissuers,verbs=get;list;watch;create;update;patch;delete
@@ -49,73 +74,267 @@ func (r *StepClusterIssuerReconciler) Reconcile(ctx context.Context, req ctrl.Re
 
 	iss := new(api.StepClusterIssuer)
 	if err := r.Client.Get(ctx, req.NamespacedName, iss); err != nil {
+		if apierrors.IsNotFound(err) {
+			// The issuer was deleted, its provisioner cannot sign more
+			// certificates.
+			provisioners.Delete(req.NamespacedName)
+			r.PasswordWatcher.Forget("StepClusterIssuer", req.NamespacedName)
+			return ctrl.Result{}, nil
+		}
 		log.Error(err, "failed to retrieve StepClusterIssuer resource")
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+		return ctrl.Result{}, err
 	}
 
 	statusReconciler := newStepStatusClusterReconciler(r, iss, log)
-	if err := validateStepClusterIssuerSpec(iss.Spec); err != nil {
-		log.Error(err, "failed to validate StepClusterIssuer resource")
-		statusReconciler.UpdateNoError(ctx, api.ConditionFalse, "Validation", "Failed to validate resource: %v", err)
-		return ctrl.Result{}, err
+
+	// Watch the password file, if any, to reconcile the issuer again when
+	// it changes.
+	if err := r.PasswordWatcher.Watch("StepClusterIssuer", req.NamespacedName, iss.Spec.Provisioner.PasswordFile); err != nil {
+		log.Error(err, "failed to watch provisioner password file", "path", iss.Spec.Provisioner.PasswordFile)
 	}
 
-	// Fetch the provisioner password from the configured source: a Kubernetes
-	// Secret, an environment variable, or a file on the controller's filesystem.
-	password, notFound, err := resolveProvisionerPassword(ctx, r.Client, iss.Spec.Provisioner.PasswordRef.Namespace,
-		iss.Spec.Provisioner.PasswordRef.Name, iss.Spec.Provisioner.PasswordRef.Key,
-		iss.Spec.Provisioner.PasswordEnv, iss.Spec.Provisioner.PasswordFile)
+	// Validate the issuer and initialize and store its signer. The running
+	// signer is kept while the issuer and its credentials do not change.
+	p, creds, err := newStepClusterIssuerSigner(ctx, r.Client, iss, r.signerOptions())
 	if err != nil {
-		log.Error(err, "failed to retrieve StepClusterIssuer provisioner password", "name", req.Name)
-		reason := "Error"
-		if notFound {
-			reason = "NotFound"
-		}
-		statusReconciler.UpdateNoError(ctx, api.ConditionFalse, reason, "Failed to retrieve provisioner password: %v", err)
+		log.Error(err, "failed to initialize StepClusterIssuer signer")
+		provisioners.Delete(req.NamespacedName)
+		statusReconciler.UpdateNoError(ctx, api.ConditionFalse, signerErrorReason(err), "%v", err)
 		return ctrl.Result{}, err
 	}
-
-	//Verify that the CABundle is in x509 PEM format If not then covert it over
-	//to PEM x509 format.
-	if !isPEMFormat(iss.Spec.CABundle) {
-		caBundle, err := convertToPemFormat(iss.Spec.CABundle)
-		if err != nil {
-			log.Error(err, "failed to parse caBundle in the StepClusterIssuer spec", "name", req.Name)
-		}
-		iss.Spec.CABundle = caBundle
+	if len(creds.Password) > 0 {
+		statusReconciler.setPasswordLoaded()
+	}
+	provisioners.Store(req.NamespacedName, iss.Generation, p)
+	if r.MemoryCA {
+		return ctrl.Result{}, statusReconciler.Update(ctx, api.ConditionTrue, "Verified", "StepClusterIssuer ready to sign certificates with an in-memory CA")
 	}
 
-	// Initialize and store the provisioner
-	p, err := provisioners.NewFromStepClusterIssuer(iss, password)
+	// Publish the claims of the provisioner, the CertificateRequests are
+	// validated against them before they are sent to the CA. If the CA does
+	// not return them, they are read again later and the requests that need
+	// them wait until then. The signers other than the in-memory CA are
+	// *provisioners.Step.
+	var result ctrl.Result
+	claims, err := p.(*provisioners.Step).ProvisionerClaims(ctx)
 	if err != nil {
-		log.Error(err, "failed to initialize provisioner")
-		statusReconciler.UpdateNoError(ctx, api.ConditionFalse, "Error", "failed initialize provisioner")
-		return ctrl.Result{}, err
+		log.Error(err, "failed to read provisioner claims")
+		result.RequeueAfter = provisionerClaimsRetryPeriod
+	}
+	statusReconciler.setProvisionerClaims(claims)
+
+	if iss.Spec.Embedded != nil {
+		return result, statusReconciler.Update(ctx, api.ConditionTrue, "Embedded",
+			"StepClusterIssuer ready to sign certificates with an embedded step certificates authority, not for production use")
+	}
+	// The environment variables cannot be watched, the issuers with a
+	// passwordEnv are reconciled again periodically if configured. Their
+	// signer is not created again unless their credentials changed.
+	if iss.Spec.Provisioner.PasswordEnv != "" && r.PasswordEnvResyncPeriod > 0 &&
+		(result.RequeueAfter == 0 || r.PasswordEnvResyncPeriod < result.RequeueAfter) {
+		result.RequeueAfter = r.PasswordEnvResyncPeriod
 	}
-	provisioners.Store(req.NamespacedName, p)
+	return result, statusReconciler.Update(ctx, api.ConditionTrue, "Verified", "StepClusterIssuer verified and ready to sign certificates")
+}
 
-	return ctrl.Result{}, statusReconciler.Update(ctx, api.ConditionTrue, "Verified", "StepClusterIssuer verified and ready to sign certificates")
+// signerOptions returns the options used to create the signers of the
+// StepClusterIssuers.
+func (r *StepClusterIssuerReconciler) signerOptions() signerOptions {
+	return signerOptions{memoryCA: r.MemoryCA, acmeHTTP01Solver: r.ACMEHTTP01Solver}
 }
 
-// SetupWithManager initializes the StepClusterIssuer controller into the controller
-// runtime.
+// SetupWithManager initializes the StepClusterIssuer controller into the
+// controller runtime. The StepClusterIssuers are reconciled again when the data
+// of a Secret they reference changes, so a rotated password or key is
+// verified and the Ready condition updated, and when their passwordFile
+// changes. The updates that do not change their spec, e.g. of their status,
+// are ignored.
 func (r *StepClusterIssuerReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&api.StepClusterIssuer{}).
-		Complete(r)
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &api.StepClusterIssuer{}, secretRefField, indexStepClusterIssuerSecretRefs); err != nil {
+		return err
+	}
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&api.StepClusterIssuer{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&core.Secret{}, handler.EnqueueRequestsFromMapFunc(issuersForSecret(r.Client, r.Log, &api.StepClusterIssuerList{})),
+			builder.WithPredicates(secretDataChanged))
+	if r.PasswordWatcher != nil {
+		b = b.WatchesRawSource(r.PasswordWatcher.Source("StepClusterIssuer"))
+	}
+	return b.Complete(r)
 }
 
 func validateStepClusterIssuerSpec(s api.StepClusterIssuerSpec) error {
 	switch {
-	case s.URL == "":
+	case s.Embedded == nil && s.URL == "":
 		return fmt.Errorf("spec.url cannot be empty")
-	case len(s.CABundle) == 0:
+	case s.Embedded == nil && len(s.CABundle) == 0:
 		return fmt.Errorf("spec.caBundle cannot be empty")
 	case s.Provisioner.Name == "":
 		return fmt.Errorf("spec.provisioner.name cannot be empty")
-	case s.Provisioner.KeyID == "":
+	}
+
+	p := s.Provisioner
+	if err := validateSingleProvisionerType(map[string]bool{
+		"oidc":         p.OIDC != nil,
+		"x5c":          p.X5C != nil,
+		"k8sSA":        p.K8sSA != nil,
+		"acme":         p.ACME != nil,
+		"pkcs11":       p.PKCS11 != nil,
+		"tokenService": p.TokenService != nil,
+	}); err != nil {
+		return err
+	}
+
+	if s.Revocation != nil {
+		var unsupportedType string
+		switch {
+		case p.OIDC != nil:
+			unsupportedType = "oidc"
+		case p.K8sSA != nil:
+			unsupportedType = "k8sSA"
+		case p.ACME != nil:
+			unsupportedType = "acme"
+		case p.TokenService != nil:
+			unsupportedType = "tokenService"
+		}
+		if err := validateRevocation(unsupportedType, s.Revocation.ReasonCode); err != nil {
+			return err
+		}
+	}
+
+	if pol := s.Policy; pol != nil {
+		minSizes := make([]int, len(pol.KeyAlgorithms))
+		for i, k := range pol.KeyAlgorithms {
+			minSizes[i] = k.MinSize
+		}
+		if err := validatePolicy(pol.DNSNames, pol.IPRanges, pol.URIPrefixes, pol.EmailDomains, minSizes, pol.MaxDuration); err != nil {
+			return err
+		}
+	}
+
+	if st := s.SubjectStrategy; st != nil {
+		var unsupportedType string
+		switch {
+		case p.OIDC != nil:
+			unsupportedType = "oidc"
+		case p.K8sSA != nil:
+			unsupportedType = "k8sSA"
+		case p.ACME != nil:
+			unsupportedType = "acme"
+		}
+		if err := validateSubjectStrategy(unsupportedType, st.Type == api.SubjectStrategyTemplate, st.Template); err != nil {
+			return err
+		}
+	}
+
+	if n := s.SubjectAltNames; n != nil {
+		var unsupportedType string
+		switch {
+		case s.Embedded != nil:
+			unsupportedType = "spec.embedded"
+		case p.OIDC != nil:
+			unsupportedType = "spec.provisioner.oidc"
+		case p.K8sSA != nil:
+			unsupportedType = "spec.provisioner.k8sSA"
+		case p.ACME != nil:
+			unsupportedType = "spec.provisioner.acme"
+		case p.X5C != nil:
+			unsupportedType = "spec.provisioner.x5c"
+		case p.PKCS11 != nil:
+			unsupportedType = "spec.provisioner.pkcs11"
+		case p.TokenService == nil:
+			unsupportedType = "a JWK provisioner"
+		}
+		if err := validateSubjectAltNames(unsupportedType, n.Mode == api.SANModeReject); err != nil {
+			return err
+		}
+	}
+
+	if v := s.Validity; v != nil {
+		if err := validateValidity(v.DefaultDuration, v.MinDuration, v.MaxDuration, v.Backdate, p.ACME != nil); err != nil {
+			return err
+		}
+	}
+
+	// The StepClusterIssuers have no namespace, the Secrets they reference
+	// must set one.
+	if err := validateSecretNamespaces(map[string]bool{
+		"spec.embedded.secretNamespace":          s.Embedded != nil && s.Embedded.SecretName != "" && s.Embedded.SecretNamespace == "",
+		"spec.provisioner.passwordRef.namespace": p.PasswordRef.Name != "" && p.PasswordRef.Namespace == "",
+		"spec.provisioner.keyRef.namespace":      p.KeyRef != nil && p.KeyRef.Name != "" && p.KeyRef.Namespace == "",
+		"spec.provisioner.pkcs11.pinRef.namespace": p.PKCS11 != nil && p.PKCS11.PINRef.Name != "" &&
+			p.PKCS11.PINRef.Namespace == "",
+		"spec.provisioner.oidc.clientSecretRef.namespace": p.OIDC != nil && p.OIDC.ClientSecretRef.Name != "" &&
+			p.OIDC.ClientSecretRef.Namespace == "",
+		"spec.provisioner.x5c.secretNamespace": p.X5C != nil && p.X5C.SecretName != "" && p.X5C.SecretNamespace == "",
+		"spec.provisioner.acme.accountKeyRef.namespace": p.ACME != nil && p.ACME.AccountKeyRef.Name != "" &&
+			p.ACME.AccountKeyRef.Namespace == "",
+		"spec.provisioner.acme.externalAccountBinding.keySecretRef.namespace": p.ACME != nil && p.ACME.ExternalAccountBinding != nil &&
+			p.ACME.ExternalAccountBinding.KeySecretRef.Name != "" && p.ACME.ExternalAccountBinding.KeySecretRef.Namespace == "",
+		"spec.provisioner.tokenService.bearerTokenRef.namespace": p.TokenService != nil && p.TokenService.BearerTokenRef != nil &&
+			p.TokenService.BearerTokenRef.Name != "" && p.TokenService.BearerTokenRef.Namespace == "",
+		"spec.provisioner.tokenService.clientCertSecretNamespace": p.TokenService != nil && p.TokenService.ClientCertSecretName != "" &&
+			p.TokenService.ClientCertSecretNamespace == "",
+	}); err != nil {
+		return err
+	}
+
+	if s.Embedded != nil {
+		credentials := p.KeyID != "" || p.PasswordRef.Name != "" || p.PasswordEnv != "" || p.PasswordFile != "" || p.KeyRef != nil ||
+			p.PKCS11 != nil || p.OIDC != nil || p.X5C != nil || p.K8sSA != nil || p.ACME != nil || p.TokenService != nil
+		return validateEmbeddedAuthority(s.URL, len(s.CABundle) > 0, credentials, s.Embedded.SecretName)
+	}
+
+	switch {
+	case p.OIDC != nil:
+		if err := validateJWKUnset("oidc", p.KeyID, p.PasswordRef.Name, p.PasswordEnv, p.PasswordFile, p.KeyRef != nil); err != nil {
+			return err
+		}
+		return validateOIDCProvisioner(p.OIDC.IssuerURL, p.OIDC.ClientID, p.OIDC.ClientSecretRef.Name, p.OIDC.ClientSecretRef.Key)
+	case p.X5C != nil:
+		if err := validateJWKUnset("x5c", p.KeyID, p.PasswordRef.Name, p.PasswordEnv, p.PasswordFile, p.KeyRef != nil); err != nil {
+			return err
+		}
+		return validateX5CProvisioner(p.X5C.SecretName)
+	case p.K8sSA != nil:
+		if err := validateJWKUnset("k8sSA", p.KeyID, p.PasswordRef.Name, p.PasswordEnv, p.PasswordFile, p.KeyRef != nil); err != nil {
+			return err
+		}
+		if !p.K8sSA.UseRequestNamespace && p.K8sSA.ServiceAccountNamespace == "" {
+			return fmt.Errorf("spec.provisioner.k8sSA.serviceAccountNamespace cannot be empty unless useRequestNamespace is set")
+		}
+		return validateK8sSAProvisioner(p.K8sSA.ServiceAccountName)
+	case p.ACME != nil:
+		if err := validateJWKUnset("acme", p.KeyID, p.PasswordRef.Name, p.PasswordEnv, p.PasswordFile, p.KeyRef != nil); err != nil {
+			return err
+		}
+		var eabKeyID, eabSecretName, eabSecretKey string
+		if eab := p.ACME.ExternalAccountBinding; eab != nil {
+			eabKeyID, eabSecretName, eabSecretKey = eab.KeyID, eab.KeySecretRef.Name, eab.KeySecretRef.Key
+		}
+		return validateACMEProvisioner(p.ACME.AccountKeyRef.Name, p.ACME.AccountKeyRef.Key, p.ACME.ExternalAccountBinding != nil,
+			eabKeyID, eabSecretName, eabSecretKey)
+	case p.TokenService != nil:
+		if err := validateJWKUnset("tokenService", p.KeyID, p.PasswordRef.Name, p.PasswordEnv, p.PasswordFile, p.KeyRef != nil); err != nil {
+			return err
+		}
+		var bearerTokenName, bearerTokenKey string
+		if ref := p.TokenService.BearerTokenRef; ref != nil {
+			bearerTokenName, bearerTokenKey = ref.Name, ref.Key
+		}
+		return validateTokenService(p.TokenService.URL, p.TokenService.BearerTokenRef != nil, bearerTokenName, bearerTokenKey,
+			p.TokenService.ClientCertSecretName)
+	case p.KeyID == "":
 		return fmt.Errorf("spec.provisioner.kid cannot be empty")
+	case p.PKCS11 != nil:
+		if err := validateJWKUnset("pkcs11", "", p.PasswordRef.Name, p.PasswordEnv, p.PasswordFile, p.KeyRef != nil); err != nil {
+			return err
+		}
+		return validatePKCS11Key(p.PKCS11.ModulePath, p.PKCS11.TokenLabel, p.PKCS11.SlotID, p.PKCS11.KeyLabel,
+			p.PKCS11.PINRef.Name, p.PKCS11.PINRef.Key)
+	case p.KeyRef != nil:
+		return validateJWKKeyRef(p.KeyRef.Name, p.KeyRef.Key, p.PasswordRef.Name, p.PasswordRef.Key, p.PasswordEnv, p.PasswordFile)
 	default:
-		return validateProvisionerPasswordSource(s.Provisioner.PasswordRef.Name, s.Provisioner.PasswordRef.Key, s.Provisioner.PasswordEnv, s.Provisioner.PasswordFile)
+		return validateProvisionerPasswordSource(p.PasswordRef.Name, p.PasswordRef.Key, p.PasswordEnv, p.PasswordFile)
 	}
 }
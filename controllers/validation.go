@@ -0,0 +1,332 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/smallstep/step-issuer/provisioners"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// validateSingleProvisionerType ensures that at most one provisioner type
+// other than JWK is set. The map keys are the spec field names of each type
+// and the values report whether they are set.
+func validateSingleProvisionerType(types map[string]bool) error {
+	var set []string
+	for name, ok := range types {
+		if ok {
+			set = append(set, "spec.provisioner."+name)
+		}
+	}
+	if len(set) > 1 {
+		sort.Strings(set)
+		return fmt.Errorf("only one of %s may be set", strings.Join(set, ", "))
+	}
+	return nil
+}
+
+// validateSecretNamespaces ensures that the Secrets referenced by a
+// StepClusterIssuer set their namespace. The keys of missing are the namespace
+// fields, and the values are true if a Secret is referenced without one.
+func validateSecretNamespaces(missing map[string]bool) error {
+	var fields []string
+	for field, ok := range missing {
+		if ok {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) > 0 {
+		sort.Strings(fields)
+		return fmt.Errorf("%s cannot be empty", strings.Join(fields, ", "))
+	}
+	return nil
+}
+
+// validateJWKUnset ensures that none of the JWK provisioner fields are set when
+// the provisioner uses a different type.
+func validateJWKUnset(provisionerType, kid, secretName, passwordEnv, passwordFile string, keyRef bool) error {
+	switch {
+	case kid != "":
+		return fmt.Errorf("spec.provisioner.kid cannot be set with spec.provisioner.%s", provisionerType)
+	case keyRef:
+		return fmt.Errorf("spec.provisioner.keyRef cannot be set with spec.provisioner.%s", provisionerType)
+	case secretName != "" || passwordEnv != "" || passwordFile != "":
+		return fmt.Errorf("a provisioner password cannot be set with spec.provisioner.%s", provisionerType)
+	default:
+		return nil
+	}
+}
+
+// validateJWKKeyRef ensures that the reference to a JWK provisioner key is
+// complete. The password is optional because the key might not be encrypted,
+// but if set, it must be valid.
+func validateJWKKeyRef(keyName, keyKey, secretName, secretKey, passwordEnv, passwordFile string) error {
+	switch {
+	case keyName == "":
+		return fmt.Errorf("spec.provisioner.keyRef.name cannot be empty")
+	case keyKey == "":
+		return fmt.Errorf("spec.provisioner.keyRef.key cannot be empty")
+	case secretName == "" && passwordEnv == "" && passwordFile == "":
+		return nil
+	default:
+		return validateProvisionerPasswordSource(secretName, secretKey, passwordEnv, passwordFile)
+	}
+}
+
+// validatePKCS11Key ensures that the location of a JWK provisioner key in a
+// PKCS#11 token is complete.
+func validatePKCS11Key(modulePath, tokenLabel string, slotID *int, keyLabel, pinName, pinKey string) error {
+	switch {
+	case modulePath == "":
+		return fmt.Errorf("spec.provisioner.pkcs11.modulePath cannot be empty")
+	case tokenLabel == "" && slotID == nil:
+		return fmt.Errorf("one of spec.provisioner.pkcs11.tokenLabel or spec.provisioner.pkcs11.slotID must be set")
+	case tokenLabel != "" && slotID != nil:
+		return fmt.Errorf("only one of spec.provisioner.pkcs11.tokenLabel or spec.provisioner.pkcs11.slotID may be set")
+	case keyLabel == "":
+		return fmt.Errorf("spec.provisioner.pkcs11.keyLabel cannot be empty")
+	case pinName == "":
+		return fmt.Errorf("spec.provisioner.pkcs11.pinRef.name cannot be empty")
+	case pinKey == "":
+		return fmt.Errorf("spec.provisioner.pkcs11.pinRef.key cannot be empty")
+	default:
+		return nil
+	}
+}
+
+// validateOIDCProvisioner ensures that the OIDC provisioner configuration is
+// complete.
+func validateOIDCProvisioner(issuerURL, clientID, secretName, secretKey string) error {
+	switch {
+	case issuerURL == "":
+		return fmt.Errorf("spec.provisioner.oidc.issuerURL cannot be empty")
+	case clientID == "":
+		return fmt.Errorf("spec.provisioner.oidc.clientID cannot be empty")
+	case secretName == "":
+		return fmt.Errorf("spec.provisioner.oidc.clientSecretRef.name cannot be empty")
+	case secretKey == "":
+		return fmt.Errorf("spec.provisioner.oidc.clientSecretRef.key cannot be empty")
+	default:
+		return nil
+	}
+}
+
+// validateX5CProvisioner ensures that the X5C provisioner configuration is
+// complete.
+func validateX5CProvisioner(secretName string) error {
+	if secretName == "" {
+		return fmt.Errorf("spec.provisioner.x5c.secretName cannot be empty")
+	}
+	return nil
+}
+
+// validateK8sSAProvisioner ensures that the K8sSA provisioner configuration is
+// complete.
+func validateK8sSAProvisioner(serviceAccountName string) error {
+	if serviceAccountName == "" {
+		return fmt.Errorf("spec.provisioner.k8sSA.serviceAccountName cannot be empty")
+	}
+	return nil
+}
+
+// validateTokenService ensures that the token service configuration is
+// complete and that it uses at least one authentication method.
+func validateTokenService(serviceURL string, bearerToken bool, bearerTokenName, bearerTokenKey, clientCertSecretName string) error {
+	if serviceURL == "" {
+		return fmt.Errorf("spec.provisioner.tokenService.url cannot be empty")
+	}
+	u, err := url.Parse(serviceURL)
+	switch {
+	case err != nil || u.Host == "":
+		return fmt.Errorf("spec.provisioner.tokenService.url is not a valid URL")
+	case u.Scheme != "https":
+		return fmt.Errorf("spec.provisioner.tokenService.url must use https")
+	case !bearerToken && clientCertSecretName == "":
+		return fmt.Errorf("one of spec.provisioner.tokenService.bearerTokenRef or spec.provisioner.tokenService.clientCertSecretName must be set")
+	case bearerToken && bearerTokenName == "":
+		return fmt.Errorf("spec.provisioner.tokenService.bearerTokenRef.name cannot be empty")
+	case bearerToken && bearerTokenKey == "":
+		return fmt.Errorf("spec.provisioner.tokenService.bearerTokenRef.key cannot be empty")
+	default:
+		return nil
+	}
+}
+
+// validateProvisionerSupport ensures that the controller can use the
+// provisioner. It must answer the http-01 challenges of the ACME provisioners,
+// the CA resolves every requested name and connects to it on port 80, and it
+// must be built with cgo to use PKCS#11 keys.
+func validateProvisionerSupport(acme, solver, pkcs11, pkcs11Supported bool) error {
+	switch {
+	case acme && !solver:
+		return fmt.Errorf("spec.provisioner.acme requires the http-01 challenge solver, start the controller with --acme-http01-bind-address")
+	case pkcs11 && !pkcs11Supported:
+		return fmt.Errorf("spec.provisioner.pkcs11 requires a controller built with cgo, build it with make build GOFLAGS=CGO_ENABLED=1")
+	default:
+		return nil
+	}
+}
+
+// validateACMEProvisioner ensures that the ACME provisioner configuration is
+// complete. The external account binding fields are only checked when eab is
+// true.
+func validateACMEProvisioner(accountKeyName, accountKeyKey string, eab bool, eabKeyID, eabSecretName, eabSecretKey string) error {
+	switch {
+	case accountKeyName == "":
+		return fmt.Errorf("spec.provisioner.acme.accountKeyRef.name cannot be empty")
+	case accountKeyKey == "":
+		return fmt.Errorf("spec.provisioner.acme.accountKeyRef.key cannot be empty")
+	case !eab:
+		return nil
+	case eabKeyID == "":
+		return fmt.Errorf("spec.provisioner.acme.externalAccountBinding.keyID cannot be empty")
+	case eabSecretName == "":
+		return fmt.Errorf("spec.provisioner.acme.externalAccountBinding.keySecretRef.name cannot be empty")
+	case eabSecretKey == "":
+		return fmt.Errorf("spec.provisioner.acme.externalAccountBinding.keySecretRef.key cannot be empty")
+	default:
+		return nil
+	}
+}
+
+// validateRevocation checks the revocation settings of an issuer. The
+// unsupportedType is the provisioner type of the issuer if it cannot create
+// revocation tokens.
+func validateRevocation(unsupportedType string, reasonCode *int) error {
+	switch {
+	case unsupportedType != "":
+		return fmt.Errorf("spec.revocation cannot be set with spec.provisioner.%s", unsupportedType)
+	case reasonCode != nil && (*reasonCode < 0 || *reasonCode > 10 || *reasonCode == 7):
+		return fmt.Errorf("spec.revocation.reasonCode %d is not a valid reason code", *reasonCode)
+	default:
+		return nil
+	}
+}
+
+// validateEmbeddedAuthority ensures that an issuer with an embedded authority
+// does not configure a remote CA or provisioner credentials, the provisioner
+// of the embedded authority is generated by the controller.
+func validateEmbeddedAuthority(caURL string, caBundle, credentials bool, secretName string) error {
+	switch {
+	case caURL != "":
+		return fmt.Errorf("spec.url cannot be set with spec.embedded")
+	case caBundle:
+		return fmt.Errorf("spec.caBundle cannot be set with spec.embedded")
+	case credentials:
+		return fmt.Errorf("only spec.provisioner.name can be set with spec.embedded")
+	case secretName == "":
+		return fmt.Errorf("spec.embedded.secretName cannot be empty")
+	default:
+		return nil
+	}
+}
+
+// validatePolicy ensures that the rules of an issuer policy are valid. The
+// namespace variable is the only template variable supported, and it cannot
+// be used in IP ranges.
+func validatePolicy(dnsNames, ipRanges, uriPrefixes, emailDomains []string, minSizes []int, maxDuration *metav1.Duration) error {
+	for _, f := range []struct {
+		field string
+		rules []string
+	}{{"dnsNames", dnsNames}, {"uriPrefixes", uriPrefixes}, {"emailDomains", emailDomains}} {
+		field := f.field
+		for _, r := range f.rules {
+			switch {
+			case r == "":
+				return fmt.Errorf("spec.policy.%s cannot contain empty rules", field)
+			case strings.Contains(strings.ReplaceAll(r, "{{namespace}}", ""), "{{"):
+				return fmt.Errorf("spec.policy.%s rule %q can only use the {{namespace}} variable", field, r)
+			case field != "uriPrefixes" && strings.Contains(strings.TrimPrefix(r, "*."), "*"):
+				return fmt.Errorf("spec.policy.%s rule %q can only use * as the leftmost label", field, r)
+			}
+		}
+	}
+	for _, r := range ipRanges {
+		if _, err := provisioners.ParseIPRange(r); err != nil {
+			return fmt.Errorf("spec.policy.ipRanges: %w", err)
+		}
+	}
+	for i, size := range minSizes {
+		if size < 0 {
+			return fmt.Errorf("spec.policy.keyAlgorithms[%d].minSize cannot be negative", i)
+		}
+	}
+	if maxDuration != nil && maxDuration.Duration <= 0 {
+		return fmt.Errorf("spec.policy.maxDuration must be positive")
+	}
+	return nil
+}
+
+// validateValidity ensures that the durations of the validity settings of an
+// issuer are consistent. Backdating is not supported by ACME provisioners, as
+// the ACME protocol does not send the start of the validity.
+func validateValidity(defaultDuration, minDuration, maxDuration, backdate *metav1.Duration, acme bool) error {
+	for _, f := range []struct {
+		field    string
+		duration *metav1.Duration
+	}{{"defaultDuration", defaultDuration}, {"minDuration", minDuration}, {"maxDuration", maxDuration}} {
+		if f.duration != nil && f.duration.Duration <= 0 {
+			return fmt.Errorf("spec.validity.%s must be positive", f.field)
+		}
+	}
+	switch {
+	case minDuration != nil && maxDuration != nil && minDuration.Duration > maxDuration.Duration:
+		return fmt.Errorf("spec.validity.minDuration cannot be longer than spec.validity.maxDuration")
+	case defaultDuration != nil && minDuration != nil && defaultDuration.Duration < minDuration.Duration:
+		return fmt.Errorf("spec.validity.defaultDuration cannot be shorter than spec.validity.minDuration")
+	case defaultDuration != nil && maxDuration != nil && defaultDuration.Duration > maxDuration.Duration:
+		return fmt.Errorf("spec.validity.defaultDuration cannot be longer than spec.validity.maxDuration")
+	case backdate != nil && backdate.Duration < 0:
+		return fmt.Errorf("spec.validity.backdate cannot be negative")
+	case backdate != nil && backdate.Duration > 0 && acme:
+		return fmt.Errorf("spec.validity.backdate is not supported by acme provisioners")
+	}
+	return nil
+}
+
+// validateSubjectStrategy ensures that the subject strategy of an issuer is
+// supported by its provisioner, and that the template of the Template
+// strategy can be parsed. OIDC, K8sSA and ACME provisioners do not send the
+// subject to the CA.
+func validateSubjectStrategy(unsupportedType string, templateType bool, template string) error {
+	switch {
+	case unsupportedType != "":
+		return fmt.Errorf("spec.subjectStrategy cannot be set with spec.provisioner.%s", unsupportedType)
+	case templateType:
+		_, err := provisioners.ParseSubjectTemplate(template)
+		return err
+	case template != "":
+		return fmt.Errorf("spec.subjectStrategy.template can only be set with the Template type")
+	default:
+		return nil
+	}
+}
+
+// validateSubjectAltNames ensures that the SANs are only normalized with the
+// tokens of a token service. The OIDC, K8sSA and ACME provisioners do not send
+// the SANs in a token, and the CA requires the SANs of the JWK and X5C tokens,
+// including the ones of the embedded authority, to match the certificate
+// request, so their requests can only be rejected.
+func validateSubjectAltNames(unsupportedType string, reject bool) error {
+	if unsupportedType != "" && !reject {
+		return fmt.Errorf("spec.subjectAltNames.mode can only be Reject with %s", unsupportedType)
+	}
+	return nil
+}
@@ -0,0 +1,398 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestValidateStepIssuerSpec(t *testing.T) {
+	jwk := api.StepProvisioner{
+		Name:        "admin",
+		KeyID:       "kid",
+		PasswordRef: api.StepIssuerSecretKeySelector{Name: "s", Key: "password"},
+	}
+	oidc := &api.StepOIDCProvisioner{
+		IssuerURL:       "https://idp.example.com",
+		ClientID:        "step-issuer",
+		ClientSecretRef: api.StepIssuerSecretKeySelector{Name: "s", Key: "client-secret"},
+	}
+	x5c := &api.StepX5CProvisioner{SecretName: "s"}
+	pkcs11 := &api.StepPKCS11Key{
+		ModulePath: "/usr/lib/softhsm/libsofthsm2.so",
+		TokenLabel: "step",
+		KeyLabel:   "key",
+		PINRef:     api.StepIssuerSecretKeySelector{Name: "s", Key: "pin"},
+	}
+	acme := &api.StepACMEProvisioner{AccountKeyRef: api.StepIssuerSecretKeySelector{Name: "s", Key: "key"}}
+
+	tests := []struct {
+		name        string
+		provisioner api.StepProvisioner
+		revocation  *api.StepRevocation
+		sanMode     api.SANMode
+		wantErr     bool
+	}{
+		{name: "jwk ok", provisioner: jwk},
+		{name: "jwk without kid", provisioner: api.StepProvisioner{Name: "admin", PasswordEnv: "E"}, wantErr: true},
+		{name: "jwk keyRef without password", provisioner: api.StepProvisioner{
+			Name: "admin", KeyID: "kid", KeyRef: &api.StepIssuerSecretKeySelector{Name: "k", Key: "key"},
+		}},
+		{name: "jwk keyRef with password", provisioner: api.StepProvisioner{
+			Name: "admin", KeyID: "kid", KeyRef: &api.StepIssuerSecretKeySelector{Name: "k", Key: "key"}, PasswordEnv: "E",
+		}},
+		{name: "jwk keyRef without key", provisioner: api.StepProvisioner{
+			Name: "admin", KeyID: "kid", KeyRef: &api.StepIssuerSecretKeySelector{Name: "k"},
+		}, wantErr: true},
+		{name: "jwk keyRef without kid", provisioner: api.StepProvisioner{
+			Name: "admin", KeyRef: &api.StepIssuerSecretKeySelector{Name: "k", Key: "key"},
+		}, wantErr: true},
+		{name: "pkcs11 ok", provisioner: api.StepProvisioner{Name: "admin", KeyID: "kid", PKCS11: pkcs11}},
+		{name: "pkcs11 without kid", provisioner: api.StepProvisioner{Name: "admin", PKCS11: pkcs11}, wantErr: true},
+		{name: "pkcs11 with password", provisioner: api.StepProvisioner{Name: "admin", KeyID: "kid", PasswordEnv: "E", PKCS11: pkcs11}, wantErr: true},
+		{name: "pkcs11 with token and slot", provisioner: api.StepProvisioner{Name: "admin", KeyID: "kid", PKCS11: &api.StepPKCS11Key{
+			ModulePath: pkcs11.ModulePath, TokenLabel: "step", SlotID: ptr.To(1), KeyLabel: "key", PINRef: pkcs11.PINRef,
+		}}, wantErr: true},
+		{name: "oidc ok", provisioner: api.StepProvisioner{Name: "oidc", OIDC: oidc}},
+		{name: "oidc with kid", provisioner: api.StepProvisioner{Name: "oidc", KeyID: "kid", OIDC: oidc}, wantErr: true},
+		{name: "oidc with password", provisioner: api.StepProvisioner{Name: "oidc", PasswordEnv: "E", OIDC: oidc}, wantErr: true},
+		{name: "oidc without client id", provisioner: api.StepProvisioner{Name: "oidc", OIDC: &api.StepOIDCProvisioner{
+			IssuerURL: "https://idp.example.com", ClientSecretRef: oidc.ClientSecretRef,
+		}}, wantErr: true},
+		{name: "oidc with keyRef", provisioner: api.StepProvisioner{
+			Name: "oidc", KeyRef: &api.StepIssuerSecretKeySelector{Name: "k", Key: "key"}, OIDC: oidc,
+		}, wantErr: true},
+		{name: "x5c ok", provisioner: api.StepProvisioner{Name: "x5c", X5C: x5c}},
+		{name: "x5c without secret", provisioner: api.StepProvisioner{Name: "x5c", X5C: &api.StepX5CProvisioner{}}, wantErr: true},
+		{name: "k8sSA ok", provisioner: api.StepProvisioner{Name: "k8s", K8sSA: &api.StepK8sSAProvisioner{ServiceAccountName: "sa"}}},
+		{name: "k8sSA without service account", provisioner: api.StepProvisioner{Name: "k8s", K8sSA: &api.StepK8sSAProvisioner{}}, wantErr: true},
+		{name: "acme ok", provisioner: api.StepProvisioner{Name: "acme", ACME: acme}},
+		{name: "acme without account key", provisioner: api.StepProvisioner{Name: "acme", ACME: &api.StepACMEProvisioner{}}, wantErr: true},
+		{name: "acme with eab", provisioner: api.StepProvisioner{Name: "acme", ACME: &api.StepACMEProvisioner{
+			AccountKeyRef: acme.AccountKeyRef,
+			ExternalAccountBinding: &api.StepACMEExternalAccountBinding{
+				KeyID: "eab", KeySecretRef: api.StepIssuerSecretKeySelector{Name: "s", Key: "hmac"},
+			},
+		}}},
+		{name: "acme with incomplete eab", provisioner: api.StepProvisioner{Name: "acme", ACME: &api.StepACMEProvisioner{
+			AccountKeyRef: acme.AccountKeyRef, ExternalAccountBinding: &api.StepACMEExternalAccountBinding{KeyID: "eab"},
+		}}, wantErr: true},
+		{name: "tokenService with bearer token", provisioner: api.StepProvisioner{Name: "ts", TokenService: &api.StepTokenService{
+			URL: "https://tokens.example.com/ott", BearerTokenRef: &api.StepIssuerSecretKeySelector{Name: "s", Key: "token"},
+		}}},
+		{name: "tokenService with client certificate", provisioner: api.StepProvisioner{Name: "ts", TokenService: &api.StepTokenService{
+			URL: "https://tokens.example.com/ott", ClientCertSecretName: "s",
+		}}},
+		{name: "tokenService without auth", provisioner: api.StepProvisioner{Name: "ts", TokenService: &api.StepTokenService{
+			URL: "https://tokens.example.com/ott",
+		}}, wantErr: true},
+		{name: "tokenService with http", provisioner: api.StepProvisioner{Name: "ts", TokenService: &api.StepTokenService{
+			URL: "http://tokens.example.com/ott", ClientCertSecretName: "s",
+		}}, wantErr: true},
+		{name: "oidc and x5c", provisioner: api.StepProvisioner{Name: "p", OIDC: oidc, X5C: x5c}, wantErr: true},
+		{name: "jwk with revocation", provisioner: jwk, revocation: &api.StepRevocation{ReasonCode: ptr.To(1)}},
+		{name: "x5c with revocation", provisioner: api.StepProvisioner{Name: "x5c", X5C: x5c}, revocation: &api.StepRevocation{}},
+		{name: "acme with revocation", provisioner: api.StepProvisioner{Name: "acme", ACME: acme}, revocation: &api.StepRevocation{}, wantErr: true},
+		{name: "revocation with invalid reason code", provisioner: jwk, revocation: &api.StepRevocation{ReasonCode: ptr.To(7)}, wantErr: true},
+		{name: "jwk rejecting SANs", provisioner: jwk, sanMode: api.SANModeReject},
+		{name: "jwk normalizing SANs", provisioner: jwk, sanMode: api.SANModeNormalize, wantErr: true},
+		{name: "jwk keyRef stripping SANs", provisioner: api.StepProvisioner{
+			Name: "admin", KeyID: "kid", KeyRef: &api.StepIssuerSecretKeySelector{Name: "k", Key: "key"},
+		}, sanMode: api.SANModeStrip, wantErr: true},
+		{name: "x5c normalizing SANs", provisioner: api.StepProvisioner{Name: "x5c", X5C: x5c}, sanMode: api.SANModeNormalize, wantErr: true},
+		{name: "pkcs11 normalizing SANs", provisioner: api.StepProvisioner{Name: "admin", KeyID: "kid", PKCS11: pkcs11}, sanMode: api.SANModeNormalize, wantErr: true},
+		{name: "tokenService normalizing SANs", provisioner: api.StepProvisioner{Name: "ts", TokenService: &api.StepTokenService{
+			URL: "https://tokens.example.com/ott", ClientCertSecretName: "s",
+		}}, sanMode: api.SANModeNormalize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := api.StepIssuerSpec{
+				URL:         "https://ca.example.com",
+				CABundle:    []byte("bundle"),
+				Provisioner: tt.provisioner,
+				Revocation:  tt.revocation,
+			}
+			if tt.sanMode != "" {
+				spec.SubjectAltNames = &api.StepSubjectAltNames{Mode: tt.sanMode}
+			}
+			err := validateStepIssuerSpec(spec)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateStepIssuerSpecEmbedded(t *testing.T) {
+	embedded := &api.StepEmbeddedAuthority{SecretName: "ca"}
+	tests := []struct {
+		name    string
+		spec    api.StepIssuerSpec
+		wantErr bool
+	}{
+		{name: "ok", spec: api.StepIssuerSpec{Provisioner: api.StepProvisioner{Name: "dev"}, Embedded: embedded}},
+		{name: "with revocation", spec: api.StepIssuerSpec{
+			Provisioner: api.StepProvisioner{Name: "dev"}, Embedded: embedded, Revocation: &api.StepRevocation{},
+		}},
+		{name: "normalizing SANs", spec: api.StepIssuerSpec{
+			Provisioner: api.StepProvisioner{Name: "dev"}, Embedded: embedded,
+			SubjectAltNames: &api.StepSubjectAltNames{Mode: api.SANModeNormalize},
+		}, wantErr: true},
+		{name: "without provisioner name", spec: api.StepIssuerSpec{Embedded: embedded}, wantErr: true},
+		{name: "without secret", spec: api.StepIssuerSpec{
+			Provisioner: api.StepProvisioner{Name: "dev"}, Embedded: &api.StepEmbeddedAuthority{},
+		}, wantErr: true},
+		{name: "with url", spec: api.StepIssuerSpec{
+			URL: "https://ca.example.com", Provisioner: api.StepProvisioner{Name: "dev"}, Embedded: embedded,
+		}, wantErr: true},
+		{name: "with caBundle", spec: api.StepIssuerSpec{
+			CABundle: []byte("bundle"), Provisioner: api.StepProvisioner{Name: "dev"}, Embedded: embedded,
+		}, wantErr: true},
+		{name: "with kid", spec: api.StepIssuerSpec{
+			Provisioner: api.StepProvisioner{Name: "dev", KeyID: "kid"}, Embedded: embedded,
+		}, wantErr: true},
+		{name: "with x5c", spec: api.StepIssuerSpec{
+			Provisioner: api.StepProvisioner{Name: "dev", X5C: &api.StepX5CProvisioner{SecretName: "s"}}, Embedded: embedded,
+		}, wantErr: true},
+		{name: "without url", spec: api.StepIssuerSpec{
+			CABundle: []byte("bundle"), Provisioner: api.StepProvisioner{Name: "admin", KeyID: "kid", PasswordEnv: "E"},
+		}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateStepIssuerSpec(tt.spec)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateStepClusterIssuerSpecSecretNamespaces(t *testing.T) {
+	ref := func(namespace string) api.StepClusterIssuerSecretKeySelector {
+		return api.StepClusterIssuerSecretKeySelector{Name: "s", Namespace: namespace, Key: "key"}
+	}
+	pkcs11 := func(namespace string) *api.StepClusterPKCS11Key {
+		return &api.StepClusterPKCS11Key{ModulePath: "/usr/lib/softhsm/libsofthsm2.so", TokenLabel: "step", KeyLabel: "key", PINRef: ref(namespace)}
+	}
+	oidc := func(namespace string) *api.StepClusterOIDCProvisioner {
+		return &api.StepClusterOIDCProvisioner{IssuerURL: "https://idp.example.com", ClientID: "step-issuer", ClientSecretRef: ref(namespace)}
+	}
+	acme := func(namespace, eabNamespace string) *api.StepClusterACMEProvisioner {
+		return &api.StepClusterACMEProvisioner{
+			AccountKeyRef: ref(namespace),
+			ExternalAccountBinding: &api.StepClusterACMEExternalAccountBinding{
+				KeyID: "eab", KeySecretRef: ref(eabNamespace),
+			},
+		}
+	}
+	tokenService := func(namespace, clientCertNamespace string) *api.StepClusterTokenService {
+		bearerToken := ref(namespace)
+		return &api.StepClusterTokenService{
+			URL: "https://tokens.example.com/ott", BearerTokenRef: &bearerToken,
+			ClientCertSecretName: "s", ClientCertSecretNamespace: clientCertNamespace,
+		}
+	}
+
+	tests := []struct {
+		name        string
+		provisioner api.StepClusterProvisioner
+		embedded    *api.StepClusterEmbeddedAuthority
+		wantErr     string
+	}{
+		{name: "passwordRef", provisioner: api.StepClusterProvisioner{Name: "admin", KeyID: "kid", PasswordRef: ref("step")}},
+		{name: "passwordRef without namespace", provisioner: api.StepClusterProvisioner{Name: "admin", KeyID: "kid", PasswordRef: ref("")},
+			wantErr: "spec.provisioner.passwordRef.namespace cannot be empty"},
+		{name: "keyRef", provisioner: api.StepClusterProvisioner{Name: "admin", KeyID: "kid", KeyRef: ptr.To(ref("step"))}},
+		{name: "keyRef without namespace", provisioner: api.StepClusterProvisioner{Name: "admin", KeyID: "kid", KeyRef: ptr.To(ref(""))},
+			wantErr: "spec.provisioner.keyRef.namespace cannot be empty"},
+		{name: "pkcs11", provisioner: api.StepClusterProvisioner{Name: "admin", KeyID: "kid", PKCS11: pkcs11("step")}},
+		{name: "pkcs11 without namespace", provisioner: api.StepClusterProvisioner{Name: "admin", KeyID: "kid", PKCS11: pkcs11("")},
+			wantErr: "spec.provisioner.pkcs11.pinRef.namespace cannot be empty"},
+		{name: "oidc", provisioner: api.StepClusterProvisioner{Name: "oidc", OIDC: oidc("step")}},
+		{name: "oidc without namespace", provisioner: api.StepClusterProvisioner{Name: "oidc", OIDC: oidc("")},
+			wantErr: "spec.provisioner.oidc.clientSecretRef.namespace cannot be empty"},
+		{name: "x5c", provisioner: api.StepClusterProvisioner{Name: "x5c", X5C: &api.StepClusterX5CProvisioner{SecretName: "s", SecretNamespace: "step"}}},
+		{name: "x5c without namespace", provisioner: api.StepClusterProvisioner{Name: "x5c", X5C: &api.StepClusterX5CProvisioner{SecretName: "s"}},
+			wantErr: "spec.provisioner.x5c.secretNamespace cannot be empty"},
+		{name: "acme", provisioner: api.StepClusterProvisioner{Name: "acme", ACME: acme("step", "step")}},
+		{name: "acme without namespace", provisioner: api.StepClusterProvisioner{Name: "acme", ACME: acme("", "step")},
+			wantErr: "spec.provisioner.acme.accountKeyRef.namespace cannot be empty"},
+		{name: "acme eab without namespace", provisioner: api.StepClusterProvisioner{Name: "acme", ACME: acme("step", "")},
+			wantErr: "spec.provisioner.acme.externalAccountBinding.keySecretRef.namespace cannot be empty"},
+		{name: "tokenService", provisioner: api.StepClusterProvisioner{Name: "ts", TokenService: tokenService("step", "step")}},
+		{name: "tokenService bearer token without namespace", provisioner: api.StepClusterProvisioner{Name: "ts", TokenService: tokenService("", "step")},
+			wantErr: "spec.provisioner.tokenService.bearerTokenRef.namespace cannot be empty"},
+		{name: "tokenService client certificate without namespace", provisioner: api.StepClusterProvisioner{Name: "ts", TokenService: tokenService("step", "")},
+			wantErr: "spec.provisioner.tokenService.clientCertSecretNamespace cannot be empty"},
+		{name: "embedded", provisioner: api.StepClusterProvisioner{Name: "embedded"},
+			embedded: &api.StepClusterEmbeddedAuthority{SecretName: "s", SecretNamespace: "step"}},
+		{name: "embedded without namespace", provisioner: api.StepClusterProvisioner{Name: "embedded"},
+			embedded: &api.StepClusterEmbeddedAuthority{SecretName: "s"}, wantErr: "spec.embedded.secretNamespace cannot be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := api.StepClusterIssuerSpec{Provisioner: tt.provisioner, Embedded: tt.embedded}
+			if tt.embedded == nil {
+				spec.URL, spec.CABundle = "https://ca.example.com", []byte("bundle")
+			}
+			err := validateStepClusterIssuerSpec(spec)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		dnsNames     []string
+		ipRanges     []string
+		uriPrefixes  []string
+		emailDomains []string
+		minSizes     []int
+		maxDuration  *metav1.Duration
+		wantErr      bool
+	}{
+		{name: "ok", dnsNames: []string{"*.{{namespace}}.svc.cluster.local"}, ipRanges: []string{"10.0.0.0/8", "::1"},
+			uriPrefixes: []string{"spiffe://cluster.local/ns/{{namespace}}/"}, emailDomains: []string{"example.com"},
+			minSizes: []int{2048}, maxDuration: &metav1.Duration{Duration: time.Hour}},
+		{name: "unknown variable", dnsNames: []string{"*.{{name}}.svc"}, wantErr: true},
+		{name: "wildcard in the middle", dnsNames: []string{"a.*.example.com"}, wantErr: true},
+		{name: "empty rule", emailDomains: []string{""}, wantErr: true},
+		{name: "invalid IP range", ipRanges: []string{"10.0.0.0/40"}, wantErr: true},
+		{name: "IP range with variable", ipRanges: []string{"{{namespace}}"}, wantErr: true},
+		{name: "negative key size", minSizes: []int{-1}, wantErr: true},
+		{name: "zero duration", maxDuration: &metav1.Duration{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePolicy(tt.dnsNames, tt.ipRanges, tt.uriPrefixes, tt.emailDomains, tt.minSizes, tt.maxDuration)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateValidity(t *testing.T) {
+	d := func(d time.Duration) *metav1.Duration { return &metav1.Duration{Duration: d} }
+	tests := []struct {
+		name                                      string
+		defaultDuration, minDuration, maxDuration *metav1.Duration
+		backdate                                  *metav1.Duration
+		acme                                      bool
+		wantErr                                   bool
+	}{
+		{name: "ok", defaultDuration: d(8 * time.Hour), minDuration: d(time.Hour), maxDuration: d(24 * time.Hour), backdate: d(time.Minute)},
+		{name: "empty"},
+		{name: "zero default", defaultDuration: d(0), wantErr: true},
+		{name: "negative max", maxDuration: d(-time.Hour), wantErr: true},
+		{name: "min longer than max", minDuration: d(2 * time.Hour), maxDuration: d(time.Hour), wantErr: true},
+		{name: "default shorter than min", defaultDuration: d(time.Minute), minDuration: d(time.Hour), wantErr: true},
+		{name: "default longer than max", defaultDuration: d(2 * time.Hour), maxDuration: d(time.Hour), wantErr: true},
+		{name: "negative backdate", backdate: d(-time.Minute), wantErr: true},
+		{name: "backdate with acme", backdate: d(time.Minute), acme: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateValidity(tt.defaultDuration, tt.minDuration, tt.maxDuration, tt.backdate, tt.acme)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateSubjectStrategy(t *testing.T) {
+	tests := []struct {
+		name            string
+		unsupportedType string
+		templateType    bool
+		template        string
+		wantErr         bool
+	}{
+		{name: "ok"},
+		{name: "template", templateType: true, template: "{{ first .URIs }}"},
+		{name: "unsupported provisioner", unsupportedType: "oidc", wantErr: true},
+		{name: "empty template", templateType: true, wantErr: true},
+		{name: "invalid template", templateType: true, template: "{{ .Name", wantErr: true},
+		{name: "template without type", template: "{{ .Name }}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSubjectStrategy(tt.unsupportedType, tt.templateType, tt.template)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateSubjectAltNames(t *testing.T) {
+	if err := validateSubjectAltNames("", false); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if err := validateSubjectAltNames("acme", true); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if err := validateSubjectAltNames("oidc", false); err == nil {
+		t.Error("expected an error normalizing the SANs of an OIDC provisioner")
+	}
+}
+
+func TestValidateProvisionerSupport(t *testing.T) {
+	if err := validateProvisionerSupport(false, false, false, false); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if err := validateProvisionerSupport(true, true, false, false); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if err := validateProvisionerSupport(true, false, false, true); err == nil {
+		t.Error("expected an error with an ACME provisioner without the http-01 solver")
+	}
+	if err := validateProvisionerSupport(false, false, true, true); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if err := validateProvisionerSupport(false, true, true, false); err == nil {
+		t.Error("expected an error with a PKCS#11 key without cgo")
+	}
+}
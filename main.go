@@ -17,17 +17,21 @@ package main
 
 import (
 	"flag"
+	"net/http"
 	"os"
+	"time"
 
 	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	stepv1beta1 "github.com/smallstep/step-issuer/api/v1beta1"
 	"github.com/smallstep/step-issuer/controllers"
+	"github.com/smallstep/step-issuer/provisioners"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	// +kubebuilder:scaffold:imports
 )
@@ -49,6 +53,9 @@ func main() {
 	var enableLeaderElection bool
 	var leaderElectionID string
 	var disableApprovedCheck bool
+	var acmeHTTP01Addr string
+	var memoryCA bool
+	var passwordEnvResync time.Duration
 
 	// Options for configuring logging
 	opts := zap.Options{}
@@ -62,6 +69,12 @@ func main() {
 		"The name of the resource that leader election will use for holding the leader lock.")
 	flag.BoolVar(&disableApprovedCheck, "disable-approval-check", false,
 		"Disables waiting for CertificateRequests to have an approved condition before signing.")
+	flag.StringVar(&acmeHTTP01Addr, "acme-http01-bind-address", "0",
+		"The address the ACME http-01 challenge solver binds to. Use :8089 for HTTP, or leave as 0 to disable the solver.")
+	flag.BoolVar(&memoryCA, "memory-ca", false,
+		"Sign certificates with an in-memory CA per issuer instead of step certificates. For development clusters and tests only.")
+	flag.DurationVar(&passwordEnvResync, "password-env-resync-period", 0,
+		"The period to reconcile again the issuers with a passwordEnv, keeping their provisioner while their credentials do not change. Leave as 0 to disable the resync.")
 	flag.Parse()
 
 	if enableLeaderElection && leaderElectionID == "" {
@@ -74,6 +87,9 @@ func main() {
 		Scheme: scheme,
 		Metrics: metricsserver.Options{
 			BindAddress: metricsAddr,
+			ExtraHandlers: map[string]http.Handler{
+				"/debug/provisioners": provisioners.CacheHandler(),
+			},
 		},
 		LeaderElection:   enableLeaderElection,
 		LeaderElectionID: leaderElectionID,
@@ -83,21 +99,44 @@ func main() {
 		os.Exit(1)
 	}
 
+	if memoryCA {
+		setupLog.Info("using in-memory CAs instead of step certificates, do not use in production")
+	}
+
+	// The issuers are reconciled again when their passwordFile changes.
+	passwordWatcher, err := controllers.NewPasswordWatcher(ctrl.Log.WithName("controllers").WithName("PasswordWatcher"))
+	if err != nil {
+		setupLog.Error(err, "unable to create password watcher")
+		os.Exit(1)
+	}
+	if err = mgr.Add(passwordWatcher); err != nil {
+		setupLog.Error(err, "unable to add password watcher")
+		os.Exit(1)
+	}
+
 	if err = (&controllers.StepIssuerReconciler{
-		Client:   mgr.GetClient(),
-		Log:      ctrl.Log.WithName("controllers").WithName("StepIssuer"),
-		Clock:    clock.RealClock{},
-		Recorder: mgr.GetEventRecorderFor("stepissuer-controller"), //nolint:staticcheck,nolintlint // will be fixed later
+		Client:                  mgr.GetClient(),
+		Log:                     ctrl.Log.WithName("controllers").WithName("StepIssuer"),
+		Clock:                   clock.RealClock{},
+		Recorder:                mgr.GetEventRecorderFor("stepissuer-controller"), //nolint:staticcheck,nolintlint // will be fixed later
+		MemoryCA:                memoryCA,
+		ACMEHTTP01Solver:        acmeHTTP01Addr != "0",
+		PasswordWatcher:         passwordWatcher,
+		PasswordEnvResyncPeriod: passwordEnvResync,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "StepIssuer")
 		os.Exit(1)
 	}
 
 	if err = (&controllers.StepClusterIssuerReconciler{
-		Client:   mgr.GetClient(),
-		Log:      ctrl.Log.WithName("controllers").WithName("StepClusterIssuer"),
-		Clock:    clock.RealClock{},
-		Recorder: mgr.GetEventRecorderFor("stepclusterissuer-controller"), //nolint:staticcheck,nolintlint // will be fixed later
+		Client:                  mgr.GetClient(),
+		Log:                     ctrl.Log.WithName("controllers").WithName("StepClusterIssuer"),
+		Clock:                   clock.RealClock{},
+		Recorder:                mgr.GetEventRecorderFor("stepclusterissuer-controller"), //nolint:staticcheck,nolintlint // will be fixed later
+		MemoryCA:                memoryCA,
+		ACMEHTTP01Solver:        acmeHTTP01Addr != "0",
+		PasswordWatcher:         passwordWatcher,
+		PasswordEnvResyncPeriod: passwordEnvResync,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "StepClusterIssuer")
 		os.Exit(1)
@@ -109,13 +148,42 @@ func main() {
 		Recorder:               mgr.GetEventRecorderFor("certificaterequests-controller"), //nolint:staticcheck,nolintlint // will be fixed later
 		Clock:                  clock.RealClock{},
 		CheckApprovedCondition: !disableApprovedCheck,
+		MemoryCA:               memoryCA,
+		ACMEHTTP01Solver:       acmeHTTP01Addr != "0",
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "CertificateRequest")
 		os.Exit(1)
 	}
 
+	if err = (&controllers.RevocationReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("Revocation"),
+		Clock:    clock.RealClock{},
+		Recorder: mgr.GetEventRecorderFor("revocation-controller"), //nolint:staticcheck,nolintlint // will be fixed later
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Revocation")
+		os.Exit(1)
+	}
+
 	// +kubebuilder:scaffold:builder
 
+	// The http-01 challenges of ACME provisioners are answered by the leader,
+	// which is the only replica signing certificates.
+	if acmeHTTP01Addr != "0" {
+		if err := mgr.Add(&manager.Server{
+			Name: "acme-http01",
+			Server: &http.Server{
+				Addr:              acmeHTTP01Addr,
+				Handler:           provisioners.HTTP01Handler(),
+				ReadHeaderTimeout: 10 * time.Second,
+			},
+			OnlyServeWhenLeader: true,
+		}); err != nil {
+			setupLog.Error(err, "unable to add ACME http-01 solver")
+			os.Exit(1)
+		}
+	}
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
@@ -0,0 +1,175 @@
+package provisioners
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.step.sm/crypto/pemutil"
+	"golang.org/x/crypto/acme"
+)
+
+// acmeOrderTimeout is the maximum time spent on an ACME order, from its
+// creation to the download of the certificate.
+const acmeOrderTimeout = 2 * time.Minute
+
+// acmeConfig contains the configuration of an ACME provisioner.
+type acmeConfig struct {
+	email    string
+	eabKeyID string
+}
+
+// acmeSigner requests certificates from an ACME provisioner. The
+// authorizations are completed with http-01 challenges answered by
+// HTTP01Handler.
+type acmeSigner struct {
+	client *acme.Client
+}
+
+// newACMESigner returns an ACME client for the directory of the given
+// provisioner, and registers its account if it does not exist.
+func newACMESigner(ctx context.Context, caURL, provisioner string, caBundle []byte, cfg *acmeConfig, accountKeyPEM, eabKey []byte) (*acmeSigner, error) {
+	key, err := pemutil.ParseKey(accountKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing ACME account key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("ACME account key is not a valid private key")
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caBundle) {
+		return nil, fmt.Errorf("error parsing caBundle")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	client := &acme.Client{
+		Key:          signer,
+		HTTPClient:   &http.Client{Transport: transport, Timeout: 30 * time.Second},
+		DirectoryURL: acmeDirectoryURL(caURL, provisioner),
+		UserAgent:    "step-issuer",
+	}
+
+	acct := &acme.Account{}
+	if cfg.email != "" {
+		acct.Contact = []string{"mailto:" + cfg.email}
+	}
+	if cfg.eabKeyID != "" {
+		hmacKey, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(strings.TrimSpace(string(eabKey)), "="))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding ACME external account binding key: %w", err)
+		}
+		acct.ExternalAccountBinding = &acme.ExternalAccountBinding{
+			KID: cfg.eabKeyID,
+			Key: hmacKey,
+		}
+	}
+
+	if _, err := client.Register(ctx, acct, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return nil, fmt.Errorf("error registering ACME account: %w", err)
+	}
+
+	return &acmeSigner{client: client}, nil
+}
+
+// Sign creates an ACME order for the names in the certificate request,
+// completes its authorizations and returns the PEM encoded certificate chain.
+func (s *acmeSigner) Sign(ctx context.Context, csr *x509.CertificateRequest, duration time.Duration) ([]byte, error) {
+	if len(csr.EmailAddresses) > 0 || len(csr.URIs) > 0 {
+		return nil, fmt.Errorf("ACME provisioners do not support email or URI SANs")
+	}
+	ids := acme.DomainIDs(csr.DNSNames...)
+	for _, ip := range csr.IPAddresses {
+		ids = append(ids, acme.IPIDs(ip.String())...)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("ACME provisioners require at least one DNS or IP SAN")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, acmeOrderTimeout)
+	defer cancel()
+
+	var opts []acme.OrderOption
+	if duration > 0 {
+		opts = append(opts, acme.WithOrderNotAfter(time.Now().Add(duration)))
+	}
+	order, err := s.client.AuthorizeOrder(ctx, ids, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating ACME order: %w", err)
+	}
+	for _, u := range order.AuthzURLs {
+		if err := s.authorize(ctx, u); err != nil {
+			return nil, err
+		}
+	}
+	if order, err = s.client.WaitOrder(ctx, order.URI); err != nil {
+		return nil, fmt.Errorf("error waiting for ACME order: %w", err)
+	}
+
+	der, _, err := s.client.CreateOrderCert(ctx, order.FinalizeURL, csr.Raw, true)
+	if err != nil {
+		return nil, fmt.Errorf("error finalizing ACME order: %w", err)
+	}
+	var chainPEM []byte
+	for _, b := range der {
+		chainPEM = append(chainPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: b})...)
+	}
+	return chainPEM, nil
+}
+
+// authorize completes a pending authorization using its http-01 challenge.
+func (s *acmeSigner) authorize(ctx context.Context, authzURL string) error {
+	authz, err := s.client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return fmt.Errorf("error getting ACME authorization: %w", err)
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+
+	var chal *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "http-01" {
+			chal = c
+			break
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("ACME authorization for %s does not offer an http-01 challenge", authz.Identifier.Value)
+	}
+
+	keyAuth, err := s.client.HTTP01ChallengeResponse(chal.Token)
+	if err != nil {
+		return err
+	}
+	http01Challenges.Store(chal.Token, keyAuth)
+	defer http01Challenges.Delete(chal.Token)
+
+	if _, err := s.client.Accept(ctx, chal); err != nil {
+		return fmt.Errorf("error accepting ACME challenge for %s: %w", authz.Identifier.Value, err)
+	}
+	if _, err := s.client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return fmt.Errorf("error validating ACME challenge for %s: %w", authz.Identifier.Value, err)
+	}
+	return nil
+}
+
+// acmeDirectoryURL returns the directory URL of an ACME provisioner in a step
+// certificates instance.
+func acmeDirectoryURL(caURL, provisioner string) string {
+	return strings.TrimSuffix(caURL, "/") + "/acme/" + url.PathEscape(provisioner) + "/directory"
+}
@@ -0,0 +1,130 @@
+package provisioners
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	stepacme "github.com/smallstep/certificates/acme"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"go.step.sm/crypto/pemutil"
+)
+
+func TestACMESigner(t *testing.T) {
+	solver := httptest.NewServer(HTTP01Handler())
+	t.Cleanup(solver.Close)
+	_, port, err := net.SplitHostPort(solver.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	solverPort, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The CA validates the challenges on port 80 of the requested names,
+	// where the solver is routed in a cluster. Here it connects directly to
+	// the port of the test solver.
+	stepacme.InsecurePortHTTP01 = solverPort
+	t.Cleanup(func() { stepacme.InsecurePortHTTP01 = 0 })
+	testCA := newTestCA(t, provisioner.List{
+		&provisioner.ACME{Type: "ACME", Name: "acme", Challenges: []provisioner.ACMEChallenge{provisioner.HTTP_01}},
+	})
+
+	accountKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accountKeyBlock, err := pemutil.Serialize(accountKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := newACMESigner(context.Background(), testCA.URL, "acme", testCA.RootPEM, &acmeConfig{}, pem.EncodeToMemory(accountKeyBlock), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	chainPEM, err := s.Sign(ctx, csr, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	block, _ := pem.Decode(chainPEM)
+	if block == nil {
+		t.Fatal("expected a PEM encoded certificate")
+	}
+	crt, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(crt.IPAddresses) != 1 || !crt.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Fatalf("unexpected IP SANs %v", crt.IPAddresses)
+	}
+
+	// The challenges are removed once the authorizations are complete.
+	http01Challenges.Range(func(k, _ any) bool {
+		t.Errorf("unexpected pending challenge %v", k)
+		return true
+	})
+}
+
+func TestACMESignerUnsupportedSANs(t *testing.T) {
+	s := &acmeSigner{}
+	csr := &x509.CertificateRequest{EmailAddresses: []string{"jane@example.com"}}
+	if _, err := s.Sign(context.Background(), csr, 0); err == nil {
+		t.Fatal("expected an error with email SANs")
+	}
+	if _, err := s.Sign(context.Background(), &x509.CertificateRequest{}, 0); err == nil {
+		t.Fatal("expected an error without SANs")
+	}
+}
+
+func TestHTTP01Handler(t *testing.T) {
+	http01Challenges.Store("token", "token.thumbprint")
+	t.Cleanup(func() { http01Challenges.Delete("token") })
+
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/.well-known/acme-challenge/token", http.StatusOK},
+		{"/.well-known/acme-challenge/other", http.StatusNotFound},
+		{"/token", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		HTTP01Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, http.NoBody))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.wantStatus, rec.Code)
+		}
+		if tt.wantStatus == http.StatusOK && rec.Body.String() != "token.thumbprint" {
+			t.Errorf("%s: unexpected body %q", tt.path, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package provisioners
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	caconfig "github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/ca"
+	"github.com/smallstep/certificates/db"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/minica"
+	"go.step.sm/crypto/pemutil"
+)
+
+// testCA is a step certificates instance running in the test process.
+type testCA struct {
+	URL     string
+	Root    *x509.Certificate
+	RootPEM []byte
+}
+
+// freeAddr returns a local address that is not in use.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// newTestCA starts a step certificates instance with the given provisioners.
+// The federated roots are returned by the /federation endpoint along with the
+// root of the CA.
+func newTestCA(t *testing.T, provisioners provisioner.List, federated ...*x509.Certificate) *testCA {
+	t.Helper()
+	mca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	rootPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mca.Root.Raw})
+	intPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mca.Intermediate.Raw})
+	keyBlock, err := pemutil.Serialize(mca.Signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var federatedPEM []byte
+	for _, crt := range federated {
+		federatedPEM = append(federatedPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})...)
+	}
+	files := map[string][]byte{
+		"root.crt":         rootPEM,
+		"intermediate.crt": intPEM,
+		"intermediate.key": pem.EncodeToMemory(keyBlock),
+		"federated.crt":    federatedPEM,
+	}
+	for name, b := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	addr := freeAddr(t)
+	cfg := &caconfig.Config{
+		Root:             []string{filepath.Join(dir, "root.crt")},
+		IntermediateCert: filepath.Join(dir, "intermediate.crt"),
+		IntermediateKey:  filepath.Join(dir, "intermediate.key"),
+		Address:          addr,
+		DNSNames:         []string{"127.0.0.1"},
+		DB:               &db.Config{Type: "bbolt", DataSource: filepath.Join(dir, "db")},
+		AuthorityConfig: &caconfig.AuthConfig{
+			Provisioners: provisioners,
+		},
+	}
+	if len(federated) > 0 {
+		cfg.FederatedRoots = []string{filepath.Join(dir, "federated.crt")}
+	}
+
+	srv, err := ca.New(cfg, ca.WithQuiet(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = srv.Run() }()
+	t.Cleanup(func() { _ = srv.Stop() })
+
+	// Wait for the server to accept connections.
+	for i := 0; i < 50; i++ {
+		if c, err := net.Dial("tcp", addr); err == nil {
+			c.Close()
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return &testCA{
+		URL:     "https://" + addr,
+		Root:    mca.Root,
+		RootPEM: rootPEM,
+	}
+}
+
+// newTestJWKProvisioner returns a JWK provisioner with the given name and its
+// private key, encoded as a plain JSON Web Key.
+func newTestJWKProvisioner(t *testing.T, name string) (*provisioner.JWK, []byte) {
+	t.Helper()
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jwk.KeyID, err = jose.Thumbprint(jwk); err != nil {
+		t.Fatal(err)
+	}
+	key, err := json.Marshal(jwk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := jwk.Public()
+	return &provisioner.JWK{
+		Type: "JWK",
+		Name: name,
+		Key:  &pub,
+	}, key
+}
+
+// newTestCSR returns a PEM encoded certificate request for the given DNS
+// names.
+func newTestCSR(t *testing.T, dnsNames ...string) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestCSRWithKey(t, key, dnsNames...)
+}
+
+// newTestCSRWithKey returns a PEM encoded certificate request for the given
+// key and DNS names.
+func newTestCSRWithKey(t *testing.T, key crypto.Signer, dnsNames ...string) []byte {
+	t.Helper()
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: dnsNames[0]},
+		DNSNames: dnsNames,
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+}
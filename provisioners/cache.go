@@ -0,0 +1,268 @@
+package provisioners
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// collection caches the signers of the issuers by NamespacedName. The cluster
+// issuers have an empty namespace.
+var collection = new(sync.Map)
+
+// creating deduplicates the concurrent creations of the signer of an issuer.
+var creating singleflight.Group
+
+// entry is the value stored in the collection.
+type entry struct {
+	*lease
+	generation int64
+	created    time.Time
+}
+
+// lease counts the users of a signer, so it is not closed while it signs or
+// revokes certificates. A signer replaced or deleted from the collection is
+// retired, and closed when its last user releases it.
+type lease struct {
+	signer Signer
+
+	mu      sync.Mutex
+	users   int
+	retired bool
+}
+
+func newEntry(signer Signer, generation int64) *entry {
+	return &entry{lease: &lease{signer: signer}, generation: generation, created: time.Now()}
+}
+
+// acquire adds a user of the signer. It returns false if the signer was
+// retired.
+func (l *lease) acquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.retired {
+		return false
+	}
+	l.users++
+	return true
+}
+
+// release removes a user of the signer, and closes it if it was the last user
+// of a retired signer.
+func (l *lease) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.users--
+	if l.retired && l.users == 0 {
+		_ = l.signer.Close()
+	}
+}
+
+// retire marks the signer as retired, and closes it if it is not used.
+func (l *lease) retire() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.retired {
+		return
+	}
+	l.retired = true
+	if l.users == 0 {
+		_ = l.signer.Close()
+	}
+}
+
+// CacheEntry describes a signer in the collection.
+type CacheEntry struct {
+	// Issuer is the namespace and name of the issuer, or just the name of a
+	// cluster issuer.
+	Issuer string `json:"issuer"`
+
+	// Generation is the generation of the issuer used to create the signer.
+	Generation int64 `json:"generation"`
+
+	// Created is the time the signer was added to the collection.
+	Created time.Time `json:"created"`
+}
+
+func load(namespacedName types.NamespacedName) (*entry, bool) {
+	v, ok := collection.Load(namespacedName)
+	if !ok {
+		return nil, false
+	}
+	e, ok := v.(*entry)
+	return e, ok
+}
+
+// Load returns the signer of an issuer by NamespacedName, regardless of the
+// generation it was created with.
+func Load(namespacedName types.NamespacedName) (Signer, bool) {
+	e, ok := load(namespacedName)
+	if !ok {
+		return nil, false
+	}
+	return e.signer, true
+}
+
+// LoadUnchanged returns the Step signer of an issuer if it was created with
+// the given generation of the issuer and the same credentials. The issuer
+// reconcilers keep it instead of creating a new provisioner, or starting a new
+// embedded authority with a new database and provisioner key, when they
+// reconcile an issuer that did not change.
+func LoadUnchanged(namespacedName types.NamespacedName, generation int64, creds Credentials) (*Step, bool) {
+	e, ok := load(namespacedName)
+	if !ok || e.generation != generation {
+		return nil, false
+	}
+	s, ok := e.signer.(*Step)
+	if !ok || s.credentials != credentialsDigest(creds) {
+		return nil, false
+	}
+	return s, true
+}
+
+// Acquire returns the signer of an issuer by NamespacedName, as Load does,
+// and a function to call when it is not used anymore. The signer is not closed
+// until then, even if it is replaced or deleted.
+func Acquire(namespacedName types.NamespacedName) (Signer, func(), bool) {
+	for {
+		e, ok := load(namespacedName)
+		if !ok {
+			return nil, nil, false
+		}
+		if e.acquire() {
+			return e.signer, sync.OnceFunc(e.release), true
+		}
+		// The signer was replaced or deleted after it was loaded.
+	}
+}
+
+// LoadOrCreate returns the signer of an issuer by NamespacedName, creating it
+// with the given function if it is not in the collection, e.g. after a
+// restart and before the issuer is reconciled, or if it was created with an
+// older generation of the issuer. Concurrent calls for the same issuer share a
+// single call to create, and the signer created is not stored if the issuer
+// reconciler stored or deleted one in the meantime. As with Acquire, the
+// returned function must be called when the signer is not used anymore.
+func LoadOrCreate(namespacedName types.NamespacedName, generation int64, create func() (Signer, error)) (Signer, func(), error) {
+	for {
+		e, ok := load(namespacedName)
+		if !ok || e.generation < generation {
+			v, err, _ := creating.Do(fmt.Sprintf("%s@%d", namespacedName, generation), func() (any, error) {
+				return loadOrCreate(namespacedName, generation, create)
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+			if e, ok = v.(*entry); !ok {
+				return nil, nil, fmt.Errorf("provisioner %s is not a signer", namespacedName)
+			}
+		}
+		if e.acquire() {
+			return e.signer, sync.OnceFunc(e.release), nil
+		}
+		// The signer was replaced or deleted after it was loaded.
+	}
+}
+
+// loadOrCreate returns the entry of an issuer created with the given
+// generation or a newer one, or stores a new one.
+func loadOrCreate(namespacedName types.NamespacedName, generation int64, create func() (Signer, error)) (*entry, error) {
+	old, ok := load(namespacedName)
+	if ok && old.generation >= generation {
+		return old, nil
+	}
+	p, err := create()
+	if err != nil {
+		return nil, err
+	}
+	e := newEntry(p, generation)
+	var stored bool
+	if ok {
+		stored = collection.CompareAndSwap(namespacedName, old, e)
+	} else {
+		_, loaded := collection.LoadOrStore(namespacedName, e)
+		stored = !loaded
+	}
+	if !stored {
+		_ = p.Close()
+		if actual, ok := load(namespacedName); ok {
+			return actual, nil
+		}
+		return nil, fmt.Errorf("provisioner %s was deleted", namespacedName)
+	}
+	if ok {
+		old.retire()
+	}
+	return e, nil
+}
+
+// Store adds a new signer to the collection by NamespacedName, with the
+// generation of the issuer used to create it. The signer it replaces is closed
+// when it is not used anymore.
+func Store(namespacedName types.NamespacedName, generation int64, signer Signer) {
+	e := newEntry(signer, generation)
+	if old, ok := load(namespacedName); ok && old.signer == signer {
+		e.lease, e.created = old.lease, old.created
+	}
+	if old, loaded := collection.Swap(namespacedName, e); loaded {
+		if o, ok := old.(*entry); ok && o.lease != e.lease {
+			o.retire()
+		}
+	}
+}
+
+// Delete removes the signer of an issuer from the collection, and closes it
+// when it is not used anymore. It is called when an issuer is deleted or it
+// fails to be reconciled, so it cannot sign more certificates.
+func Delete(namespacedName types.NamespacedName) {
+	if old, loaded := collection.LoadAndDelete(namespacedName); loaded {
+		if o, ok := old.(*entry); ok {
+			o.retire()
+		}
+	}
+}
+
+// Entries returns the signers in the collection, sorted by issuer.
+func Entries() []CacheEntry {
+	entries := []CacheEntry{}
+	collection.Range(func(k, v any) bool {
+		nn, ok1 := k.(types.NamespacedName)
+		e, ok2 := v.(*entry)
+		if ok1 && ok2 {
+			issuer := nn.Name
+			if nn.Namespace != "" {
+				issuer = nn.String()
+			}
+			entries = append(entries, CacheEntry{
+				Issuer:     issuer,
+				Generation: e.generation,
+				Created:    e.created,
+			})
+		}
+		return true
+	})
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Issuer < entries[j].Issuer
+	})
+	return entries
+}
+
+// CacheHandler returns an http.Handler that writes the entries of the
+// collection in JSON. It does not expose any secret material.
+func CacheHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(Entries())
+	})
+}
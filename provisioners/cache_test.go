@@ -0,0 +1,246 @@
+package provisioners
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestLoadOrCreate(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "lazy"}
+	t.Cleanup(func() { collection.Delete(key) })
+
+	if _, _, err := LoadOrCreate(key, 1, func() (Signer, error) {
+		return nil, errors.New("CA not available")
+	}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, ok := Load(key); ok {
+		t.Fatal("expected no signer after an error")
+	}
+
+	// A burst of requests creates a single signer.
+	var calls atomic.Int32
+	unblock := make(chan struct{})
+	var wg sync.WaitGroup
+	signers := make([]Signer, 10)
+	for i := range signers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p, release, err := LoadOrCreate(key, 1, func() (Signer, error) {
+				calls.Add(1)
+				<-unblock
+				return new(Step), nil
+			})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			release()
+			signers[i] = p
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(unblock)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected a single creation, got %d", n)
+	}
+	stored, ok := Load(key)
+	if !ok {
+		t.Fatal("expected the signer to be stored")
+	}
+	for _, p := range signers {
+		if p != stored {
+			t.Errorf("expected the stored signer, got %v", p)
+		}
+	}
+
+	// The signer stored by the issuer reconciler is kept.
+	replaced := new(Step)
+	Store(key, 1, replaced)
+	p, release, err := LoadOrCreate(key, 1, func() (Signer, error) {
+		t.Error("unexpected creation")
+		return nil, nil
+	})
+	if err != nil || p != replaced {
+		t.Fatalf("expected the stored signer, got %v, %v", p, err)
+	}
+	release()
+}
+
+// closeSigner is a signer that records if it was closed.
+type closeSigner struct {
+	Signer
+	closed atomic.Bool
+}
+
+func (s *closeSigner) Close() error {
+	s.closed.Store(true)
+	return nil
+}
+
+func TestCacheGeneration(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "generation"}
+	t.Cleanup(func() { collection.Delete(key) })
+
+	first := new(closeSigner)
+	Store(key, 2, first)
+
+	// An older generation, e.g. from a stale cache, uses the stored signer.
+	p, releaseFirst, err := LoadOrCreate(key, 1, func() (Signer, error) {
+		t.Error("unexpected creation")
+		return nil, nil
+	})
+	if err != nil || p != first {
+		t.Fatalf("expected the stored signer, got %v, %v", p, err)
+	}
+
+	// A newer generation replaces it, and the replaced signer is closed when
+	// it is not used anymore.
+	second := new(closeSigner)
+	p, releaseSecond, err := LoadOrCreate(key, 3, func() (Signer, error) {
+		return second, nil
+	})
+	if err != nil || p != second {
+		t.Fatalf("expected the new signer, got %v, %v", p, err)
+	}
+	releaseSecond()
+	if first.closed.Load() {
+		t.Error("expected the replaced signer to be open while it is used")
+	}
+	releaseFirst()
+	releaseFirst()
+	if !first.closed.Load() {
+		t.Error("expected the replaced signer to be closed")
+	}
+
+	// Storing the same signer with a new generation keeps it open.
+	Store(key, 4, second)
+	if second.closed.Load() {
+		t.Error("expected the stored signer to be open")
+	}
+	if e, ok := load(key); !ok || e.generation != 4 {
+		t.Errorf("expected generation 4, got %v", e)
+	}
+
+	Delete(key)
+	if !second.closed.Load() {
+		t.Error("expected the deleted signer to be closed")
+	}
+	if _, ok := Load(key); ok {
+		t.Error("expected no signer after a deletion")
+	}
+}
+
+func TestAcquire(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "acquire"}
+	t.Cleanup(func() { collection.Delete(key) })
+
+	if _, _, ok := Acquire(key); ok {
+		t.Error("expected no signer before one is stored")
+	}
+
+	// The signer deleted while in use, e.g. by a request signed when its
+	// issuer is deleted, is closed when the last user releases it.
+	first := new(closeSigner)
+	Store(key, 1, first)
+	p1, release1, ok := Acquire(key)
+	if !ok || p1 != first {
+		t.Fatalf("expected the stored signer, got %v, %v", p1, ok)
+	}
+	_, release2, _ := Acquire(key)
+	Delete(key)
+	if _, _, ok := Acquire(key); ok {
+		t.Error("expected no signer after a deletion")
+	}
+	release1()
+	if first.closed.Load() {
+		t.Error("expected the deleted signer to be open while it is used")
+	}
+	release2()
+	if !first.closed.Load() {
+		t.Error("expected the deleted signer to be closed")
+	}
+
+	// Concurrent users of signers replaced by the issuer reconciler always
+	// get an open signer, and the replaced signers are all closed.
+	var wg sync.WaitGroup
+	signers := make([]*closeSigner, 50)
+	for i := range signers {
+		signers[i] = new(closeSigner)
+	}
+	Store(key, 1, signers[0])
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				p, release, ok := Acquire(key)
+				if !ok {
+					t.Error("expected a signer")
+					return
+				}
+				if p.(*closeSigner).closed.Load() {
+					t.Error("expected an open signer")
+				}
+				release()
+			}
+		}()
+	}
+	for _, s := range signers[1:] {
+		Store(key, 1, s)
+	}
+	wg.Wait()
+	for _, s := range signers[:len(signers)-1] {
+		if !s.closed.Load() {
+			t.Error("expected the replaced signers to be closed")
+		}
+	}
+}
+
+func TestCacheHandler(t *testing.T) {
+	namespaced := types.NamespacedName{Namespace: "default", Name: "handler"}
+	cluster := types.NamespacedName{Name: "handler"}
+	t.Cleanup(func() {
+		collection.Delete(namespaced)
+		collection.Delete(cluster)
+	})
+	Store(namespaced, 3, new(closeSigner))
+	Store(cluster, 5, new(closeSigner))
+
+	rec := httptest.NewRecorder()
+	CacheHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/provisioners", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var entries []CacheEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int64{}
+	for _, e := range entries {
+		if e.Created.IsZero() {
+			t.Errorf("expected a created time for %s", e.Issuer)
+		}
+		got[e.Issuer] = e.Generation
+	}
+	if got["default/handler"] != 3 || got["handler"] != 5 {
+		t.Errorf("unexpected entries %v", entries)
+	}
+
+	rec = httptest.NewRecorder()
+	CacheHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/provisioners", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,160 @@
+package provisioners
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/ca"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// provisionersPageSize is the number of provisioners requested at once when
+// looking for the provisioner of an issuer.
+const provisionersPageSize = 100
+
+// ErrDurationNotAllowed is returned when the duration of a CertificateRequest
+// is outside of the range allowed by the claims of the provisioner.
+var ErrDurationNotAllowed = errors.New("certificate duration not allowed by the provisioner")
+
+// ProvisionerClaims contains the claims of a provisioner, as returned by the
+// CA. The claims that are not set in the provisioner default to the global
+// claims of the CA, which are not published, so they are left unset.
+type ProvisionerClaims struct {
+	// Name and Type are the name and type of the provisioner.
+	Name string
+	Type string
+
+	// MinDuration, MaxDuration and DefaultDuration are the limits and the
+	// default of the duration of the X.509 certificates, or zero if they
+	// are not set.
+	MinDuration     time.Duration
+	MaxDuration     time.Duration
+	DefaultDuration time.Duration
+
+	// DisableRenewal and AllowRenewalAfterExpiry are the renewal claims, or
+	// nil if they are not set.
+	DisableRenewal          *bool
+	AllowRenewalAfterExpiry *bool
+
+	// Template is true if the provisioner has an X.509 template.
+	Template bool
+}
+
+// NewProvisionerClaimsFromStepIssuer returns the claims of the provisioner of
+// a StepIssuer, as published in its status, or nil if they are not known.
+func NewProvisionerClaimsFromStepIssuer(iss *api.StepIssuer) *ProvisionerClaims {
+	p := iss.Status.Provisioner
+	if p == nil {
+		return nil
+	}
+	return newProvisionerClaims(p.Name, p.Type, p.MinTLSCertDuration, p.MaxTLSCertDuration, p.DefaultTLSCertDuration)
+}
+
+// NewProvisionerClaimsFromStepClusterIssuer returns the claims of the
+// provisioner of a StepClusterIssuer, as published in its status, or nil if
+// they are not known.
+func NewProvisionerClaimsFromStepClusterIssuer(iss *api.StepClusterIssuer) *ProvisionerClaims {
+	p := iss.Status.Provisioner
+	if p == nil {
+		return nil
+	}
+	return newProvisionerClaims(p.Name, p.Type, p.MinTLSCertDuration, p.MaxTLSCertDuration, p.DefaultTLSCertDuration)
+}
+
+func newProvisionerClaims(name, typ string, minDuration, maxDuration, defaultDuration *metav1.Duration) *ProvisionerClaims {
+	c := &ProvisionerClaims{Name: name, Type: typ}
+	if minDuration != nil {
+		c.MinDuration = minDuration.Duration
+	}
+	if maxDuration != nil {
+		c.MaxDuration = maxDuration.Duration
+	}
+	if defaultDuration != nil {
+		c.DefaultDuration = defaultDuration.Duration
+	}
+	return c
+}
+
+// ValidateDuration returns an error wrapping ErrDurationNotAllowed if the
+// duration of the request is outside of the limits of the provisioner. The
+// requests without a duration get the default duration of the provisioner,
+// so they are always allowed.
+func (c *ProvisionerClaims) ValidateDuration(cr *certmanager.CertificateRequest) error {
+	if cr.Spec.Duration == nil {
+		return nil
+	}
+	d := cr.Spec.Duration.Duration
+	switch {
+	case c.MinDuration > 0 && d < c.MinDuration:
+		return fmt.Errorf("%w: duration %s is shorter than the minTLSCertDuration %s of the %s provisioner %q",
+			ErrDurationNotAllowed, d, c.MinDuration, c.Type, c.Name)
+	case c.MaxDuration > 0 && d > c.MaxDuration:
+		return fmt.Errorf("%w: duration %s is longer than the maxTLSCertDuration %s of the %s provisioner %q",
+			ErrDurationNotAllowed, d, c.MaxDuration, c.Type, c.Name)
+	default:
+		return nil
+	}
+}
+
+// ProvisionerClaims returns the claims of the provisioner of the issuer, read
+// from the /provisioners endpoint of the CA.
+func (s *Step) ProvisionerClaims(ctx context.Context) (*ProvisionerClaims, error) {
+	var cursor string
+	for {
+		resp, err := s.client.ProvisionersWithContext(ctx, ca.WithProvisionerCursor(cursor), ca.WithProvisionerLimit(provisionersPageSize))
+		if err != nil {
+			return nil, fmt.Errorf("error getting CA provisioners: %w", err)
+		}
+		for _, p := range resp.Provisioners {
+			if p.GetName() == s.provisioner {
+				return claimsOf(p)
+			}
+		}
+		if resp.NextCursor == "" || len(resp.Provisioners) == 0 {
+			return nil, fmt.Errorf("provisioner %q not found in the CA", s.provisioner)
+		}
+		cursor = resp.NextCursor
+	}
+}
+
+// claimsOf returns the claims of a provisioner. The provisioner types do not
+// share the fields with the claims and options, so they are read from its
+// JSON representation.
+func claimsOf(p provisioner.Interface) (*ProvisionerClaims, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding provisioner %q: %w", p.GetName(), err)
+	}
+	var v struct {
+		Claims  *provisioner.Claims  `json:"claims"`
+		Options *provisioner.Options `json:"options"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("error decoding provisioner %q: %w", p.GetName(), err)
+	}
+
+	c := &ProvisionerClaims{Name: p.GetName(), Type: p.GetType().String()}
+	if v.Claims != nil {
+		if v.Claims.MinTLSDur != nil {
+			c.MinDuration = v.Claims.MinTLSDur.Duration
+		}
+		if v.Claims.MaxTLSDur != nil {
+			c.MaxDuration = v.Claims.MaxTLSDur.Duration
+		}
+		if v.Claims.DefaultTLSDur != nil {
+			c.DefaultDuration = v.Claims.DefaultTLSDur.Duration
+		}
+		c.DisableRenewal = v.Claims.DisableRenewal
+		c.AllowRenewalAfterExpiry = v.Claims.AllowRenewalAfterExpiry
+	}
+	if v.Options != nil {
+		c.Template = v.Options.X509.HasTemplate()
+	}
+	return c, nil
+}
@@ -0,0 +1,106 @@
+package provisioners
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/smallstep/certificates/authority/provisioner"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStepProvisionerClaims(t *testing.T) {
+	disabled := true
+	jwk, key := newTestJWKProvisioner(t, "admin")
+	jwk.Claims = &provisioner.Claims{
+		MinTLSDur:      &provisioner.Duration{Duration: 10 * time.Minute},
+		MaxTLSDur:      &provisioner.Duration{Duration: 48 * time.Hour},
+		DefaultTLSDur:  &provisioner.Duration{Duration: 12 * time.Hour},
+		DisableRenewal: &disabled,
+	}
+	jwk.Options = &provisioner.Options{X509: &provisioner.X509Options{Template: `{"subject": {{ toJson .Subject }}}`}}
+	other, _ := newTestJWKProvisioner(t, "other")
+	testCA := newTestCA(t, provisioner.List{other, jwk})
+
+	newIssuer := func(name string) *Step {
+		s, err := NewFromStepIssuer(context.Background(), &api.StepIssuer{
+			ObjectMeta: metav1.ObjectMeta{Name: "issuer", Namespace: "default"},
+			Spec: api.StepIssuerSpec{
+				URL:      testCA.URL,
+				CABundle: testCA.RootPEM,
+				Provisioner: api.StepProvisioner{
+					Name:   name,
+					KeyID:  jwk.Key.KeyID,
+					KeyRef: &api.StepIssuerSecretKeySelector{},
+				},
+			},
+		}, Credentials{JWKKey: key})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return s
+	}
+
+	claims, err := newIssuer("admin").ProvisionerClaims(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := ProvisionerClaims{
+		Name: "admin", Type: "JWK",
+		MinDuration: 10 * time.Minute, MaxDuration: 48 * time.Hour, DefaultDuration: 12 * time.Hour,
+		Template: true,
+	}
+	if claims.DisableRenewal == nil || !*claims.DisableRenewal || claims.AllowRenewalAfterExpiry != nil {
+		t.Errorf("unexpected renewal claims %v, %v", claims.DisableRenewal, claims.AllowRenewalAfterExpiry)
+	}
+	claims.DisableRenewal = nil
+	if *claims != want {
+		t.Errorf("expected %+v, got %+v", want, *claims)
+	}
+
+	if _, err := newIssuer("missing").ProvisionerClaims(context.Background()); err == nil {
+		t.Error("expected an error reading the claims of a missing provisioner")
+	}
+}
+
+func TestProvisionerClaimsValidateDuration(t *testing.T) {
+	claims := NewProvisionerClaimsFromStepClusterIssuer(&api.StepClusterIssuer{
+		Status: api.StepClusterIssuerStatus{Provisioner: &api.StepClusterProvisionerStatus{
+			Name:               "admin",
+			Type:               "JWK",
+			MinTLSCertDuration: &metav1.Duration{Duration: 5 * time.Minute},
+			MaxTLSCertDuration: &metav1.Duration{Duration: 24 * time.Hour},
+		}},
+	})
+
+	tests := []struct {
+		name     string
+		duration *metav1.Duration
+		wantErr  bool
+	}{
+		{name: "no duration"},
+		{name: "allowed", duration: &metav1.Duration{Duration: time.Hour}},
+		{name: "too short", duration: &metav1.Duration{Duration: time.Minute}, wantErr: true},
+		{name: "too long", duration: &metav1.Duration{Duration: 48 * time.Hour}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := claims.ValidateDuration(&certmanager.CertificateRequest{
+				Spec: certmanager.CertificateRequestSpec{Duration: tt.duration},
+			})
+			if tt.wantErr && !errors.Is(err, ErrDurationNotAllowed) {
+				t.Fatalf("expected ErrDurationNotAllowed, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+
+	if c := NewProvisionerClaimsFromStepIssuer(&api.StepIssuer{}); c != nil {
+		t.Errorf("expected no claims, got %v", c)
+	}
+}
@@ -0,0 +1,271 @@
+package provisioners
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	capi "github.com/smallstep/certificates/api"
+	"github.com/smallstep/certificates/authority"
+	caconfig "github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/ca"
+	"github.com/smallstep/certificates/db"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/pemutil"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// embeddedShutdownTimeout is the time given to the requests in flight to an
+// embedded authority when it is replaced.
+const embeddedShutdownTimeout = 30 * time.Second
+
+// embeddedConfig configures a step certificates authority running in the
+// controller process.
+type embeddedConfig struct {
+	// options and claims are the JSON encoded options and claims of the
+	// generated JWK provisioner.
+	options []byte
+	claims  []byte
+}
+
+// newEmbeddedConfig returns the configuration of an embedded authority with
+// the options and claims of the spec of an issuer.
+func newEmbeddedConfig(options, claims *runtime.RawExtension) *embeddedConfig {
+	cfg := &embeddedConfig{}
+	if options != nil {
+		cfg.options = options.Raw
+	}
+	if claims != nil {
+		cfg.claims = claims.Raw
+	}
+	return cfg
+}
+
+// embeddedAuthority is a step certificates authority running in the
+// controller process. It serves the step certificates API on a loopback
+// address, so it is used with the same client as a remote CA.
+type embeddedAuthority struct {
+	url      string
+	rootPEM  []byte
+	key      *jose.JSONWebKey
+	kid      string
+	auth     *authority.Authority
+	renewer  *ca.TLSRenewer
+	server   *http.Server
+	dataDir  string
+	shutdown chan struct{}
+}
+
+// startEmbeddedAuthority starts a step certificates authority with the given
+// root, intermediate and intermediate key, and a JWK provisioner with the
+// given name and a key generated on each start.
+func startEmbeddedAuthority(provisionerName string, cfg *embeddedConfig, creds Credentials) (_ *embeddedAuthority, err error) {
+	roots, err := pemutil.ParseCertificateBundle(creds.EmbeddedRoot)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing embedded authority root: %w", err)
+	}
+	chain, err := pemutil.ParseCertificateBundle(creds.EmbeddedIntermediate)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing embedded authority intermediate: %w", err)
+	}
+	key, err := pemutil.Parse(creds.EmbeddedIntermediateKey)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing embedded authority intermediate key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("embedded authority intermediate key is not a private key")
+	}
+
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	if err != nil {
+		return nil, fmt.Errorf("error generating provisioner key: %w", err)
+	}
+	kid, err := jose.Thumbprint(jwk)
+	if err != nil {
+		return nil, fmt.Errorf("error calculating provisioner key thumbprint: %w", err)
+	}
+	pub := jwk.Public()
+	pub.KeyID = kid
+	p := &provisioner.JWK{
+		Type: "JWK",
+		Name: provisionerName,
+		Key:  &pub,
+	}
+	if len(cfg.options) > 0 {
+		if err := json.Unmarshal(cfg.options, &p.Options); err != nil {
+			return nil, fmt.Errorf("error parsing embedded authority options: %w", err)
+		}
+	}
+	if len(cfg.claims) > 0 {
+		if err := json.Unmarshal(cfg.claims, &p.Claims); err != nil {
+			return nil, fmt.Errorf("error parsing embedded authority claims: %w", err)
+		}
+	}
+
+	// The authority checks the audience of the tokens against its address,
+	// so the listener is created first.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("error starting embedded authority: %w", err)
+	}
+	dataDir, err := os.MkdirTemp("", "step-issuer-")
+	if err != nil {
+		l.Close()
+		return nil, fmt.Errorf("error creating embedded authority database: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			l.Close()
+			os.RemoveAll(dataDir)
+		}
+	}()
+
+	auth, err := authority.NewEmbedded(
+		authority.WithConfig(&caconfig.Config{
+			Address:  l.Addr().String(),
+			DNSNames: []string{"127.0.0.1"},
+			DB: &db.Config{
+				Type:       "bbolt",
+				DataSource: filepath.Join(dataDir, "db"),
+			},
+			AuthorityConfig: &caconfig.AuthConfig{
+				Provisioners: provisioner.List{p},
+			},
+		}),
+		authority.WithX509RootCerts(roots...),
+		authority.WithX509SignerChain(chain, signer),
+		authority.WithQuietInit(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating embedded authority: %w", err)
+	}
+
+	tlsCrt, err := auth.GetTLSCertificate()
+	if err != nil {
+		_ = auth.Shutdown()
+		return nil, fmt.Errorf("error creating embedded authority certificate: %w", err)
+	}
+	renewer, err := ca.NewTLSRenewer(tlsCrt, auth.GetTLSCertificate)
+	if err != nil {
+		_ = auth.Shutdown()
+		return nil, fmt.Errorf("error creating embedded authority certificate: %w", err)
+	}
+	renewer.Run()
+
+	// Clients can authenticate with their certificates to renew them.
+	clientCAs := x509.NewCertPool()
+	for _, crt := range append(roots, chain...) {
+		clientCAs.AddCert(crt)
+	}
+
+	mux := chi.NewRouter()
+	mux.Use(middleware.GetHead)
+	capi.Route(mux)
+	mux.Route("/1.0", func(r chi.Router) {
+		capi.Route(r)
+	})
+
+	baseContext := authority.NewContext(context.Background(), auth)
+	baseContext = db.NewContext(baseContext, auth.GetDatabase())
+	e := &embeddedAuthority{
+		url:     "https://" + l.Addr().String(),
+		rootPEM: encodeX509(roots...),
+		key:     jwk,
+		kid:     kid,
+		auth:    auth,
+		renewer: renewer,
+		server: &http.Server{
+			Handler: mux,
+			TLSConfig: &tls.Config{
+				GetCertificate: renewer.GetCertificateForCA,
+				ClientAuth:     tls.VerifyClientCertIfGiven,
+				ClientCAs:      clientCAs,
+				MinVersion:     tls.VersionTLS12,
+			},
+			BaseContext:       func(net.Listener) context.Context { return baseContext },
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		dataDir:  dataDir,
+		shutdown: make(chan struct{}),
+	}
+	go func() {
+		defer close(e.shutdown)
+		_ = e.server.ServeTLS(l, "", "")
+	}()
+	return e, nil
+}
+
+// newEmbeddedStep starts an embedded authority and returns a Step provisioner
+// that signs certificates with it.
+func newEmbeddedStep(cfg *config) (*Step, error) {
+	e, err := startEmbeddedAuthority(cfg.provisioner, cfg.embedded, cfg.creds)
+	if err != nil {
+		return nil, err
+	}
+	client, err := ca.NewClient(e.url, ca.WithCABundle(e.rootPEM))
+	if err != nil {
+		_ = e.Close()
+		return nil, err
+	}
+	audience, err := signAudience(client)
+	if err != nil {
+		_ = e.Close()
+		return nil, err
+	}
+	revokeAudience, err := revokeAudience(client)
+	if err != nil {
+		_ = e.Close()
+		return nil, err
+	}
+	return &Step{
+		name:        cfg.name,
+		provisioner: cfg.provisioner,
+		caBundle:    e.rootPEM,
+		caSource:    cfg.caSource,
+		client:      client,
+		tokens: &jwkKeyTokenSource{
+			name:           cfg.provisioner,
+			kid:            e.kid,
+			audience:       audience,
+			revokeAudience: revokeAudience,
+			fingerprint:    bundleFingerprint(e.rootPEM),
+			key:            e.key,
+		},
+		embedded:    e,
+		credentials: credentialsDigest(cfg.creds),
+
+		templateDataConfig: cfg.templateData,
+		subjectConfig:      cfg.subject,
+		renewal:            cfg.renewal,
+		backdate:           cfg.backdate,
+		exactSANs:          cfg.exactSANs,
+		sanMode:            cfg.sanMode,
+	}, nil
+}
+
+// Close stops the authority in the background, after the requests in flight
+// finish, and removes its database.
+func (e *embeddedAuthority) Close() error {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), embeddedShutdownTimeout)
+		defer cancel()
+		_ = e.server.Shutdown(ctx)
+		<-e.shutdown
+		e.renewer.Stop()
+		_ = e.auth.Shutdown()
+		_ = os.RemoveAll(e.dataDir)
+	}()
+	return nil
+}
@@ -0,0 +1,188 @@
+package provisioners
+
+import (
+	"context"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	"go.step.sm/crypto/minica"
+	"go.step.sm/crypto/pemutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestEmbeddedAuthority(t *testing.T) {
+	mca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBlock, err := pemutil.Serialize(mca.Signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := Credentials{
+		EmbeddedRoot:            pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mca.Root.Raw}),
+		EmbeddedIntermediate:    pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mca.Intermediate.Raw}),
+		EmbeddedIntermediateKey: pem.EncodeToMemory(keyBlock),
+	}
+
+	s, err := NewFromStepIssuer(context.Background(), &api.StepIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "issuer", Namespace: "default"},
+		Spec: api.StepIssuerSpec{
+			Provisioner: api.StepProvisioner{Name: "embedded"},
+			Embedded: &api.StepEmbeddedAuthority{
+				SecretName: "ca",
+				Options: &runtime.RawExtension{Raw: []byte(`{"x509":{"template":` +
+					`"{\"subject\":{\"commonName\":{{ toJson .Subject.CommonName }},\"organization\":\"Development\"},\"sans\":{{ toJson .SANs }}}"}}`)},
+				Claims: &runtime.RawExtension{Raw: []byte(`{"maxTLSCertDuration":"1h","defaultTLSCertDuration":"30m"}`)},
+			},
+		},
+	}, creds)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.Health(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cr := &certmanager.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "cr", Namespace: "default"},
+		Spec: certmanager.CertificateRequestSpec{
+			Request: newTestCSR(t, "example.com"),
+		},
+	}
+	chainPEM, caPEM, err := s.Sign(context.Background(), cr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	chain, err := pemutil.ParseCertificateBundle(chainPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots, err := pemutil.ParseCertificateBundle(caPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 1 || !roots[0].Equal(mca.Root) {
+		t.Errorf("expected the root of the embedded authority")
+	}
+	if _, err := issuingRoot(roots, chain); err != nil {
+		t.Errorf("certificate does not verify: %v", err)
+	}
+	if got := chain[0].Subject.Organization; len(got) != 1 || got[0] != "Development" {
+		t.Errorf("expected the organization set by the template, got %v", got)
+	}
+	if got := chain[0].NotAfter.Sub(chain[0].NotBefore); got != 31*time.Minute {
+		t.Errorf("expected the default duration of the claims, got %s", got)
+	}
+
+	// The claims are enforced by the authority.
+	cr.Spec.Duration = &metav1.Duration{Duration: 2 * time.Hour}
+	if _, _, err := s.Sign(context.Background(), cr); err == nil {
+		t.Error("expected an error signing a certificate longer than the maximum duration")
+	}
+
+	if err := s.Revoke(context.Background(), chain[0].SerialNumber.String(), 5, "deleted"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestEmbeddedAuthorityInvalidCredentials(t *testing.T) {
+	mca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mca.Root.Raw})
+	iss := &api.StepIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "issuer", Namespace: "default"},
+		Spec: api.StepIssuerSpec{
+			Provisioner: api.StepProvisioner{Name: "embedded"},
+			Embedded:    &api.StepEmbeddedAuthority{SecretName: "ca"},
+		},
+	}
+	tests := []struct {
+		name  string
+		creds Credentials
+	}{
+		{name: "no root", creds: Credentials{EmbeddedIntermediate: rootPEM, EmbeddedIntermediateKey: rootPEM}},
+		{name: "no intermediate", creds: Credentials{EmbeddedRoot: rootPEM, EmbeddedIntermediateKey: rootPEM}},
+		{name: "certificate as key", creds: Credentials{EmbeddedRoot: rootPEM, EmbeddedIntermediate: rootPEM, EmbeddedIntermediateKey: rootPEM}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewFromStepIssuer(context.Background(), iss, tt.creds); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestLoadUnchanged(t *testing.T) {
+	newCreds := func() Credentials {
+		mca, err := minica.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyBlock, err := pemutil.Serialize(mca.Signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return Credentials{
+			EmbeddedRoot:            pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mca.Root.Raw}),
+			EmbeddedIntermediate:    pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mca.Intermediate.Raw}),
+			EmbeddedIntermediateKey: pem.EncodeToMemory(keyBlock),
+		}
+	}
+	creds := newCreds()
+	key := types.NamespacedName{Namespace: "default", Name: "embedded"}
+	t.Cleanup(func() { Delete(key) })
+
+	if _, ok := LoadUnchanged(key, 1, creds); ok {
+		t.Error("expected no signer before one is stored")
+	}
+	Store(key, 1, new(closeSigner))
+	if _, ok := LoadUnchanged(key, 1, creds); ok {
+		t.Error("expected no signer without a Step provisioner")
+	}
+
+	s, err := NewFromStepIssuer(context.Background(), &api.StepIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+		Spec: api.StepIssuerSpec{
+			Provisioner: api.StepProvisioner{Name: "embedded"},
+			Embedded:    &api.StepEmbeddedAuthority{SecretName: "ca"},
+		},
+	}, creds)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	Store(key, 1, s)
+
+	// The running authority is kept while the generation and the
+	// credentials do not change.
+	if got, ok := LoadUnchanged(key, 1, creds); !ok || got != s {
+		t.Errorf("expected the stored signer, got %v, %v", got, ok)
+	}
+	if _, ok := LoadUnchanged(key, 2, creds); ok {
+		t.Error("expected no signer for a new generation")
+	}
+	if _, ok := LoadUnchanged(key, 1, newCreds()); ok {
+		t.Error("expected no signer for new credentials")
+	}
+
+	// The provisioners of a CA are kept while the password does not change,
+	// e.g. when the issuers with a passwordEnv are verified again.
+	jwk := &Step{credentials: credentialsDigest(Credentials{Password: []byte("password")})}
+	Store(key, 1, jwk)
+	if got, ok := LoadUnchanged(key, 1, Credentials{Password: []byte("password")}); !ok || got != jwk {
+		t.Errorf("expected the stored signer, got %v, %v", got, ok)
+	}
+	if _, ok := LoadUnchanged(key, 1, Credentials{Password: []byte("rotated")}); ok {
+		t.Error("expected no signer for a new password")
+	}
+}
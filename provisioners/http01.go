@@ -0,0 +1,31 @@
+package provisioners
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// http01Challenges contains the key authorizations of the pending http-01
+// challenges by token.
+var http01Challenges = new(sync.Map)
+
+// HTTP01Handler returns an http.Handler that answers the http-01 challenges of
+// the ACME orders in progress.
+func HTTP01Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.URL.Path, "/.well-known/acme-challenge/")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		v, ok := http01Challenges.Load(token)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, v.(string))
+	})
+}
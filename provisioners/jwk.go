@@ -0,0 +1,124 @@
+package provisioners
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/smallstep/certificates/ca"
+	"github.com/smallstep/cli-utils/token"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/pemutil"
+	"go.step.sm/crypto/x509util"
+)
+
+// ErrKeyIDMismatch is returned when the provisioner key in a Secret does not
+// match the configured kid.
+var ErrKeyIDMismatch = errors.New("provisioner key does not match kid")
+
+// jwkTokenSource creates tokens signed with the decrypted key of a JWK
+// provisioner.
+type jwkTokenSource struct {
+	provisioner *ca.Provisioner
+}
+
+func (s *jwkTokenSource) Token(_ context.Context, req *tokenRequest) (string, error) {
+	return s.provisioner.Token(req.subject, req.sans...)
+}
+
+func (s *jwkTokenSource) RevokeToken(_ context.Context, serial string) (string, error) {
+	// The audience of the shared provisioner cannot be changed, use a copy.
+	p := *s.provisioner
+	audience, err := revokeAudience(p.Client)
+	if err != nil {
+		return "", err
+	}
+	p.SetAudience(audience)
+	return p.Token(serial)
+}
+
+// jwkKeyTokenSource creates tokens for a JWK provisioner with a private key
+// loaded from a Secret. Unlike jwkTokenSource, it does not need the CA to
+// create tokens.
+type jwkKeyTokenSource struct {
+	name           string
+	kid            string
+	audience       string
+	revokeAudience string
+	fingerprint    string
+	key            *jose.JSONWebKey
+}
+
+// newJWKKeyTokenSource parses the given provisioner key, decrypting it with
+// the password if it is encrypted, and checks that it matches the kid.
+func newJWKKeyTokenSource(client *ca.Client, name, kid string, caBundle, keyData, password []byte) (*jwkKeyTokenSource, error) {
+	var opts []jose.Option
+	if len(password) > 0 {
+		opts = append(opts, jose.WithPassword(password))
+	}
+	key, err := jose.ParseKey(keyData, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing provisioner key: %w", err)
+	}
+	if key.IsPublic() {
+		return nil, fmt.Errorf("provisioner key is not a private key")
+	}
+	if key.Algorithm == "" {
+		return nil, fmt.Errorf("provisioner key does not have an algorithm")
+	}
+
+	// The kid of step provisioners is the thumbprint of the key, but a JWK
+	// can also carry its own kid.
+	thumbprint, err := jose.Thumbprint(key)
+	if err != nil {
+		return nil, fmt.Errorf("error calculating provisioner key thumbprint: %w", err)
+	}
+	if kid != thumbprint && kid != key.KeyID {
+		return nil, fmt.Errorf("%w: expected %s, got %s", ErrKeyIDMismatch, kid, thumbprint)
+	}
+
+	audience, err := signAudience(client)
+	if err != nil {
+		return nil, err
+	}
+	revokeAudience, err := revokeAudience(client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jwkKeyTokenSource{
+		name:           name,
+		kid:            kid,
+		audience:       audience,
+		revokeAudience: revokeAudience,
+		fingerprint:    bundleFingerprint(caBundle),
+		key:            key,
+	}, nil
+}
+
+func (s *jwkKeyTokenSource) Token(_ context.Context, req *tokenRequest) (string, error) {
+	return signToken(req.subject, req.sans, s.name, s.audience, s.fingerprint, s.key.Algorithm, s.key.Key,
+		token.WithKid(s.kid))
+}
+
+func (s *jwkKeyTokenSource) RevokeToken(_ context.Context, serial string) (string, error) {
+	return signToken(serial, nil, s.name, s.revokeAudience, s.fingerprint, s.key.Algorithm, s.key.Key,
+		token.WithKid(s.kid))
+}
+
+// bundleFingerprint returns the fingerprint of the first self-signed
+// certificate in the given PEM bundle, or an empty string if there is none.
+// It is used instead of client.RootFingerprint to avoid a request to the CA.
+func bundleFingerprint(caBundle []byte) string {
+	certs, err := pemutil.ParseCertificateBundle(caBundle)
+	if err != nil {
+		return ""
+	}
+	for _, crt := range certs {
+		if bytes.Equal(crt.RawIssuer, crt.RawSubject) && crt.CheckSignatureFrom(crt) == nil {
+			return x509util.Fingerprint(crt)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package provisioners
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"github.com/smallstep/certificates/ca"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/minica"
+	"go.step.sm/crypto/x509util"
+)
+
+func TestJWKKeyTokenSource(t *testing.T) {
+	mca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mca.Intermediate.Raw})
+	caBundle = append(caBundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mca.Root.Raw})...)
+
+	// The CA is not reachable, tokens must be created offline.
+	client, err := ca.NewClient("https://127.0.0.1:1", ca.WithCABundle(caBundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, err := jose.Thumbprint(jwk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := json.Marshal(jwk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwe, err := jose.EncryptJWK(jwk, []byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := jwe.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		kid          string
+		key          []byte
+		password     []byte
+		wantErr      bool
+		wantMismatch bool
+	}{
+		{name: "plain", kid: kid, key: plain},
+		{name: "encrypted", kid: kid, key: []byte(encrypted), password: []byte("password")},
+		{name: "encrypted without password", kid: kid, key: []byte(encrypted), wantErr: true},
+		{name: "wrong password", kid: kid, key: []byte(encrypted), password: []byte("wrong"), wantErr: true},
+		{name: "kid mismatch", kid: "other-kid", key: plain, wantErr: true, wantMismatch: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newJWKKeyTokenSource(client, "admin", tt.kid, caBundle, tt.key, tt.password)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				if got := errors.Is(err, ErrKeyIDMismatch); got != tt.wantMismatch {
+					t.Fatalf("expected ErrKeyIDMismatch %v, got %v", tt.wantMismatch, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			tok, err := s.Token(context.Background(), &tokenRequest{subject: "example.com", sans: []string{"example.com"}})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			jwt, err := jose.ParseSigned(tok)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := jwt.Headers[0].KeyID; got != kid {
+				t.Errorf("expected kid %s, got %s", kid, got)
+			}
+			var claims struct {
+				jose.Claims
+				SHA string `json:"sha"`
+			}
+			if err := jwt.Claims(jwk.Public().Key, &claims); err != nil {
+				t.Fatalf("error verifying token: %v", err)
+			}
+			if claims.Issuer != "admin" || claims.Audience[0] != "https://127.0.0.1:1/1.0/sign" {
+				t.Errorf("unexpected claims %+v", claims.Claims)
+			}
+			if want := x509util.Fingerprint(mca.Root); claims.SHA != want {
+				t.Errorf("expected sha %s, got %s", want, claims.SHA)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package provisioners
+
+import (
+	"context"
+	"fmt"
+)
+
+// k8sSAConfig contains the configuration of a K8sSA provisioner.
+type k8sSAConfig struct {
+	namespace           string
+	name                string
+	useRequestNamespace bool
+}
+
+// k8sSATokenSource uses Kubernetes ServiceAccount tokens as the one-time tokens
+// of a K8sSA provisioner.
+//
+// The K8sSA provisioner of step certificates only accepts the tokens issued by
+// "kubernetes/serviceaccount" without a CA audience, and verifies them with the
+// public keys in its configuration. These are the tokens that the Kubernetes
+// token controller writes in the Secrets of type
+// kubernetes.io/service-account-token, not the ones of the TokenRequest API,
+// that have the issuer of the cluster and the requested audiences.
+type k8sSATokenSource struct {
+	config *k8sSAConfig
+	read   ServiceAccountTokenFunc
+}
+
+// newK8sSATokenSource returns a token source that reads the tokens of the
+// configured ServiceAccount.
+func newK8sSATokenSource(cfg *k8sSAConfig, read ServiceAccountTokenFunc) (*k8sSATokenSource, error) {
+	if read == nil {
+		return nil, fmt.Errorf("K8sSA provisioner requires a ServiceAccount token reader")
+	}
+	return &k8sSATokenSource{
+		config: cfg,
+		read:   read,
+	}, nil
+}
+
+// Token returns the token of the ServiceAccount. If configured, the token is
+// read in the namespace of the CertificateRequest.
+func (s *k8sSATokenSource) Token(ctx context.Context, req *tokenRequest) (string, error) {
+	namespace := s.config.namespace
+	if s.config.useRequestNamespace {
+		namespace = req.namespace
+	}
+	return s.read(ctx, namespace, s.config.name)
+}
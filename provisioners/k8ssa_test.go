@@ -0,0 +1,131 @@
+package provisioners
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/smallstep/certificates/authority/provisioner"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/pemutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newTestServiceAccountToken returns a token like the ones written by the
+// Kubernetes token controller in the Secrets of type
+// kubernetes.io/service-account-token, signed with the given key.
+func newTestServiceAccountToken(t *testing.T, key *ecdsa.PrivateKey, issuer, namespace, name string) string {
+	t.Helper()
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, new(jose.SignerOptions).WithType("JWT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := jose.Signed(signer).Claims(map[string]any{
+		"iss":                                    issuer,
+		"sub":                                    "system:serviceaccount:" + namespace + ":" + name,
+		"kubernetes.io/serviceaccount/namespace": namespace,
+		"kubernetes.io/serviceaccount/secret.name":          name + "-token",
+		"kubernetes.io/serviceaccount/service-account.name": name,
+		"kubernetes.io/serviceaccount/service-account.uid":  "3f1c6b9e-6b1a-4a55-9d8e-2f1b8f9c0a11",
+	}).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+func TestK8sSATokenSource(t *testing.T) {
+	// The public key of the cluster signing the ServiceAccount tokens is
+	// configured in the K8sSA provisioner of the CA.
+	clusterKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubBlock, err := pemutil.Serialize(clusterKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCA := newTestCA(t, provisioner.List{&provisioner.K8sSA{
+		Type:    "K8sSA",
+		Name:    "k8ssa",
+		PubKeys: pem.EncodeToMemory(pubBlock),
+	}})
+
+	tests := []struct {
+		name                string
+		useRequestNamespace bool
+		issuer              string
+		wantNamespace       string
+		wantErr             bool
+	}{
+		{name: "issuer namespace", issuer: "kubernetes/serviceaccount", wantNamespace: "step"},
+		{name: "request namespace", useRequestNamespace: true, issuer: "kubernetes/serviceaccount", wantNamespace: "workload"},
+		// The TokenRequest API issues tokens with the issuer of the cluster,
+		// that the CA rejects.
+		{name: "token request issuer", issuer: "https://kubernetes.default.svc.cluster.local", wantNamespace: "step", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotNamespace, gotName string
+			read := func(_ context.Context, namespace, name string) (string, error) {
+				gotNamespace, gotName = namespace, name
+				return newTestServiceAccountToken(t, clusterKey, tt.issuer, namespace, name), nil
+			}
+			s, err := NewFromStepIssuer(context.Background(), &api.StepIssuer{
+				ObjectMeta: metav1.ObjectMeta{Name: "issuer", Namespace: "step"},
+				Spec: api.StepIssuerSpec{
+					URL:      testCA.URL,
+					CABundle: testCA.RootPEM,
+					Provisioner: api.StepProvisioner{
+						Name: "k8ssa",
+						K8sSA: &api.StepK8sSAProvisioner{
+							ServiceAccountName:  "step-issuer",
+							UseRequestNamespace: tt.useRequestNamespace,
+						},
+					},
+				},
+			}, Credentials{ServiceAccountToken: read})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			chainPEM, _, err := s.Sign(context.Background(), &certmanager.CertificateRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "cr", Namespace: "workload"},
+				Spec:       certmanager.CertificateRequestSpec{Request: newTestCSR(t, "example.com")},
+			})
+			if gotNamespace != tt.wantNamespace || gotName != "step-issuer" {
+				t.Errorf("expected the token of %s/step-issuer, got %s/%s", tt.wantNamespace, gotNamespace, gotName)
+			}
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "lacked necessary authorization") {
+					t.Fatalf("expected the CA to reject the token, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			chain, err := pemutil.ParseCertificateBundle(chainPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := chain[0].DNSNames; len(got) != 1 || got[0] != "example.com" {
+				t.Errorf("expected the SANs of the request, got %v", got)
+			}
+			if d := time.Until(chain[0].NotAfter); d <= 0 {
+				t.Errorf("expected a valid certificate, expires in %s", d)
+			}
+		})
+	}
+
+	if _, err := newK8sSATokenSource(&k8sSAConfig{name: "step-issuer"}, nil); err == nil {
+		t.Error("expected an error without a token reader")
+	}
+}
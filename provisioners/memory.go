@@ -0,0 +1,151 @@
+package provisioners
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"time"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+)
+
+const (
+	// memoryCAValidity is the validity of the root and intermediate
+	// certificates of a MemoryCA.
+	memoryCAValidity = 10 * 365 * 24 * time.Hour
+
+	// memoryCADefaultDuration is the duration of the certificates signed by a
+	// MemoryCA if the request does not have one.
+	memoryCADefaultDuration = 24 * time.Hour
+)
+
+// MemoryCA is a Signer backed by a CA that only lives in memory. Its root is
+// generated when it is created and lost when the controller restarts, so it
+// must only be used in development clusters and tests.
+type MemoryCA struct {
+	root         *x509.Certificate
+	intermediate *x509.Certificate
+	key          crypto.Signer
+}
+
+// NewMemoryCA creates a new root and intermediate CA with the given name.
+func NewMemoryCA(name string) (*MemoryCA, error) {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("error generating root key: %w", err)
+	}
+	intKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("error generating intermediate key: %w", err)
+	}
+
+	now := time.Now()
+	rootTemplate := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: name + " Root CA"},
+		NotBefore:             now,
+		NotAfter:              now.Add(memoryCAValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLen:            1,
+	}
+	root, err := createCertificate(rootTemplate, rootTemplate, rootKey.Public(), rootKey)
+	if err != nil {
+		return nil, fmt.Errorf("error creating root certificate: %w", err)
+	}
+	intermediate, err := createCertificate(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: name + " Intermediate CA"},
+		NotBefore:             now,
+		NotAfter:              now.Add(memoryCAValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}, root, intKey.Public(), rootKey)
+	if err != nil {
+		return nil, fmt.Errorf("error creating intermediate certificate: %w", err)
+	}
+
+	return &MemoryCA{
+		root:         root,
+		intermediate: intermediate,
+		key:          intKey,
+	}, nil
+}
+
+// Sign signs the certificate request with the intermediate CA. The
+// certificate has the subject and SANs of the request, and the requested
+// duration, usages and basic constraints.
+func (c *MemoryCA) Sign(_ context.Context, cr *certmanager.CertificateRequest, _ ...SignOption) ([]byte, []byte, error) {
+	csr, err := decodeCSR(cr.Spec.Request)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyUsage, extKeyUsage, err := keyUsages(cr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	duration := memoryCADefaultDuration
+	if cr.Spec.Duration != nil {
+		duration = cr.Spec.Duration.Duration
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		Subject:               csr.Subject,
+		DNSNames:              csr.DNSNames,
+		EmailAddresses:        csr.EmailAddresses,
+		IPAddresses:           csr.IPAddresses,
+		URIs:                  csr.URIs,
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(duration),
+		KeyUsage:              keyUsage,
+		ExtKeyUsage:           extKeyUsage,
+		BasicConstraintsValid: true,
+		IsCA:                  cr.Spec.IsCA,
+	}
+	if template.NotAfter.After(c.intermediate.NotAfter) {
+		template.NotAfter = c.intermediate.NotAfter
+	}
+
+	crt, err := createCertificate(template, c.intermediate, csr.PublicKey, c.key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error signing certificate: %w", err)
+	}
+	return encodeX509(crt, c.intermediate), encodeX509(c.root), nil
+}
+
+// Health always succeeds, the in-memory CA is always available.
+func (c *MemoryCA) Health(context.Context) error {
+	return nil
+}
+
+// Roots returns the root certificate of the in-memory CA.
+func (c *MemoryCA) Roots(context.Context) ([]*x509.Certificate, error) {
+	return []*x509.Certificate{c.root}, nil
+}
+
+// Close does nothing, the in-memory CA does not hold any resources.
+func (c *MemoryCA) Close() error {
+	return nil
+}
+
+// createCertificate signs the template with a random serial number.
+func createCertificate(template, parent *x509.Certificate, pub crypto.PublicKey, signer crypto.Signer) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	template.SerialNumber = serial
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, signer)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
@@ -0,0 +1,79 @@
+package provisioners
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"go.step.sm/crypto/pemutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMemoryCA(t *testing.T) {
+	ca, err := NewMemoryCA("test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	roots, err := ca.Roots(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(roots[0])
+
+	tests := []struct {
+		name     string
+		usages   []certmanager.KeyUsage
+		isCA     bool
+		duration time.Duration
+		want     time.Duration
+	}{
+		{name: "default", want: memoryCADefaultDuration},
+		{name: "client auth", usages: []certmanager.KeyUsage{certmanager.UsageDigitalSignature, certmanager.UsageClientAuth},
+			duration: time.Hour, want: time.Hour},
+		{name: "ca", isCA: true, want: memoryCADefaultDuration},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr := &certmanager.CertificateRequest{
+				Spec: certmanager.CertificateRequestSpec{
+					Request: newTestCSR(t, "example.com"),
+					Usages:  tt.usages,
+					IsCA:    tt.isCA,
+				},
+			}
+			if tt.duration != 0 {
+				cr.Spec.Duration = &metav1.Duration{Duration: tt.duration}
+			}
+			chainPEM, caPEM, err := ca.Sign(context.Background(), cr)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(caPEM) == 0 {
+				t.Error("expected the root certificate")
+			}
+			chain, err := pemutil.ParseCertificateBundle(chainPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+			intermediates := x509.NewCertPool()
+			intermediates.AddCert(chain[1])
+			if _, err := chain[0].Verify(x509.VerifyOptions{
+				Roots:         pool,
+				Intermediates: intermediates,
+				DNSName:       "example.com",
+				KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+			}); err != nil {
+				t.Errorf("certificate does not verify: %v", err)
+			}
+			if err := verifyUsages(chain[0], cr); err != nil {
+				t.Error(err)
+			}
+			if got := chain[0].NotAfter.Sub(chain[0].NotBefore); got != tt.want+time.Minute {
+				t.Errorf("expected duration %s, got %s", tt.want+time.Minute, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package provisioners
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// oidcConfig contains the configuration of an OIDC provisioner.
+type oidcConfig struct {
+	issuerURL string
+	clientID  string
+	scopes    []string
+}
+
+// oidcTokenSource gets ID tokens from an OpenID Connect provider using the
+// client credentials grant. The ID tokens are used as the one-time tokens of
+// an OIDC provisioner.
+type oidcTokenSource struct {
+	client *http.Client
+	config clientcredentials.Config
+}
+
+// newOIDCTokenSource discovers the token endpoint of the configured issuer and
+// returns a token source for it.
+func newOIDCTokenSource(cfg *oidcConfig, clientSecret []byte) (*oidcTokenSource, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	tokenURL, err := discoverTokenEndpoint(client, cfg.issuerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oidcTokenSource{
+		client: client,
+		config: clientcredentials.Config{
+			ClientID:     cfg.clientID,
+			ClientSecret: string(clientSecret),
+			TokenURL:     tokenURL,
+			Scopes:       append([]string{"openid"}, cfg.scopes...),
+		},
+	}, nil
+}
+
+// Token requests a new ID token to the OpenID Connect provider. The subject and
+// SANs are not used, the provider decides the identity in the token.
+func (s *oidcTokenSource) Token(ctx context.Context, _ *tokenRequest) (string, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, s.client)
+	tok, err := s.config.Token(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error getting OIDC token: %w", err)
+	}
+	idToken, ok := tok.Extra("id_token").(string)
+	if !ok || idToken == "" {
+		return "", fmt.Errorf("OIDC token response does not contain an id_token")
+	}
+	return idToken, nil
+}
+
+// discoverTokenEndpoint returns the token endpoint in the OpenID Connect
+// discovery document of the given issuer.
+func discoverTokenEndpoint(client *http.Client, issuerURL string) (string, error) {
+	u := strings.TrimSuffix(issuerURL, "/") + "/.well-known/openid-configuration"
+	resp, err := client.Get(u)
+	if err != nil {
+		return "", fmt.Errorf("error getting OIDC configuration: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error getting OIDC configuration: %s returned %s", u, resp.Status)
+	}
+
+	var doc struct {
+		Issuer        string `json:"issuer"`
+		TokenEndpoint string `json:"token_endpoint"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return "", fmt.Errorf("error decoding OIDC configuration: %w", err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != strings.TrimSuffix(issuerURL, "/") {
+		return "", fmt.Errorf("OIDC configuration issuer %q does not match %q", doc.Issuer, issuerURL)
+	}
+	if doc.TokenEndpoint == "" {
+		return "", fmt.Errorf("OIDC configuration does not contain a token_endpoint")
+	}
+	return doc.TokenEndpoint, nil
+}
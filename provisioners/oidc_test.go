@@ -0,0 +1,109 @@
+package provisioners
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newOIDCServer starts a minimal OpenID Connect provider that issues the given
+// ID token to the client "step-issuer" with the secret "s3cr3t".
+func newOIDCServer(t *testing.T, idToken string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":         srv.URL,
+			"token_endpoint": srv.URL + "/token",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "step-issuer" || secret != "s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.FormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resp := map[string]string{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+		}
+		if idToken != "" {
+			resp["id_token"] = idToken
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+
+	return srv
+}
+
+func TestOIDCTokenSource(t *testing.T) {
+	srv := newOIDCServer(t, "the-id-token")
+
+	s, err := newOIDCTokenSource(&oidcConfig{issuerURL: srv.URL, clientID: "step-issuer"}, []byte("s3cr3t"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := s.Token(context.Background(), &tokenRequest{subject: "subject"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "the-id-token" {
+		t.Fatalf("expected the-id-token, got %q", got)
+	}
+}
+
+func TestOIDCTokenSourceWrongSecret(t *testing.T) {
+	srv := newOIDCServer(t, "the-id-token")
+
+	s, err := newOIDCTokenSource(&oidcConfig{issuerURL: srv.URL, clientID: "step-issuer"}, []byte("wrong"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.Token(context.Background(), &tokenRequest{subject: "subject"}); err == nil {
+		t.Fatal("expected an error with the wrong client secret")
+	}
+}
+
+func TestOIDCTokenSourceNoIDToken(t *testing.T) {
+	srv := newOIDCServer(t, "")
+
+	s, err := newOIDCTokenSource(&oidcConfig{issuerURL: srv.URL, clientID: "step-issuer"}, []byte("s3cr3t"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.Token(context.Background(), &tokenRequest{subject: "subject"}); err == nil {
+		t.Fatal("expected an error when the response does not contain an id_token")
+	}
+}
+
+func TestOIDCTokenSourceIssuerMismatch(t *testing.T) {
+	// The discovery document of the configured URL advertises another issuer.
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	mux.HandleFunc("/other/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":         srv.URL,
+			"token_endpoint": srv.URL + "/token",
+		})
+	})
+
+	_, err := newOIDCTokenSource(&oidcConfig{issuerURL: srv.URL + "/other", clientID: "step-issuer"}, []byte("s3cr3t"))
+	if err == nil {
+		t.Fatal("expected an error when the discovery document has another issuer")
+	}
+	if want := fmt.Sprintf("OIDC configuration issuer %q does not match %q", srv.URL, srv.URL+"/other"); err.Error() != want {
+		t.Fatalf("expected %q, got %q", want, err)
+	}
+}
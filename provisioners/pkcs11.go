@@ -0,0 +1,213 @@
+package provisioners
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/smallstep/certificates/ca"
+	"github.com/smallstep/cli-utils/token"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/kms/apiv1"
+	"go.step.sm/crypto/kms/pkcs11"
+	"go.step.sm/crypto/kms/uri"
+)
+
+// pkcs11Config contains the location of a JWK provisioner key in a PKCS#11
+// token.
+type pkcs11Config struct {
+	modulePath string
+	tokenLabel string
+	slotID     *int
+	keyLabel   string
+}
+
+// uri returns the PKCS#11 URI of the token.
+func (c *pkcs11Config) uri() string {
+	v := url.Values{}
+	v.Set("module-path", c.modulePath)
+	if c.tokenLabel != "" {
+		v.Set("token", c.tokenLabel)
+	}
+	if c.slotID != nil {
+		v.Set("slot-id", strconv.Itoa(*c.slotID))
+	}
+	return uri.New(string(apiv1.PKCS11), v).String()
+}
+
+// pkcs11KeyManager is the part of the PKCS#11 key manager used to sign tokens.
+type pkcs11KeyManager interface {
+	CreateSigner(req *apiv1.CreateSignerRequest) (crypto.Signer, error)
+	Close() error
+}
+
+// newPKCS11KeyManager opens a PKCS#11 module. Without cgo it always fails, see
+// PKCS11Supported.
+var newPKCS11KeyManager = func(ctx context.Context, opts apiv1.Options) (pkcs11KeyManager, error) {
+	return pkcs11.New(ctx, opts)
+}
+
+// pkcs11Module is a PKCS#11 module opened and logged in with a PIN, and the
+// number of issuers using it.
+type pkcs11Module struct {
+	uri   string
+	pin   string
+	km    pkcs11KeyManager
+	users int
+}
+
+// pkcs11Modules contains the open PKCS#11 modules by URI. Modules are shared
+// by all the issuers using the same token, and closed when none of them uses
+// it any more.
+var (
+	pkcs11Mu      sync.Mutex
+	pkcs11Modules = map[string]*pkcs11Module{}
+)
+
+// acquirePKCS11Module returns the open module for the given token, opening it
+// if necessary. If the PIN changed, the module is opened again to log in with
+// the new PIN, and the previous one is closed when its last issuer releases
+// it. The module must be released when it is no longer used.
+func acquirePKCS11Module(ctx context.Context, tokenURI string, pin []byte) (*pkcs11Module, error) {
+	pkcs11Mu.Lock()
+	defer pkcs11Mu.Unlock()
+
+	if m, ok := pkcs11Modules[tokenURI]; ok && m.pin == string(pin) {
+		m.users++
+		return m, nil
+	}
+	km, err := newPKCS11KeyManager(ctx, apiv1.Options{
+		Type: apiv1.PKCS11,
+		URI:  tokenURI,
+		Pin:  string(pin),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error opening PKCS#11 module: %w", err)
+	}
+	m := &pkcs11Module{uri: tokenURI, pin: string(pin), km: km, users: 1}
+	pkcs11Modules[tokenURI] = m
+	return m, nil
+}
+
+// release closes the module if no other issuer uses it.
+func (m *pkcs11Module) release() {
+	pkcs11Mu.Lock()
+	defer pkcs11Mu.Unlock()
+
+	if m.users--; m.users > 0 {
+		return
+	}
+	if pkcs11Modules[m.uri] == m {
+		delete(pkcs11Modules, m.uri)
+	}
+	_ = m.km.Close()
+}
+
+// pkcs11TokenSource creates tokens for a JWK provisioner whose private key is
+// stored in a PKCS#11 token. The signatures are computed by the token.
+type pkcs11TokenSource struct {
+	name           string
+	kid            string
+	audience       string
+	revokeAudience string
+	fingerprint    string
+	alg            string
+	signer         crypto.Signer
+	module         *pkcs11Module
+}
+
+// newPKCS11TokenSource finds the provisioner key in the PKCS#11 token and
+// checks that it matches the kid.
+func newPKCS11TokenSource(client *ca.Client, name, kid string, caBundle []byte, cfg *pkcs11Config, pin []byte) (_ *pkcs11TokenSource, err error) {
+	module, err := acquirePKCS11Module(context.Background(), cfg.uri(), pin)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			module.release()
+		}
+	}()
+	signer, err := module.km.CreateSigner(&apiv1.CreateSignerRequest{
+		SigningKey: uri.New(string(apiv1.PKCS11), url.Values{"object": []string{cfg.keyLabel}}).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error loading PKCS#11 key %s: %w", cfg.keyLabel, err)
+	}
+
+	alg, err := signatureAlgorithm(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	thumbprint, err := jose.Thumbprint(&jose.JSONWebKey{Key: signer.Public()})
+	if err != nil {
+		return nil, fmt.Errorf("error calculating provisioner key thumbprint: %w", err)
+	}
+	if kid != thumbprint {
+		return nil, fmt.Errorf("%w: expected %s, got %s", ErrKeyIDMismatch, kid, thumbprint)
+	}
+
+	audience, err := signAudience(client)
+	if err != nil {
+		return nil, err
+	}
+	revokeAudience, err := revokeAudience(client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pkcs11TokenSource{
+		name:           name,
+		kid:            kid,
+		audience:       audience,
+		revokeAudience: revokeAudience,
+		fingerprint:    bundleFingerprint(caBundle),
+		alg:            alg,
+		signer:         signer,
+		module:         module,
+	}, nil
+}
+
+func (s *pkcs11TokenSource) Token(_ context.Context, req *tokenRequest) (string, error) {
+	return signToken(req.subject, req.sans, s.name, s.audience, s.fingerprint, s.alg, s.signer,
+		token.WithKid(s.kid))
+}
+
+func (s *pkcs11TokenSource) RevokeToken(_ context.Context, serial string) (string, error) {
+	return signToken(serial, nil, s.name, s.revokeAudience, s.fingerprint, s.alg, s.signer,
+		token.WithKid(s.kid))
+}
+
+// Close releases the PKCS#11 module.
+func (s *pkcs11TokenSource) Close() error {
+	s.module.release()
+	return nil
+}
+
+// signatureAlgorithm returns the JWS algorithm used with the given public
+// key, it matches the defaults of the step JWK provisioners.
+func signatureAlgorithm(pub crypto.PublicKey) (string, error) {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return jose.ES256, nil
+		case elliptic.P384():
+			return jose.ES384, nil
+		case elliptic.P521():
+			return jose.ES512, nil
+		}
+	case *rsa.PublicKey:
+		return jose.RS256, nil
+	case ed25519.PublicKey:
+		return jose.EdDSA, nil
+	}
+	return "", fmt.Errorf("unsupported provisioner key type %T", pub)
+}
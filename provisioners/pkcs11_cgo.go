@@ -0,0 +1,7 @@
+//go:build cgo && !nopkcs11
+
+package provisioners
+
+// PKCS11Supported reports whether the controller is built with PKCS#11
+// support, which requires cgo.
+const PKCS11Supported = true
@@ -0,0 +1,86 @@
+//go:build cgo && softhsm2
+
+package provisioners
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/kms/apiv1"
+	"go.step.sm/crypto/kms/pkcs11"
+)
+
+// softHSM2Module returns the path of the SoftHSM module.
+func softHSM2Module(t *testing.T) string {
+	t.Helper()
+	if path := os.Getenv("SOFTHSM2_MODULE"); path != "" {
+		return path
+	}
+	for _, path := range []string{"/usr/local/lib/softhsm/libsofthsm2.so", "/usr/lib/softhsm/libsofthsm2.so"} {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	t.Skip("SoftHSM module not found, set SOFTHSM2_MODULE")
+	return ""
+}
+
+// TestPKCS11TokenSourceSoftHSM2 requires softhsm2-util and the SoftHSM module,
+// and runs with:
+//
+//	go test -tags softhsm2 ./provisioners/...
+func TestPKCS11TokenSourceSoftHSM2(t *testing.T) {
+	module := softHSM2Module(t)
+
+	// Initialize a token in a temporary directory.
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "softhsm2.conf")
+	if err := os.WriteFile(conf, []byte("directories.tokendir = "+dir+"\nobjectstore.backend = file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOFTHSM2_CONF", conf)
+	out, err := exec.Command("softhsm2-util", "--init-token", "--free",
+		"--label", "step-issuer", "--pin", "1234", "--so-pin", "5678").CombinedOutput()
+	if err != nil {
+		t.Fatalf("error initializing token: %v: %s", err, out)
+	}
+
+	cfg := &pkcs11Config{modulePath: module, tokenLabel: "step-issuer", keyLabel: "step-provisioner"}
+	km, err := pkcs11.New(context.Background(), apiv1.Options{Type: apiv1.PKCS11, URI: cfg.uri(), Pin: "1234"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = km.Close() })
+	resp, err := km.CreateKey(&apiv1.CreateKeyRequest{
+		Name:               "pkcs11:id=7331;object=step-provisioner",
+		SignatureAlgorithm: apiv1.ECDSAWithSHA256,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, err := jose.Thumbprint(&jose.JSONWebKey{Key: resp.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := newPKCS11TokenSource(newTestClient(t), "admin", kid, nil, cfg, []byte("1234"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tok, err := s.Token(context.Background(), &tokenRequest{subject: "example.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	jwt, err := jose.ParseSigned(tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims jose.Claims
+	if err := jwt.Claims(resp.PublicKey, &claims); err != nil {
+		t.Fatalf("error verifying token: %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package provisioners
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/kms/apiv1"
+)
+
+// hsmSigner hides the type of a private key, like the signers of a PKCS#11
+// module.
+type hsmSigner struct {
+	crypto.Signer
+}
+
+// fakeKeyManager is a PKCS#11 key manager with in-memory keys by URI.
+type fakeKeyManager struct {
+	signers map[string]crypto.Signer
+	closed  int
+}
+
+func (m *fakeKeyManager) CreateSigner(req *apiv1.CreateSignerRequest) (crypto.Signer, error) {
+	s, ok := m.signers[req.SigningKey]
+	if !ok {
+		return nil, fmt.Errorf("key %s not found", req.SigningKey)
+	}
+	return hsmSigner{s}, nil
+}
+
+func (m *fakeKeyManager) Close() error {
+	m.closed++
+	return nil
+}
+
+// setFakeKeyManager replaces the PKCS#11 modules with the given key manager,
+// that accepts the given PINs, and returns a pointer to the number of modules
+// opened.
+func setFakeKeyManager(t *testing.T, km pkcs11KeyManager, pins ...string) *int {
+	t.Helper()
+	var opened int
+	newFn := newPKCS11KeyManager
+	newPKCS11KeyManager = func(_ context.Context, opts apiv1.Options) (pkcs11KeyManager, error) {
+		if !slices.Contains(pins, opts.Pin) {
+			return nil, errors.New("invalid pin")
+		}
+		opened++
+		return km, nil
+	}
+	pkcs11Modules = map[string]*pkcs11Module{}
+	t.Cleanup(func() {
+		newPKCS11KeyManager = newFn
+		pkcs11Modules = map[string]*pkcs11Module{}
+	})
+	return &opened
+}
+
+func TestPKCS11TokenSource(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, err := jose.Thumbprint(&jose.JSONWebKey{Key: key.Public()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened := setFakeKeyManager(t, &fakeKeyManager{signers: map[string]crypto.Signer{
+		"pkcs11:object=step-provisioner": key,
+	}}, "1234")
+	client := newTestClient(t)
+	cfg := &pkcs11Config{modulePath: "/usr/lib/softhsm/libsofthsm2.so", tokenLabel: "step", keyLabel: "step-provisioner"}
+
+	tests := []struct {
+		name         string
+		kid          string
+		keyLabel     string
+		pin          string
+		wantErr      bool
+		wantMismatch bool
+	}{
+		{name: "ok", kid: kid, keyLabel: "step-provisioner", pin: "1234"},
+		{name: "kid mismatch", kid: "other-kid", keyLabel: "step-provisioner", pin: "1234", wantErr: true, wantMismatch: true},
+		{name: "key not found", kid: kid, keyLabel: "other", pin: "1234", wantErr: true},
+		{name: "wrong pin", kid: kid, keyLabel: "step-provisioner", pin: "0000", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := *cfg
+			cfg.keyLabel = tt.keyLabel
+			s, err := newPKCS11TokenSource(client, "admin", tt.kid, nil, &cfg, []byte(tt.pin))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				if got := errors.Is(err, ErrKeyIDMismatch); got != tt.wantMismatch {
+					t.Fatalf("expected ErrKeyIDMismatch %v, got %v", tt.wantMismatch, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			tok, err := s.Token(context.Background(), &tokenRequest{subject: "example.com"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			jwt, err := jose.ParseSigned(tok)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := jwt.Headers[0].KeyID; got != kid {
+				t.Errorf("expected kid %s, got %s", kid, got)
+			}
+			if got := jwt.Headers[0].Algorithm; got != jose.ES256 {
+				t.Errorf("expected alg %s, got %s", jose.ES256, got)
+			}
+			var claims jose.Claims
+			if err := jwt.Claims(key.Public(), &claims); err != nil {
+				t.Fatalf("error verifying token: %v", err)
+			}
+			if claims.Subject != "example.com" {
+				t.Errorf("unexpected subject %s", claims.Subject)
+			}
+		})
+	}
+
+	// The module is opened once for all the issuers using the same token.
+	if *opened != 1 {
+		t.Errorf("expected the module to be opened once, got %d", *opened)
+	}
+}
+
+func TestPKCS11ModuleLifecycle(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, err := jose.Thumbprint(&jose.JSONWebKey{Key: key.Public()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	km := &fakeKeyManager{signers: map[string]crypto.Signer{"pkcs11:object=step-provisioner": key}}
+	opened := setFakeKeyManager(t, km, "1234", "5678")
+	client := newTestClient(t)
+	cfg := &pkcs11Config{modulePath: "/usr/lib/softhsm/libsofthsm2.so", tokenLabel: "step", keyLabel: "step-provisioner"}
+	newSource := func(pin string) *pkcs11TokenSource {
+		t.Helper()
+		s, err := newPKCS11TokenSource(client, "admin", kid, nil, cfg, []byte(pin))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return s
+	}
+
+	// The issuers using the same token and PIN share the module.
+	s1, s2 := newSource("1234"), newSource("1234")
+	if *opened != 1 {
+		t.Fatalf("expected the module to be opened once, got %d", *opened)
+	}
+	// A new PIN logs in again, the previous module is closed with its last
+	// issuer.
+	s3 := newSource("5678")
+	if *opened != 2 {
+		t.Fatalf("expected the module to be opened again, got %d", *opened)
+	}
+	_ = s1.Close()
+	if km.closed != 0 {
+		t.Fatalf("expected the module to be open, closed %d times", km.closed)
+	}
+	_ = s2.Close()
+	if km.closed != 1 {
+		t.Fatalf("expected the previous module to be closed, closed %d times", km.closed)
+	}
+	if _, err := s3.Token(context.Background(), &tokenRequest{subject: "example.com"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = s3.Close()
+	if km.closed != 2 || len(pkcs11Modules) != 0 {
+		t.Fatalf("expected all the modules to be closed, closed %d times, %d open", km.closed, len(pkcs11Modules))
+	}
+}
+
+func TestPKCS11ConfigURI(t *testing.T) {
+	slot := 3
+	tests := []struct {
+		cfg  pkcs11Config
+		want string
+	}{
+		{pkcs11Config{modulePath: "/lib/softhsm2.so", tokenLabel: "step"}, "pkcs11:module-path=%2Flib%2Fsofthsm2.so;token=step"},
+		{pkcs11Config{modulePath: "/lib/softhsm2.so", slotID: &slot}, "pkcs11:module-path=%2Flib%2Fsofthsm2.so;slot-id=3"},
+	}
+	for _, tt := range tests {
+		if got := tt.cfg.uri(); got != tt.want {
+			t.Errorf("expected %s, got %s", tt.want, got)
+		}
+	}
+}
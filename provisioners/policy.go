@@ -0,0 +1,264 @@
+package provisioners
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+)
+
+// namespaceVariable is replaced by the namespace of the CertificateRequest in
+// the policy rules.
+const namespaceVariable = "{{namespace}}"
+
+// ErrPolicyViolation is returned when a CertificateRequest does not satisfy
+// the policy of its issuer.
+var ErrPolicyViolation = errors.New("certificate request violates the issuer policy")
+
+// Policy contains the rules that the CertificateRequests of an issuer must
+// satisfy before they are sent to the CA.
+type Policy struct {
+	dnsNames      []string
+	ipRanges      []*net.IPNet
+	uriPrefixes   []string
+	emailDomains  []string
+	keyAlgorithms []policyKeyAlgorithm
+	maxDuration   time.Duration
+}
+
+type policyKeyAlgorithm struct {
+	algorithm api.KeyAlgorithm
+	minSize   int
+}
+
+// NewPolicyFromStepIssuer returns the policy of a StepIssuer, or nil if it
+// does not have one.
+func NewPolicyFromStepIssuer(iss *api.StepIssuer) (*Policy, error) {
+	p := iss.Spec.Policy
+	if p == nil {
+		return nil, nil
+	}
+	keyAlgorithms := make([]policyKeyAlgorithm, len(p.KeyAlgorithms))
+	for i, k := range p.KeyAlgorithms {
+		keyAlgorithms[i] = policyKeyAlgorithm{algorithm: k.Algorithm, minSize: k.MinSize}
+	}
+	var maxDuration time.Duration
+	if p.MaxDuration != nil {
+		maxDuration = p.MaxDuration.Duration
+	}
+	return newPolicy(p.DNSNames, p.IPRanges, p.URIPrefixes, p.EmailDomains, keyAlgorithms, maxDuration)
+}
+
+// NewPolicyFromStepClusterIssuer returns the policy of a StepClusterIssuer, or
+// nil if it does not have one.
+func NewPolicyFromStepClusterIssuer(iss *api.StepClusterIssuer) (*Policy, error) {
+	p := iss.Spec.Policy
+	if p == nil {
+		return nil, nil
+	}
+	keyAlgorithms := make([]policyKeyAlgorithm, len(p.KeyAlgorithms))
+	for i, k := range p.KeyAlgorithms {
+		keyAlgorithms[i] = policyKeyAlgorithm{algorithm: k.Algorithm, minSize: k.MinSize}
+	}
+	var maxDuration time.Duration
+	if p.MaxDuration != nil {
+		maxDuration = p.MaxDuration.Duration
+	}
+	return newPolicy(p.DNSNames, p.IPRanges, p.URIPrefixes, p.EmailDomains, keyAlgorithms, maxDuration)
+}
+
+func newPolicy(dnsNames, ipRanges, uriPrefixes, emailDomains []string, keyAlgorithms []policyKeyAlgorithm, maxDuration time.Duration) (*Policy, error) {
+	p := &Policy{
+		dnsNames:      dnsNames,
+		uriPrefixes:   uriPrefixes,
+		emailDomains:  emailDomains,
+		keyAlgorithms: keyAlgorithms,
+		maxDuration:   maxDuration,
+	}
+	for _, r := range ipRanges {
+		ipNet, err := ParseIPRange(r)
+		if err != nil {
+			return nil, fmt.Errorf("spec.policy.ipRanges: %w", err)
+		}
+		p.ipRanges = append(p.ipRanges, ipNet)
+	}
+	return p, nil
+}
+
+// ParseIPRange parses an IP range in CIDR notation, or a single IP address.
+func ParseIPRange(s string) (*net.IPNet, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		bits := 8 * len(ip.To16())
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP range %q", s)
+	}
+	return ipNet, nil
+}
+
+// Evaluate checks the certificate request against the policy and returns an
+// error wrapping ErrPolicyViolation with the rule that failed.
+//
+// If any of the name rules is set, every name in the request must be allowed
+// by the rule of its type, and the names of a type without a rule are denied.
+// The common name must be one of the SANs or be allowed as a DNS name or IP
+// address.
+//
+// The requests without a duration are signed with the default duration of the
+// provisioner, defaultDuration, or zero if the provisioner does not set one.
+// They are denied if the maximum duration is set and the provisioner has no
+// default. Use NeedsDefaultDuration to wait for the claims of the provisioner
+// before evaluating these requests.
+func (p *Policy) Evaluate(cr *certmanager.CertificateRequest, csr *x509.CertificateRequest, defaultDuration time.Duration) error {
+	if err := p.evaluateDuration(cr, defaultDuration); err != nil {
+		return err
+	}
+	if err := p.evaluateKey(csr); err != nil {
+		return err
+	}
+
+	if len(p.dnsNames) == 0 && len(p.ipRanges) == 0 && len(p.uriPrefixes) == 0 && len(p.emailDomains) == 0 {
+		return nil
+	}
+	dnsNames := expandNamespace(p.dnsNames, cr.Namespace)
+	uriPrefixes := expandNamespace(p.uriPrefixes, cr.Namespace)
+	emailDomains := expandNamespace(p.emailDomains, cr.Namespace)
+
+	for _, name := range csr.DNSNames {
+		if !slices.ContainsFunc(dnsNames, func(pattern string) bool { return matchDomain(pattern, name) }) {
+			return fmt.Errorf("%w: DNS name %q is not allowed by spec.policy.dnsNames %v", ErrPolicyViolation, name, dnsNames)
+		}
+	}
+	for _, ip := range csr.IPAddresses {
+		if !slices.ContainsFunc(p.ipRanges, func(r *net.IPNet) bool { return r.Contains(ip) }) {
+			return fmt.Errorf("%w: IP address %s is not allowed by spec.policy.ipRanges %v", ErrPolicyViolation, ip, p.ipRanges)
+		}
+	}
+	for _, u := range csr.URIs {
+		uri := u.String()
+		if !slices.ContainsFunc(uriPrefixes, func(prefix string) bool { return strings.HasPrefix(uri, prefix) }) {
+			return fmt.Errorf("%w: URI %q is not allowed by spec.policy.uriPrefixes %v", ErrPolicyViolation, uri, uriPrefixes)
+		}
+	}
+	for _, email := range csr.EmailAddresses {
+		_, domain, _ := strings.Cut(email, "@")
+		if !slices.ContainsFunc(emailDomains, func(pattern string) bool { return matchDomain(pattern, domain) }) {
+			return fmt.Errorf("%w: email address %q is not allowed by spec.policy.emailDomains %v", ErrPolicyViolation, email, emailDomains)
+		}
+	}
+
+	cn := csr.Subject.CommonName
+	if cn == "" || slices.Contains(csr.DNSNames, cn) || slices.Contains(csr.EmailAddresses, cn) ||
+		slices.ContainsFunc(csr.IPAddresses, func(ip net.IP) bool { return ip.String() == cn }) {
+		return nil
+	}
+	if ip := net.ParseIP(cn); ip != nil {
+		if !slices.ContainsFunc(p.ipRanges, func(r *net.IPNet) bool { return r.Contains(ip) }) {
+			return fmt.Errorf("%w: common name %q is not allowed by spec.policy.ipRanges %v", ErrPolicyViolation, cn, p.ipRanges)
+		}
+		return nil
+	}
+	if !slices.ContainsFunc(dnsNames, func(pattern string) bool { return matchDomain(pattern, cn) }) {
+		return fmt.Errorf("%w: common name %q is not allowed by spec.policy.dnsNames %v", ErrPolicyViolation, cn, dnsNames)
+	}
+	return nil
+}
+
+// NeedsDefaultDuration returns true if the request has no duration and the
+// default duration of the provisioner must be checked against the maximum
+// duration of the policy.
+func (p *Policy) NeedsDefaultDuration(cr *certmanager.CertificateRequest) bool {
+	return p.maxDuration > 0 && cr.Spec.Duration == nil
+}
+
+// evaluateDuration checks the effective duration of the request against the
+// maximum duration.
+func (p *Policy) evaluateDuration(cr *certmanager.CertificateRequest, defaultDuration time.Duration) error {
+	if p.maxDuration == 0 {
+		return nil
+	}
+	if cr.Spec.Duration == nil {
+		if defaultDuration == 0 {
+			return fmt.Errorf("%w: request without a duration and the provisioner has no default duration, spec.policy.maxDuration is %s",
+				ErrPolicyViolation, p.maxDuration)
+		}
+		if defaultDuration > p.maxDuration {
+			return fmt.Errorf("%w: default duration %s of the provisioner exceeds spec.policy.maxDuration %s", ErrPolicyViolation, defaultDuration, p.maxDuration)
+		}
+		return nil
+	}
+	if d := cr.Spec.Duration.Duration; d > p.maxDuration {
+		return fmt.Errorf("%w: duration %s exceeds spec.policy.maxDuration %s", ErrPolicyViolation, d, p.maxDuration)
+	}
+	return nil
+}
+
+// evaluateKey checks the public key of the request against the allowed key
+// algorithms.
+func (p *Policy) evaluateKey(csr *x509.CertificateRequest) error {
+	if len(p.keyAlgorithms) == 0 {
+		return nil
+	}
+	var algorithm api.KeyAlgorithm
+	var size int
+	switch pub := csr.PublicKey.(type) {
+	case *rsa.PublicKey:
+		algorithm, size = api.KeyAlgorithmRSA, pub.N.BitLen()
+	case *ecdsa.PublicKey:
+		algorithm, size = api.KeyAlgorithmECDSA, pub.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		algorithm = api.KeyAlgorithmEd25519
+	default:
+		return fmt.Errorf("%w: unsupported public key type %T", ErrPolicyViolation, csr.PublicKey)
+	}
+
+	failed := -1
+	for i, k := range p.keyAlgorithms {
+		if k.algorithm != algorithm {
+			continue
+		}
+		if algorithm == api.KeyAlgorithmEd25519 || size >= k.minSize {
+			return nil
+		}
+		failed = i
+	}
+	if failed < 0 {
+		return fmt.Errorf("%w: %s keys are not allowed by spec.policy.keyAlgorithms", ErrPolicyViolation, algorithm)
+	}
+	return fmt.Errorf("%w: %s key of %d bits is smaller than spec.policy.keyAlgorithms[%d].minSize %d",
+		ErrPolicyViolation, algorithm, size, failed, p.keyAlgorithms[failed].minSize)
+}
+
+// expandNamespace replaces the namespace variable in the rules.
+func expandNamespace(rules []string, namespace string) []string {
+	expanded := make([]string, len(rules))
+	for i, r := range rules {
+		expanded[i] = strings.ReplaceAll(r, namespaceVariable, namespace)
+	}
+	return expanded
+}
+
+// matchDomain returns true if the domain matches the pattern, ignoring case. A
+// * as the leftmost label of the pattern matches exactly one label.
+func matchDomain(pattern, domain string) bool {
+	pattern, domain = strings.ToLower(pattern), strings.ToLower(domain)
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		label, rest, ok := strings.Cut(domain, ".")
+		return ok && label != "" && rest == suffix
+	}
+	return pattern == domain
+}
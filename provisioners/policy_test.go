@@ -0,0 +1,186 @@
+package provisioners
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPolicyEvaluate(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iss := &api.StepClusterIssuer{Spec: api.StepClusterIssuerSpec{Policy: &api.StepClusterPolicy{
+		DNSNames:     []string{"*.{{namespace}}.svc.cluster.local", "example.com"},
+		IPRanges:     []string{"10.0.0.0/8", "192.168.1.1"},
+		URIPrefixes:  []string{"spiffe://cluster.local/ns/{{namespace}}/"},
+		EmailDomains: []string{"example.com"},
+		KeyAlgorithms: []api.StepClusterPolicyKeyAlgorithm{
+			{Algorithm: api.KeyAlgorithmECDSA, MinSize: 256},
+			{Algorithm: api.KeyAlgorithmRSA, MinSize: 2048},
+		},
+		MaxDuration: &metav1.Duration{Duration: 24 * time.Hour},
+	}}}
+	policy, err := NewPolicyFromStepClusterIssuer(iss)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mustURL := func(s string) *url.URL {
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	tests := []struct {
+		name     string
+		key      crypto.Signer
+		template *x509.CertificateRequest
+		duration time.Duration
+		wantErr  bool
+	}{
+		{name: "ok", template: &x509.CertificateRequest{
+			Subject:        pkix.Name{CommonName: "web.team-a.svc.cluster.local"},
+			DNSNames:       []string{"web.team-a.svc.cluster.local", "Example.com"},
+			IPAddresses:    []net.IP{net.ParseIP("10.1.2.3"), net.ParseIP("192.168.1.1")},
+			URIs:           []*url.URL{mustURL("spiffe://cluster.local/ns/team-a/sa/web")},
+			EmailAddresses: []string{"admin@example.com"},
+		}, duration: time.Hour},
+		{name: "common name as IP", template: &x509.CertificateRequest{Subject: pkix.Name{CommonName: "10.0.0.1"}}},
+		{name: "other namespace", template: &x509.CertificateRequest{
+			DNSNames: []string{"web.team-b.svc.cluster.local"},
+		}, wantErr: true},
+		{name: "nested subdomain", template: &x509.CertificateRequest{
+			DNSNames: []string{"a.web.team-a.svc.cluster.local"},
+		}, wantErr: true},
+		{name: "ip out of range", template: &x509.CertificateRequest{
+			IPAddresses: []net.IP{net.ParseIP("192.168.1.2")},
+		}, wantErr: true},
+		{name: "uri of other namespace", template: &x509.CertificateRequest{
+			URIs: []*url.URL{mustURL("spiffe://cluster.local/ns/team-b/sa/web")},
+		}, wantErr: true},
+		{name: "email of other domain", template: &x509.CertificateRequest{
+			EmailAddresses: []string{"admin@example.org"},
+		}, wantErr: true},
+		{name: "common name not allowed", template: &x509.CertificateRequest{
+			Subject: pkix.Name{CommonName: "example.org"}, DNSNames: []string{"example.com"},
+		}, wantErr: true},
+		{name: "duration too long", template: &x509.CertificateRequest{DNSNames: []string{"example.com"}},
+			duration: 48 * time.Hour, wantErr: true},
+		{name: "small rsa key", key: rsaKey, template: &x509.CertificateRequest{DNSNames: []string{"example.com"}}, wantErr: true},
+		{name: "ed25519 not allowed", key: edKey, template: &x509.CertificateRequest{DNSNames: []string{"example.com"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := tt.key
+			if key == nil {
+				key = ecKey
+			}
+			der, err := x509.CreateCertificateRequest(rand.Reader, tt.template, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			csr, err := x509.ParseCertificateRequest(der)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cr := &certmanager.CertificateRequest{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a"}}
+			if tt.duration != 0 {
+				cr.Spec.Duration = &metav1.Duration{Duration: tt.duration}
+			}
+			err = policy.Evaluate(cr, csr, time.Hour)
+			if tt.wantErr && !errors.Is(err, ErrPolicyViolation) {
+				t.Fatalf("expected a policy violation, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestPolicyEvaluateDuration(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{"example.com"}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := NewPolicyFromStepIssuer(&api.StepIssuer{Spec: api.StepIssuerSpec{Policy: &api.StepPolicy{
+		MaxDuration: &metav1.Duration{Duration: 24 * time.Hour},
+	}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		duration        time.Duration
+		defaultDuration time.Duration
+		wantErr         bool
+	}{
+		{name: "duration", duration: 24 * time.Hour},
+		{name: "duration too long", duration: 48 * time.Hour, defaultDuration: time.Hour, wantErr: true},
+		{name: "default duration", defaultDuration: 24 * time.Hour},
+		{name: "default duration too long", defaultDuration: 48 * time.Hour, wantErr: true},
+		{name: "no default duration", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr := &certmanager.CertificateRequest{}
+			if tt.duration != 0 {
+				cr.Spec.Duration = &metav1.Duration{Duration: tt.duration}
+			}
+			err := policy.Evaluate(cr, csr, tt.defaultDuration)
+			if tt.wantErr && !errors.Is(err, ErrPolicyViolation) {
+				t.Fatalf("expected a policy violation, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewPolicyFromStepIssuer(t *testing.T) {
+	if p, err := NewPolicyFromStepIssuer(&api.StepIssuer{}); p != nil || err != nil {
+		t.Errorf("expected no policy, got %v, %v", p, err)
+	}
+	if _, err := NewPolicyFromStepIssuer(&api.StepIssuer{Spec: api.StepIssuerSpec{
+		Policy: &api.StepPolicy{IPRanges: []string{"10.0.0.0/33"}},
+	}}); err == nil {
+		t.Error("expected an error parsing an invalid IP range")
+	}
+}
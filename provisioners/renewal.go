@@ -0,0 +1,175 @@
+package provisioners
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	capi "github.com/smallstep/certificates/api"
+)
+
+// renewalConfig configures the renewal of certificates with the previous
+// certificate.
+type renewalConfig struct {
+	rekey bool
+}
+
+// SignOption configures a sign request.
+type SignOption func(*signOptions)
+
+type signOptions struct {
+	previousCertificate []byte
+	previousKey         []byte
+}
+
+// WithPreviousCertificate sets the PEM encoded certificate chain and private
+// key of the certificate being renewed. They are used to renew the
+// certificate if the issuer has renewal enabled.
+func WithPreviousCertificate(certPEM, keyPEM []byte) SignOption {
+	return func(o *signOptions) {
+		o.previousCertificate = certPEM
+		o.previousKey = keyPEM
+	}
+}
+
+// renewalDurationSkew is the maximum difference between the requested duration
+// and the duration of the previous certificate, the CA backdates the
+// certificates.
+const renewalDurationSkew = 5 * time.Minute
+
+// renew renews the previous certificate using the /renew endpoint, or the
+// /rekey endpoint if the request has a new key and rekey is enabled. It
+// returns false if the request cannot be signed as a renewal of the previous
+// certificate.
+func (s *Step) renew(ctx context.Context, cr *certmanager.CertificateRequest, csr *x509.CertificateRequest, o *signOptions) ([]*x509.Certificate, bool, error) {
+	if s.renewal == nil || len(o.previousCertificate) == 0 || len(o.previousKey) == 0 {
+		return nil, false, nil
+	}
+
+	crt, err := tls.X509KeyPair(o.previousCertificate, o.previousKey)
+	if err != nil {
+		return nil, false, nil
+	}
+	chain := make([]*x509.Certificate, len(crt.Certificate))
+	for i, der := range crt.Certificate {
+		if chain[i], err = x509.ParseCertificate(der); err != nil {
+			return nil, false, nil
+		}
+	}
+	leaf := chain[0]
+
+	now := time.Now()
+	if now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+		return nil, false, nil
+	}
+	if !sameNames(leaf, csr) || verifyUsages(leaf, cr) != nil {
+		return nil, false, nil
+	}
+	if cr.Spec.Duration != nil {
+		d := leaf.NotAfter.Sub(leaf.NotBefore) - cr.Spec.Duration.Duration
+		if d < -renewalDurationSkew || d > renewalDurationSkew {
+			return nil, false, nil
+		}
+	}
+
+	rekey := true
+	if pub, ok := leaf.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); ok && pub.Equal(csr.PublicKey) {
+		rekey = false
+	}
+	if rekey && !s.renewal.rekey {
+		return nil, false, nil
+	}
+
+	// The previous certificate must have been issued by this CA.
+	roots, err := s.loadRoots(ctx, false)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := issuingRoot(roots, chain); err != nil {
+		return nil, false, nil
+	}
+
+	tr, err := s.renewTransport(crt)
+	if err != nil {
+		return nil, false, err
+	}
+	var resp *capi.SignResponse
+	if rekey {
+		resp, err = s.client.RekeyWithContext(ctx, &capi.RekeyRequest{
+			CsrPEM: capi.CertificateRequest{CertificateRequest: csr},
+		}, tr)
+	} else {
+		resp, err = s.client.RenewWithContext(ctx, tr)
+	}
+	if err != nil {
+		return nil, true, fmt.Errorf("error renewing certificate: %w", err)
+	}
+
+	certs := make([]*x509.Certificate, len(resp.CertChainPEM))
+	for i, c := range resp.CertChainPEM {
+		certs[i] = c.Certificate
+	}
+	return certs, true, nil
+}
+
+// renewTransport returns a transport that authenticates to the CA with the
+// given certificate.
+func (s *Step) renewTransport(crt tls.Certificate) (http.RoundTripper, error) {
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{crt},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if len(s.caBundle) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(s.caBundle) {
+			return nil, fmt.Errorf("error parsing caBundle")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.TLSClientConfig = tlsConfig
+	return tr, nil
+}
+
+// sameNames returns true if the certificate has the same common name and SANs
+// as the certificate request.
+func sameNames(crt *x509.Certificate, csr *x509.CertificateRequest) bool {
+	if crt.Subject.CommonName != csr.Subject.CommonName {
+		return false
+	}
+	if !sameStrings(crt.DNSNames, csr.DNSNames) || !sameStrings(crt.EmailAddresses, csr.EmailAddresses) {
+		return false
+	}
+	var a, b []string
+	for _, ip := range crt.IPAddresses {
+		a = append(a, ip.String())
+	}
+	for _, ip := range csr.IPAddresses {
+		b = append(b, ip.String())
+	}
+	if !sameStrings(a, b) {
+		return false
+	}
+	a, b = nil, nil
+	for _, u := range crt.URIs {
+		a = append(a, u.String())
+	}
+	for _, u := range csr.URIs {
+		b = append(b, u.String())
+	}
+	return sameStrings(a, b)
+}
+
+// sameStrings returns true if both lists have the same elements, in any order.
+func sameStrings(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
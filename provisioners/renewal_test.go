@@ -0,0 +1,119 @@
+package provisioners
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/smallstep/certificates/authority/provisioner"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	"go.step.sm/crypto/pemutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// failingTokenSource simulates a provisioner whose credentials were rotated.
+type failingTokenSource struct{}
+
+func (failingTokenSource) Token(context.Context, *tokenRequest) (string, error) {
+	return "", errors.New("provisioner credentials are not valid")
+}
+
+func TestStepSignRenewal(t *testing.T) {
+	jwk, jwkKey := newTestJWKProvisioner(t, "admin")
+	testCA := newTestCA(t, provisioner.List{jwk})
+
+	newStep := func(t *testing.T, renewal *api.StepRenewal) *Step {
+		t.Helper()
+		s, err := NewFromStepIssuer(context.Background(), &api.StepIssuer{
+			ObjectMeta: metav1.ObjectMeta{Name: "issuer", Namespace: "default"},
+			Spec: api.StepIssuerSpec{
+				URL:      testCA.URL,
+				CABundle: testCA.RootPEM,
+				Renewal:  renewal,
+				Provisioner: api.StepProvisioner{
+					Name:   "admin",
+					KeyID:  jwk.Key.KeyID,
+					KeyRef: &api.StepIssuerSecretKeySelector{},
+				},
+			},
+		}, Credentials{JWKKey: jwkKey})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return s
+	}
+	newKey := func(t *testing.T) crypto.Signer {
+		t.Helper()
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	newCR := func(t *testing.T, key crypto.Signer, dnsNames ...string) *certmanager.CertificateRequest {
+		return &certmanager.CertificateRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: "cr", Namespace: "default"},
+			Spec: certmanager.CertificateRequestSpec{
+				Request: newTestCSRWithKey(t, key, dnsNames...),
+			},
+		}
+	}
+
+	// Issue the previous certificate with the provisioner.
+	key := newKey(t)
+	prevCertPEM, _, err := newStep(t, nil).Sign(context.Background(), newCR(t, key, "example.com"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	block, err := pemutil.Serialize(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevKeyPEM := pem.EncodeToMemory(block)
+
+	tests := []struct {
+		name     string
+		renewal  *api.StepRenewal
+		key      crypto.Signer
+		dnsNames []string
+		wantErr  bool
+	}{
+		{name: "renew", renewal: &api.StepRenewal{}, key: key, dnsNames: []string{"example.com"}},
+		{name: "rekey", renewal: &api.StepRenewal{Rekey: true}, key: newKey(t), dnsNames: []string{"example.com"}},
+		{name: "rekey disabled", renewal: &api.StepRenewal{}, key: newKey(t), dnsNames: []string{"example.com"}, wantErr: true},
+		{name: "different names", renewal: &api.StepRenewal{}, key: key, dnsNames: []string{"example.org"}, wantErr: true},
+		{name: "renewal disabled", key: key, dnsNames: []string{"example.com"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStep(t, tt.renewal)
+			s.tokens = failingTokenSource{}
+
+			chainPEM, _, err := s.Sign(context.Background(), newCR(t, tt.key, tt.dnsNames...),
+				WithPreviousCertificate(prevCertPEM, prevKeyPEM))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			chain, err := pemutil.ParseCertificateBundle(chainPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pub, ok := chain[0].PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+			if !ok || !pub.Equal(tt.key.Public()) {
+				t.Error("renewed certificate does not have the requested public key")
+			}
+		})
+	}
+}
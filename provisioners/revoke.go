@@ -0,0 +1,55 @@
+package provisioners
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	capi "github.com/smallstep/certificates/api"
+	"github.com/smallstep/certificates/errs"
+)
+
+// ErrRevocationNotSupported is returned when the provisioner of an issuer
+// cannot create revocation tokens.
+var ErrRevocationNotSupported = errors.New("provisioner does not support revocation")
+
+// ErrRevocationRejected is returned when the CA rejects a revocation request,
+// for example because the certificate is already revoked. Retrying the request
+// does not help.
+var ErrRevocationRejected = errors.New("revocation rejected by the CA")
+
+// revokeTokenSource creates the one-time tokens used to authorize revoke
+// requests. It is implemented by the token sources of JWK and X5C
+// provisioners.
+type revokeTokenSource interface {
+	RevokeToken(ctx context.Context, serial string) (string, error)
+}
+
+// Revoke revokes the certificate with the given serial number, in decimal
+// format, using a token signed by the provisioner. step-ca only supports
+// passive revocation, the certificate cannot be renewed anymore but it is
+// still valid for the TLS clients that do not check for revocation.
+func (s *Step) Revoke(ctx context.Context, serial string, reasonCode int, reason string) error {
+	tokens, ok := s.tokens.(revokeTokenSource)
+	if !ok {
+		return ErrRevocationNotSupported
+	}
+	token, err := tokens.RevokeToken(ctx, serial)
+	if err != nil {
+		return err
+	}
+	if _, err := s.client.RevokeWithContext(ctx, &capi.RevokeRequest{
+		Serial:     serial,
+		OTT:        token,
+		ReasonCode: reasonCode,
+		Reason:     reason,
+		Passive:    true,
+	}, nil); err != nil {
+		var apiErr *errs.Error
+		if errors.As(err, &apiErr) && apiErr.StatusCode() >= 400 && apiErr.StatusCode() < 500 {
+			return fmt.Errorf("%w: error revoking certificate %s: %w", ErrRevocationRejected, serial, err)
+		}
+		return fmt.Errorf("error revoking certificate %s: %w", serial, err)
+	}
+	return nil
+}
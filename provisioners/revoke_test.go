@@ -0,0 +1,60 @@
+package provisioners
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/smallstep/certificates/authority/provisioner"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	"go.step.sm/crypto/pemutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStepRevoke(t *testing.T) {
+	jwk, key := newTestJWKProvisioner(t, "admin")
+	testCA := newTestCA(t, provisioner.List{jwk})
+
+	s, err := NewFromStepIssuer(context.Background(), &api.StepIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "issuer", Namespace: "default"},
+		Spec: api.StepIssuerSpec{
+			URL:      testCA.URL,
+			CABundle: testCA.RootPEM,
+			Provisioner: api.StepProvisioner{
+				Name:   "admin",
+				KeyID:  jwk.Key.KeyID,
+				KeyRef: &api.StepIssuerSecretKeySelector{},
+			},
+		},
+	}, Credentials{JWKKey: key})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	chainPEM, _, err := s.Sign(context.Background(), &certmanager.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "cr", Namespace: "default"},
+		Spec: certmanager.CertificateRequestSpec{
+			Request: newTestCSR(t, "example.com"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	chain, err := pemutil.ParseCertificateBundle(chainPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial := chain[0].SerialNumber.String()
+
+	if err := s.Revoke(context.Background(), serial, 5, "deleted"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.Revoke(context.Background(), serial, 5, "deleted"); !errors.Is(err, ErrRevocationRejected) {
+		t.Fatalf("expected ErrRevocationRejected revoking a revoked certificate, got %v", err)
+	}
+
+	unsupported := &Step{client: s.client, tokens: failingTokenSource{}}
+	if err := unsupported.Revoke(context.Background(), serial, 5, ""); !errors.Is(err, ErrRevocationNotSupported) {
+		t.Fatalf("expected ErrRevocationNotSupported, got %v", err)
+	}
+}
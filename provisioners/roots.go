@@ -0,0 +1,126 @@
+package provisioners
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"slices"
+
+	capi "github.com/smallstep/certificates/api"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+)
+
+// caRoots returns the root certificates used to populate the ca field of the
+// CertificateRequests.
+func (s *Step) caRoots(ctx context.Context) ([]*x509.Certificate, error) {
+	switch s.caSource {
+	case api.CASourceRoot:
+		return s.loadRoots(ctx, false)
+	case api.CASourceFederated:
+		return s.loadRoots(ctx, true)
+	default:
+		return nil, nil
+	}
+}
+
+// loadRoots returns the roots, or the federated roots, of the CA. They are
+// read from the CA the first time they are needed and kept for the lifetime
+// of the provisioner.
+func (s *Step) loadRoots(ctx context.Context, federated bool) ([]*x509.Certificate, error) {
+	s.rootsMu.Lock()
+	defer s.rootsMu.Unlock()
+	if federated && s.federatedRoots != nil {
+		return s.federatedRoots, nil
+	}
+	if !federated && s.roots != nil {
+		return s.roots, nil
+	}
+
+	var certs []capi.Certificate
+	if federated {
+		resp, err := s.client.FederationWithContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error getting CA federated roots: %w", err)
+		}
+		certs = resp.Certificates
+	} else {
+		resp, err := s.client.RootsWithContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error getting CA roots: %w", err)
+		}
+		certs = resp.Certificates
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("CA did not return any root certificate")
+	}
+
+	roots := make([]*x509.Certificate, len(certs))
+	for i, crt := range certs {
+		roots[i] = crt.Certificate
+	}
+	if federated {
+		// The CA returns the federated roots in random order, sort them so
+		// the ca field does not change between requests.
+		slices.SortFunc(roots, func(a, b *x509.Certificate) int {
+			return bytes.Compare(a.Raw, b.Raw)
+		})
+		s.federatedRoots = roots
+	} else {
+		s.roots = roots
+	}
+	return roots, nil
+}
+
+// caCertificates returns the PEM encoded certificates for the ca field of a
+// CertificateRequest issued with the given chain.
+func (s *Step) caCertificates(roots, chain []*x509.Certificate) ([]byte, error) {
+	switch s.caSource {
+	case api.CASourceNone:
+		return nil, nil
+	case api.CASourceTLSBundle:
+		return s.caBundle, nil
+	case api.CASourceFederated:
+		return encodeX509(roots...), nil
+	default:
+		root, err := issuingRoot(roots, chain)
+		if err != nil {
+			return nil, err
+		}
+		return encodeX509(root), nil
+	}
+}
+
+// issuingRoot returns the root that the certificate chain verifies to. The
+// chain is verified at the time all of its certificates are valid, as the CA
+// can backdate the leaf certificate.
+func issuingRoot(roots, chain []*x509.Certificate) (*x509.Certificate, error) {
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("CA did not return any certificate")
+	}
+	intermediates := x509.NewCertPool()
+	verifyTime := chain[0].NotBefore
+	for _, crt := range chain[1:] {
+		intermediates.AddCert(crt)
+		if crt.NotBefore.After(verifyTime) {
+			verifyTime = crt.NotBefore
+		}
+	}
+	for _, root := range roots {
+		pool := x509.NewCertPool()
+		pool.AddCert(root)
+		currentTime := verifyTime
+		if root.NotBefore.After(currentTime) {
+			currentTime = root.NotBefore
+		}
+		if _, err := chain[0].Verify(x509.VerifyOptions{
+			Roots:         pool,
+			Intermediates: intermediates,
+			CurrentTime:   currentTime,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}); err == nil {
+			return root, nil
+		}
+	}
+	return nil, fmt.Errorf("certificate does not chain to any of the CA roots")
+}
@@ -0,0 +1,76 @@
+package provisioners
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"testing"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/smallstep/certificates/authority/provisioner"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	"go.step.sm/crypto/minica"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStepSignCASource(t *testing.T) {
+	federated, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, key := newTestJWKProvisioner(t, "admin")
+	testCA := newTestCA(t, provisioner.List{jwk}, federated.Root)
+	federatedPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: federated.Root.Raw})
+	tlsBundle := append(append([]byte{}, testCA.RootPEM...), federatedPEM...)
+	federatedBundle := tlsBundle
+	if bytes.Compare(federated.Root.Raw, testCA.Root.Raw) < 0 {
+		federatedBundle = append(append([]byte{}, federatedPEM...), testCA.RootPEM...)
+	}
+
+	tests := []struct {
+		name     string
+		caSource api.CASource
+		want     []byte
+	}{
+		{name: "default", want: testCA.RootPEM},
+		{name: "root", caSource: api.CASourceRoot, want: testCA.RootPEM},
+		{name: "federated", caSource: api.CASourceFederated, want: federatedBundle},
+		{name: "tls bundle", caSource: api.CASourceTLSBundle, want: tlsBundle},
+		{name: "none", caSource: api.CASourceNone, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewFromStepIssuer(context.Background(), &api.StepIssuer{
+				ObjectMeta: metav1.ObjectMeta{Name: "issuer", Namespace: "default"},
+				Spec: api.StepIssuerSpec{
+					URL:      testCA.URL,
+					CABundle: tlsBundle,
+					CASource: tt.caSource,
+					Provisioner: api.StepProvisioner{
+						Name:   "admin",
+						KeyID:  jwk.Key.KeyID,
+						KeyRef: &api.StepIssuerSecretKeySelector{},
+					},
+				},
+			}, Credentials{JWKKey: key})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			chainPEM, caPEM, err := s.Sign(context.Background(), &certmanager.CertificateRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "cr", Namespace: "default"},
+				Spec: certmanager.CertificateRequestSpec{
+					Request: newTestCSR(t, "example.com"),
+				},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(chainPEM) == 0 {
+				t.Error("expected a certificate chain")
+			}
+			if !bytes.Equal(caPEM, tt.want) {
+				t.Errorf("unexpected ca:\n%s\nwant:\n%s", caPEM, tt.want)
+			}
+		})
+	}
+}
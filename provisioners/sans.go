@@ -0,0 +1,139 @@
+package provisioners
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	"golang.org/x/net/idna"
+)
+
+// ErrSANsNotNormalized is returned when the SANs of a request are not
+// normalized and the issuer rejects them.
+var ErrSANsNotNormalized = errors.New("certificate request SANs are not normalized")
+
+// requestSANs contains the SANs of a certificate request, by type.
+type requestSANs struct {
+	dnsNames       []string
+	emailAddresses []string
+	ipAddresses    []string
+	uris           []string
+}
+
+func newRequestSANs(csr *x509.CertificateRequest) *requestSANs {
+	return &requestSANs{
+		dnsNames:       slices.Clone(csr.DNSNames),
+		emailAddresses: slices.Clone(csr.EmailAddresses),
+		ipAddresses:    ipStrings(csr.IPAddresses),
+		uris:           uriStrings(csr.URIs),
+	}
+}
+
+// all returns the SANs in the order they are sent in the tokens.
+func (r *requestSANs) all() []string {
+	var sans []string
+	sans = append(sans, r.dnsNames...)
+	sans = append(sans, r.emailAddresses...)
+	sans = append(sans, r.ipAddresses...)
+	return append(sans, r.uris...)
+}
+
+func (r *requestSANs) equal(o *requestSANs) bool {
+	return slices.Equal(r.dnsNames, o.dnsNames) && slices.Equal(r.emailAddresses, o.emailAddresses) &&
+		slices.Equal(r.ipAddresses, o.ipAddresses) && slices.Equal(r.uris, o.uris)
+}
+
+// requestSANs returns the SANs of the request after the normalization
+// configured in the issuer.
+func (s *Step) requestSANs(csr *x509.CertificateRequest) (*requestSANs, error) {
+	sans := newRequestSANs(csr)
+	switch s.sanMode {
+	case api.SANModeNormalize:
+		return normalizeSANs(sans, true)
+	case api.SANModeStrip:
+		return normalizeSANs(sans, false)
+	case api.SANModeReject:
+		normalized, err := normalizeSANs(sans, true)
+		if err != nil {
+			return nil, err
+		}
+		if !sans.equal(normalized) {
+			return nil, fmt.Errorf("%w: expected %v, got %v", ErrSANsNotNormalized, normalized.all(), sans.all())
+		}
+		return sans, nil
+	default:
+		return sans, nil
+	}
+}
+
+// normalizeSANs removes the loopback names and addresses and the duplicates
+// of the SANs. If lowercase is true, it also lowercases the DNS names and the
+// domains of the email addresses, and converts them to punycode.
+func normalizeSANs(sans *requestSANs, lowercase bool) (*requestSANs, error) {
+	n := new(requestSANs)
+	for _, name := range sans.dnsNames {
+		if lowercase {
+			normalized, err := normalizeDomain(name)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid DNS name %q: %w", ErrSANsNotNormalized, name, err)
+			}
+			name = normalized
+		}
+		if isLoopbackName(name) || slices.Contains(n.dnsNames, name) {
+			continue
+		}
+		n.dnsNames = append(n.dnsNames, name)
+	}
+	for _, email := range sans.emailAddresses {
+		if lowercase {
+			local, domain, ok := strings.Cut(email, "@")
+			if !ok {
+				return nil, fmt.Errorf("%w: invalid email address %q", ErrSANsNotNormalized, email)
+			}
+			domain, err := normalizeDomain(domain)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid email address %q: %w", ErrSANsNotNormalized, email, err)
+			}
+			email = local + "@" + domain
+		}
+		if !slices.Contains(n.emailAddresses, email) {
+			n.emailAddresses = append(n.emailAddresses, email)
+		}
+	}
+	for _, ip := range sans.ipAddresses {
+		if net.ParseIP(ip).IsLoopback() || slices.Contains(n.ipAddresses, ip) {
+			continue
+		}
+		n.ipAddresses = append(n.ipAddresses, ip)
+	}
+	for _, uri := range sans.uris {
+		if !slices.Contains(n.uris, uri) {
+			n.uris = append(n.uris, uri)
+		}
+	}
+	return n, nil
+}
+
+// normalizeDomain lowercases a domain and converts it to punycode. A leading
+// wildcard label is kept.
+func normalizeDomain(domain string) (string, error) {
+	wildcard, rest := "", domain
+	if r, ok := strings.CutPrefix(domain, "*."); ok {
+		wildcard, rest = "*.", r
+	}
+	ascii, err := idna.Punycode.ToASCII(strings.ToLower(rest))
+	if err != nil {
+		return "", err
+	}
+	return wildcard + ascii, nil
+}
+
+// isLoopbackName returns true if the DNS name resolves to a loopback address.
+func isLoopbackName(name string) bool {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	return name == "localhost" || strings.HasSuffix(name, ".localhost")
+}
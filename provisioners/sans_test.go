@@ -0,0 +1,134 @@
+package provisioners
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/smallstep/certificates/authority/provisioner"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStepRequestSANs(t *testing.T) {
+	uri, err := url.Parse("spiffe://cluster.local/ns/default/sa/web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	noisy := &x509.CertificateRequest{
+		DNSNames:       []string{"Web.Example.com", "localhost", "web.example.com", "*.Bücher.example", "app.localhost"},
+		EmailAddresses: []string{"Admin@Example.COM", "Admin@example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("10.0.0.1"), net.ParseIP("::1"), net.ParseIP("10.0.0.1")},
+		URIs:           []*url.URL{uri, uri},
+	}
+	clean := &x509.CertificateRequest{
+		DNSNames:       []string{"web.example.com"},
+		EmailAddresses: []string{"admin@example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("10.0.0.1")},
+		URIs:           []*url.URL{uri},
+	}
+
+	tests := []struct {
+		name    string
+		mode    api.SANMode
+		csr     *x509.CertificateRequest
+		want    []string
+		wantErr bool
+	}{
+		{name: "unset", csr: noisy, want: []string{
+			"Web.Example.com", "localhost", "web.example.com", "*.Bücher.example", "app.localhost",
+			"Admin@Example.COM", "Admin@example.com", "127.0.0.1", "10.0.0.1", "::1", "10.0.0.1", uri.String(), uri.String(),
+		}},
+		{name: "normalize", mode: api.SANModeNormalize, csr: noisy, want: []string{
+			"web.example.com", "*.xn--bcher-kva.example", "Admin@example.com", "10.0.0.1", uri.String(),
+		}},
+		{name: "strip", mode: api.SANModeStrip, csr: noisy, want: []string{
+			"Web.Example.com", "web.example.com", "*.Bücher.example", "Admin@Example.COM", "Admin@example.com", "10.0.0.1", uri.String(),
+		}},
+		{name: "reject", mode: api.SANModeReject, csr: noisy, wantErr: true},
+		{name: "reject normalized", mode: api.SANModeReject, csr: clean, want: []string{
+			"web.example.com", "admin@example.com", "10.0.0.1", uri.String(),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Step{sanMode: tt.mode}
+			sans, err := s.requestSANs(tt.csr)
+			if tt.wantErr {
+				if !errors.Is(err, ErrSANsNotNormalized) {
+					t.Fatalf("expected ErrSANsNotNormalized, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := sans.all(); !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestStepSignSANModeJWK shows why the issuers with a JWK provisioner can only
+// reject the SANs that are not normalized: the CA requires the SANs of the
+// token to match the certificate request.
+func TestStepSignSANModeJWK(t *testing.T) {
+	jwk, key := newTestJWKProvisioner(t, "admin")
+	testCA := newTestCA(t, provisioner.List{jwk})
+
+	tests := []struct {
+		name          string
+		mode          api.SANMode
+		sans          []string
+		wantForbidden bool
+		wantErr       error
+	}{
+		{name: "normalize", mode: api.SANModeNormalize, sans: []string{"Example.com"}, wantForbidden: true},
+		{name: "normalize normalized", mode: api.SANModeNormalize, sans: []string{"example.com"}},
+		{name: "reject", mode: api.SANModeReject, sans: []string{"Example.com"}, wantErr: ErrSANsNotNormalized},
+		{name: "reject normalized", mode: api.SANModeReject, sans: []string{"example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewFromStepIssuer(context.Background(), &api.StepIssuer{
+				ObjectMeta: metav1.ObjectMeta{Name: "issuer", Namespace: "default"},
+				Spec: api.StepIssuerSpec{
+					URL:      testCA.URL,
+					CABundle: testCA.RootPEM,
+					Provisioner: api.StepProvisioner{
+						Name:   "admin",
+						KeyID:  jwk.Key.KeyID,
+						KeyRef: &api.StepIssuerSecretKeySelector{},
+					},
+					SubjectAltNames: &api.StepSubjectAltNames{Mode: tt.mode},
+				},
+			}, Credentials{JWKKey: key})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			_, _, err = s.Sign(context.Background(), &certmanager.CertificateRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "cr", Namespace: "default"},
+				Spec:       certmanager.CertificateRequestSpec{Request: newTestCSR(t, tt.sans...)},
+			})
+			switch {
+			case tt.wantForbidden:
+				if err == nil || !strings.Contains(err.Error(), "forbidden by the certificate authority") {
+					t.Fatalf("expected the CA to forbid the request, got %v", err)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
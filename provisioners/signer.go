@@ -0,0 +1,41 @@
+package provisioners
+
+import (
+	"context"
+	"crypto/x509"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+)
+
+// Signer signs the CertificateRequests of an issuer. Step, backed by a step
+// certificates server, and MemoryCA, backed by an in-memory CA, implement it.
+type Signer interface {
+	// Sign signs the certificate request and returns the PEM encoded
+	// certificate chain and the CA certificates for the ca field of the
+	// CertificateRequest.
+	Sign(ctx context.Context, cr *certmanager.CertificateRequest, opts ...SignOption) ([]byte, []byte, error)
+
+	// Health returns an error if the signer cannot sign certificates.
+	Health(ctx context.Context) error
+
+	// Roots returns the root certificates of the signer.
+	Roots(ctx context.Context) ([]*x509.Certificate, error)
+
+	// Close releases the resources of the signer. It is called when the
+	// signer is replaced or deleted from the cache, after the requests
+	// signed or revoked with it finish.
+	Close() error
+}
+
+// Revoker is implemented by the signers that can revoke certificates.
+type Revoker interface {
+	// Revoke revokes the certificate with the given serial number, in
+	// decimal format.
+	Revoke(ctx context.Context, serial string, reasonCode int, reason string) error
+}
+
+var (
+	_ Signer  = (*Step)(nil)
+	_ Revoker = (*Step)(nil)
+	_ Signer  = (*MemoryCA)(nil)
+)
@@ -3,133 +3,595 @@ package provisioners
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"sync"
+	"time"
 
 	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	capi "github.com/smallstep/certificates/api"
 	"github.com/smallstep/certificates/ca"
 	api "github.com/smallstep/step-issuer/api/v1beta1"
-	"k8s.io/apimachinery/pkg/types"
+	"go.step.sm/crypto/pemutil"
 )
 
-var collection = new(sync.Map)
+// Credentials contains the secret material, resolved by the controllers, that
+// a provisioner uses to create one-time tokens.
+type Credentials struct {
+	// Password is used to decrypt the JWK provisioner key.
+	Password []byte
 
-// Step implements a Step JWK provisioners in charge of signing certificate
-// requests using step certificates.
+	// JWKKey is the private key of a JWK provisioner, used instead of the
+	// encrypted key stored in the CA. It is decrypted with Password if it is
+	// encrypted.
+	JWKKey []byte
+
+	// PKCS11PIN is the user PIN of the PKCS#11 token with the JWK provisioner
+	// key.
+	PKCS11PIN []byte
+
+	// ClientSecret is the OAuth client secret of an OIDC provisioner.
+	ClientSecret []byte
+
+	// X5CCertificate is the PEM encoded certificate chain of an X5C
+	// provisioner.
+	X5CCertificate []byte
+
+	// X5CKey is the PEM encoded private key of the X5C certificate.
+	X5CKey []byte
+
+	// TokenServiceBearerToken is the bearer token used to authenticate to a
+	// token service.
+	TokenServiceBearerToken []byte
+
+	// TokenServiceClientCertificate is the PEM encoded client certificate
+	// used to authenticate to a token service.
+	TokenServiceClientCertificate []byte
+
+	// TokenServiceClientKey is the PEM encoded private key of the token
+	// service client certificate.
+	TokenServiceClientKey []byte
+
+	// ServiceAccountToken reads the tokens of a K8sSA provisioner.
+	ServiceAccountToken ServiceAccountTokenFunc
+
+	// ACMEAccountKey is the PEM encoded private key of the ACME account.
+	ACMEAccountKey []byte
+
+	// ACMEEABKey is the base64url encoded HMAC key used for the ACME external
+	// account binding.
+	ACMEEABKey []byte
+
+	// EmbeddedRoot is the PEM encoded root certificate of an embedded
+	// authority.
+	EmbeddedRoot []byte
+
+	// EmbeddedIntermediate is the PEM encoded intermediate certificate of an
+	// embedded authority.
+	EmbeddedIntermediate []byte
+
+	// EmbeddedIntermediateKey is the PEM encoded private key of the
+	// intermediate certificate.
+	EmbeddedIntermediateKey []byte
+}
+
+// credentialsDigest returns the digest of the secret material in the
+// credentials. ServiceAccountToken is not included, it reads the tokens when
+// they are used.
+func credentialsDigest(creds Credentials) [sha256.Size]byte {
+	h := sha256.New()
+	for _, b := range [][]byte{
+		creds.Password, creds.JWKKey, creds.PKCS11PIN, creds.ClientSecret,
+		creds.X5CCertificate, creds.X5CKey,
+		creds.TokenServiceBearerToken, creds.TokenServiceClientCertificate, creds.TokenServiceClientKey,
+		creds.ACMEAccountKey, creds.ACMEEABKey,
+		creds.EmbeddedRoot, creds.EmbeddedIntermediate, creds.EmbeddedIntermediateKey,
+	} {
+		fmt.Fprintf(h, "%d:", len(b))
+		h.Write(b)
+	}
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// ServiceAccountTokenFunc returns the token of a Kubernetes ServiceAccount
+// written in a Secret of type kubernetes.io/service-account-token.
+type ServiceAccountTokenFunc func(ctx context.Context, namespace, name string) (string, error)
+
+// tokenRequest contains the information used to create a one-time token.
+type tokenRequest struct {
+	subject string
+	sans    []string
+
+	// namespace is the namespace of the CertificateRequest.
+	namespace string
+}
+
+// tokenSource creates the one-time tokens used to authorize sign requests.
+type tokenSource interface {
+	Token(ctx context.Context, req *tokenRequest) (string, error)
+}
+
+// Step implements a step certificates provisioner in charge of signing
+// certificate requests using step certificates.
 type Step struct {
-	name        string
+	name string
+	// provisioner is the name of the provisioner in the CA.
+	provisioner string
 	caBundle    []byte
-	provisioner *ca.Provisioner
+	caSource    api.CASource
+	client      *ca.Client
+	tokens      tokenSource
+	acme        *acmeSigner
+	embedded    *embeddedAuthority
+
+	// credentials is the digest of the credentials the provisioner was
+	// created with.
+	credentials [sha256.Size]byte
+
+	templateDataConfig *templateDataConfig
+	subjectConfig      *subjectConfig
+	renewal            *renewalConfig
+
+	// backdate is subtracted from the signing time to set the start of the
+	// validity of the certificates.
+	backdate time.Duration
+
+	// exactSANs requires the issued certificates to have exactly the
+	// requested SANs.
+	exactSANs bool
+
+	// sanMode selects the normalization of the SANs sent to the CA.
+	sanMode api.SANMode
+
+	// rootsMu guards roots and federatedRoots, the root certificates read
+	// from the CA.
+	rootsMu        sync.Mutex
+	roots          []*x509.Certificate
+	federatedRoots []*x509.Certificate
+}
+
+// config is the provisioner configuration common to StepIssuer and
+// StepClusterIssuer resources.
+type config struct {
+	name         string
+	url          string
+	caBundle     []byte
+	caSource     api.CASource
+	provisioner  string
+	kid          string
+	keyRef       bool
+	pkcs11       *pkcs11Config
+	oidc         *oidcConfig
+	x5c          bool
+	k8sSA        *k8sSAConfig
+	acme         *acmeConfig
+	tokenService *tokenServiceConfig
+	templateData *templateDataConfig
+	subject      *subjectConfig
+	renewal      *renewalConfig
+	embedded     *embeddedConfig
+	backdate     time.Duration
+	exactSANs    bool
+	sanMode      api.SANMode
+	creds        Credentials
 }
 
 // NewFromStepIssuer returns a new Step provisioner, configured with the information in the
 // given issuer.
-func NewFromStepIssuer(iss *api.StepIssuer, password []byte) (*Step, error) {
-	options := []ca.ClientOption{
-		ca.WithCABundle(iss.Spec.CABundle),
+func NewFromStepIssuer(ctx context.Context, iss *api.StepIssuer, creds Credentials) (*Step, error) {
+	spec := iss.Spec
+	p := spec.Provisioner
+	cfg, err := newConfig(iss.Name+"."+iss.Namespace, stepSpec{
+		url:             spec.URL,
+		caBundle:        spec.CABundle,
+		caSource:        spec.CASource,
+		provisioner:     p.Name,
+		kid:             p.KeyID,
+		keyRef:          p.KeyRef != nil,
+		x5c:             p.X5C != nil,
+		templateData:    spec.TemplateData,
+		renewal:         spec.Renewal,
+		validity:        spec.Validity,
+		verification:    spec.Verification,
+		subjectStrategy: spec.SubjectStrategy,
+		subjectAltNames: spec.SubjectAltNames,
+	}, creds)
+	if err != nil {
+		return nil, err
+	}
+	if k := p.PKCS11; k != nil {
+		cfg.pkcs11 = &pkcs11Config{
+			modulePath: k.ModulePath,
+			tokenLabel: k.TokenLabel,
+			slotID:     k.SlotID,
+			keyLabel:   k.KeyLabel,
+		}
 	}
+	if o := p.OIDC; o != nil {
+		cfg.oidc = &oidcConfig{
+			issuerURL: o.IssuerURL,
+			clientID:  o.ClientID,
+			scopes:    o.Scopes,
+		}
+	}
+	if k := p.K8sSA; k != nil {
+		cfg.k8sSA = &k8sSAConfig{
+			namespace:           iss.Namespace,
+			name:                k.ServiceAccountName,
+			useRequestNamespace: k.UseRequestNamespace,
+		}
+	}
+	if a := p.ACME; a != nil {
+		cfg.acme = &acmeConfig{email: a.Email}
+		if a.ExternalAccountBinding != nil {
+			cfg.acme.eabKeyID = a.ExternalAccountBinding.KeyID
+		}
+	}
+	if t := p.TokenService; t != nil {
+		cfg.tokenService = &tokenServiceConfig{url: t.URL, caBundle: t.CABundle}
+	}
+	if e := spec.Embedded; e != nil {
+		cfg.embedded = newEmbeddedConfig(e.Options, e.Claims)
+	}
+	return newStep(ctx, cfg)
+}
 
-	provisioner, err := ca.NewProvisioner(iss.Spec.Provisioner.Name, iss.Spec.Provisioner.KeyID, iss.Spec.URL, password, options...)
+// NewFromStepClusterIssuer returns a new Step provisioner, configured with the
+// information in the given cluster issuer.
+func NewFromStepClusterIssuer(ctx context.Context, iss *api.StepClusterIssuer, creds Credentials) (*Step, error) {
+	spec := iss.Spec
+	p := spec.Provisioner
+	cfg, err := newConfig(iss.Name+"."+iss.Namespace, stepSpec{
+		url:             spec.URL,
+		caBundle:        spec.CABundle,
+		caSource:        spec.CASource,
+		provisioner:     p.Name,
+		kid:             p.KeyID,
+		keyRef:          p.KeyRef != nil,
+		x5c:             p.X5C != nil,
+		templateData:    (*api.StepTemplateData)(spec.TemplateData),
+		renewal:         (*api.StepRenewal)(spec.Renewal),
+		validity:        (*api.StepValidity)(spec.Validity),
+		verification:    (*api.StepVerification)(spec.Verification),
+		subjectStrategy: (*api.StepSubjectStrategy)(spec.SubjectStrategy),
+		subjectAltNames: (*api.StepSubjectAltNames)(spec.SubjectAltNames),
+	}, creds)
 	if err != nil {
 		return nil, err
 	}
-
-	p := &Step{
-		name:        iss.Name + "." + iss.Namespace,
-		caBundle:    iss.Spec.CABundle,
-		provisioner: provisioner,
+	if k := p.PKCS11; k != nil {
+		cfg.pkcs11 = &pkcs11Config{
+			modulePath: k.ModulePath,
+			tokenLabel: k.TokenLabel,
+			slotID:     k.SlotID,
+			keyLabel:   k.KeyLabel,
+		}
+	}
+	if o := p.OIDC; o != nil {
+		cfg.oidc = &oidcConfig{
+			issuerURL: o.IssuerURL,
+			clientID:  o.ClientID,
+			scopes:    o.Scopes,
+		}
+	}
+	if k := p.K8sSA; k != nil {
+		cfg.k8sSA = &k8sSAConfig{
+			namespace:           k.ServiceAccountNamespace,
+			name:                k.ServiceAccountName,
+			useRequestNamespace: k.UseRequestNamespace,
+		}
+	}
+	if a := p.ACME; a != nil {
+		cfg.acme = &acmeConfig{email: a.Email}
+		if a.ExternalAccountBinding != nil {
+			cfg.acme.eabKeyID = a.ExternalAccountBinding.KeyID
+		}
 	}
+	if t := p.TokenService; t != nil {
+		cfg.tokenService = &tokenServiceConfig{url: t.URL, caBundle: t.CABundle}
+	}
+	if e := spec.Embedded; e != nil {
+		cfg.embedded = newEmbeddedConfig(e.Options, e.Claims)
+	}
+	return newStep(ctx, cfg)
+}
 
-	return p, nil
+// stepSpec holds the fields of the spec of a StepIssuer or a StepClusterIssuer
+// that are common to both kinds. The sections with the same fields in both
+// kinds use the StepIssuer types.
+type stepSpec struct {
+	url             string
+	caBundle        []byte
+	caSource        api.CASource
+	provisioner     string
+	kid             string
+	keyRef          bool
+	x5c             bool
+	templateData    *api.StepTemplateData
+	renewal         *api.StepRenewal
+	validity        *api.StepValidity
+	verification    *api.StepVerification
+	subjectStrategy *api.StepSubjectStrategy
+	subjectAltNames *api.StepSubjectAltNames
+}
+
+// newConfig returns the configuration of a Step provisioner with the common
+// fields of the spec of an issuer. The sections of the provisioner, whose
+// types have the Secret references of each kind, are set by the callers.
+func newConfig(name string, spec stepSpec, creds Credentials) (*config, error) {
+	cfg := &config{
+		name:        name,
+		url:         spec.url,
+		caBundle:    spec.caBundle,
+		caSource:    spec.caSource,
+		provisioner: spec.provisioner,
+		kid:         spec.kid,
+		keyRef:      spec.keyRef,
+		x5c:         spec.x5c,
+		creds:       creds,
+	}
+	if d := spec.templateData; d != nil {
+		cfg.templateData = &templateDataConfig{
+			requestMetadata: d.RequestMetadata,
+			annotations:     d.Annotations,
+		}
+	}
+	if r := spec.renewal; r != nil {
+		cfg.renewal = &renewalConfig{rekey: r.Rekey}
+	}
+	if v := spec.validity; v != nil && v.Backdate != nil {
+		cfg.backdate = v.Backdate.Duration
+	}
+	if v := spec.verification; v != nil {
+		cfg.exactSANs = v.SubjectAltNames == api.SANMatchExact
+	}
+	if n := spec.subjectAltNames; n != nil {
+		cfg.sanMode = n.Mode
+	}
+	if st := spec.subjectStrategy; st != nil {
+		subject, err := newSubjectConfig(st.Type, st.Template, st.RejectEmpty)
+		if err != nil {
+			return nil, err
+		}
+		cfg.subject = subject
+	}
+	return cfg, nil
 }
 
-func NewFromStepClusterIssuer(iss *api.StepClusterIssuer, password []byte) (*Step, error) {
+func newStep(ctx context.Context, cfg *config) (*Step, error) {
+	if cfg.caSource == "" {
+		cfg.caSource = api.CASourceRoot
+	}
+	if cfg.embedded != nil {
+		return newEmbeddedStep(cfg)
+	}
 	options := []ca.ClientOption{
-		ca.WithCABundle(iss.Spec.CABundle),
+		ca.WithCABundle(cfg.caBundle),
+	}
+
+	// JWK provisioners load the encrypted provisioner key from the CA, unless
+	// the key is given in a Secret or a PKCS#11 token.
+	if !cfg.keyRef && cfg.pkcs11 == nil && cfg.oidc == nil && !cfg.x5c && cfg.k8sSA == nil && cfg.acme == nil && cfg.tokenService == nil {
+		provisioner, err := ca.NewProvisioner(cfg.provisioner, cfg.kid, cfg.url, cfg.creds.Password, options...)
+		if err != nil {
+			return nil, err
+		}
+		return &Step{
+			name:        cfg.name,
+			provisioner: cfg.provisioner,
+			caBundle:    cfg.caBundle,
+			caSource:    cfg.caSource,
+			client:      provisioner.Client,
+			tokens:      &jwkTokenSource{provisioner: provisioner},
+			credentials: credentialsDigest(cfg.creds),
+
+			templateDataConfig: cfg.templateData,
+			subjectConfig:      cfg.subject,
+			renewal:            cfg.renewal,
+			backdate:           cfg.backdate,
+			exactSANs:          cfg.exactSANs,
+			sanMode:            cfg.sanMode,
+		}, nil
 	}
 
-	provisioner, err := ca.NewProvisioner(iss.Spec.Provisioner.Name, iss.Spec.Provisioner.KeyID, iss.Spec.URL, password, options...)
+	client, err := ca.NewClient(cfg.url, options...)
 	if err != nil {
 		return nil, err
 	}
 
 	p := &Step{
-		name:        iss.Name + "." + iss.Namespace,
-		caBundle:    iss.Spec.CABundle,
-		provisioner: provisioner,
+		name:        cfg.name,
+		provisioner: cfg.provisioner,
+		caBundle:    cfg.caBundle,
+		caSource:    cfg.caSource,
+		client:      client,
+		credentials: credentialsDigest(cfg.creds),
+
+		templateDataConfig: cfg.templateData,
+		subjectConfig:      cfg.subject,
+		renewal:            cfg.renewal,
+		backdate:           cfg.backdate,
+		exactSANs:          cfg.exactSANs,
+		sanMode:            cfg.sanMode,
+	}
+	switch {
+	case cfg.keyRef:
+		p.tokens, err = newJWKKeyTokenSource(client, cfg.provisioner, cfg.kid, cfg.caBundle, cfg.creds.JWKKey, cfg.creds.Password)
+	case cfg.pkcs11 != nil:
+		p.tokens, err = newPKCS11TokenSource(client, cfg.provisioner, cfg.kid, cfg.caBundle, cfg.pkcs11, cfg.creds.PKCS11PIN)
+	case cfg.oidc != nil:
+		p.tokens, err = newOIDCTokenSource(cfg.oidc, cfg.creds.ClientSecret)
+	case cfg.x5c:
+		p.tokens, err = newX5CTokenSource(client, cfg.provisioner, cfg.creds.X5CCertificate, cfg.creds.X5CKey)
+	case cfg.k8sSA != nil:
+		p.tokens, err = newK8sSATokenSource(cfg.k8sSA, cfg.creds.ServiceAccountToken)
+	case cfg.tokenService != nil:
+		p.tokens, err = newTokenServiceSource(client, cfg.provisioner, cfg.tokenService, cfg.creds.TokenServiceBearerToken,
+			cfg.creds.TokenServiceClientCertificate, cfg.creds.TokenServiceClientKey)
+	case cfg.acme != nil:
+		p.acme, err = newACMESigner(ctx, cfg.url, cfg.provisioner, cfg.caBundle, cfg.acme, cfg.creds.ACMEAccountKey, cfg.creds.ACMEEABKey)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	return p, nil
 }
 
-// Load returns a Step provisioner by NamespacedName.
-func Load(namespacedName types.NamespacedName) (*Step, bool) {
-	v, ok := collection.Load(namespacedName)
-	if !ok {
-		return nil, ok
+// Sign sends the certificate requests to the Step CA and returns the signed
+// certificate and the CA certificates selected by the caSource of the issuer.
+func (s *Step) Sign(ctx context.Context, cr *certmanager.CertificateRequest, opts ...SignOption) ([]byte, []byte, error) {
+	o := new(signOptions)
+	for _, fn := range opts {
+		fn(o)
 	}
-	p, ok := v.(*Step)
-	return p, ok
-}
 
-// Store adds a new provisioner to the collection by NamespacedName.
-func Store(namespacedName types.NamespacedName, provisioner *Step) {
-	collection.Store(namespacedName, provisioner)
-}
-
-// Sign sends the certificate requests to the Step CA and returns the signed
-// certificate.
-func (s *Step) Sign(_ context.Context, cr *certmanager.CertificateRequest) ([]byte, []byte, error) {
 	// decode and check certificate request
 	csr, err := decodeCSR(cr.Spec.Request)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	sans := append([]string{}, csr.DNSNames...)
-	sans = append(sans, csr.EmailAddresses...)
-	for _, ip := range csr.IPAddresses {
-		sans = append(sans, ip.String())
+	data, err := s.templateData(cr)
+	if err != nil {
+		return nil, nil, err
 	}
-	for _, u := range csr.URIs {
-		sans = append(sans, u.String())
+
+	sans, err := s.requestSANs(csr)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	subject := csr.Subject.CommonName
-	if subject == "" {
-		subject = generateSubject(sans)
+	// load the roots before signing, so a CA without them does not issue a
+	// certificate that is never used
+	roots, err := s.caRoots(ctx)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	token, err := s.provisioner.Token(subject, sans...)
+	// renew the previous certificate if possible, and fall back to the
+	// provisioner if the renewal fails
+	chain, ok, renewErr := s.renew(ctx, cr, csr, o)
+	if !ok || renewErr != nil {
+		chain, err = s.sign(ctx, cr, csr, sans.all(), data)
+		if err != nil {
+			if renewErr != nil {
+				return nil, nil, fmt.Errorf("%w; %w", renewErr, err)
+			}
+			return nil, nil, err
+		}
+	}
+	if err := s.verifyCertificate(chain, roots, csr, sans, time.Now()); err != nil {
+		return nil, nil, err
+	}
+	if err := verifyUsages(chain[0], cr); err != nil {
+		return nil, nil, err
+	}
+	caPem, err := s.caCertificates(roots, chain)
 	if err != nil {
 		return nil, nil, err
 	}
+	return encodeX509(chain...), caPem, nil
+}
+
+// Health checks that the CA is available.
+func (s *Step) Health(ctx context.Context) error {
+	if _, err := s.client.HealthWithContext(ctx); err != nil {
+		return fmt.Errorf("error checking CA health: %w", err)
+	}
+	return nil
+}
+
+// Roots returns the root certificates of the CA.
+func (s *Step) Roots(ctx context.Context) ([]*x509.Certificate, error) {
+	return s.loadRoots(ctx, false)
+}
 
-	var notAfter capi.TimeDuration
+// Close stops the embedded authority, if any, and releases the PKCS#11 module
+// of the provisioner key. The CA client does not hold any resources that need
+// to be released.
+func (s *Step) Close() error {
+	if s.embedded != nil {
+		return s.embedded.Close()
+	}
+	if t, ok := s.tokens.(*pkcs11TokenSource); ok {
+		return t.Close()
+	}
+	return nil
+}
+
+// sign sends the certificate request to the Step CA and returns the
+// certificate chain.
+func (s *Step) sign(ctx context.Context, cr *certmanager.CertificateRequest, csr *x509.CertificateRequest, sans []string, data json.RawMessage) ([]*x509.Certificate, error) {
+	// ACME provisioners do not use one-time tokens or template data
+	if s.acme != nil {
+		var duration time.Duration
+		if cr.Spec.Duration != nil {
+			duration = cr.Spec.Duration.Duration
+		}
+		chainPem, err := s.acme.Sign(ctx, csr, duration)
+		if err != nil {
+			return nil, err
+		}
+		chain, err := pemutil.ParseCertificateBundle(chainPem)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing certificate chain: %w", err)
+		}
+		return chain, nil
+	}
+
+	subject, err := s.subject(cr, csr, sans)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.tokens.Token(ctx, &tokenRequest{
+		subject:   subject,
+		sans:      sans,
+		namespace: cr.Namespace,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the duration is relative to the start of the validity
+	var notBefore, notAfter capi.TimeDuration
+	if s.backdate > 0 {
+		notBefore.SetTime(time.Now().Add(-s.backdate))
+	}
 	if cr.Spec.Duration != nil {
 		notAfter.SetDuration(cr.Spec.Duration.Duration)
 	}
 
-	resp, err := s.provisioner.Sign(&capi.SignRequest{
+	resp, err := s.client.SignWithContext(ctx, &capi.SignRequest{
 		CsrPEM: capi.CertificateRequest{
 			CertificateRequest: csr,
 		},
-		OTT:      token,
-		NotAfter: notAfter,
+		OTT:          token,
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		TemplateData: data,
 	})
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	// Encode server certificate with the intermediate
-	chainPem, err := encodeX509(resp.CertChainPEM...)
-	if err != nil {
-		return nil, nil, err
+	chain := make([]*x509.Certificate, len(resp.CertChainPEM))
+	for i, crt := range resp.CertChainPEM {
+		chain[i] = crt.Certificate
 	}
-	return chainPem, s.caBundle, nil
+	return chain, nil
+}
+
+// DecodeCSR decodes a certificate request in PEM format and checks its
+// signature.
+func DecodeCSR(data []byte) (*x509.CertificateRequest, error) {
+	return decodeCSR(data)
 }
 
 // decodeCSR decodes a certificate request in PEM format and returns the
@@ -151,30 +613,11 @@ func decodeCSR(data []byte) (*x509.CertificateRequest, error) {
 	return csr, nil
 }
 
-// encodeX509 will encode a certificate into PEM format.
-func encodeX509(certs ...capi.Certificate) ([]byte, error) {
+// encodeX509 will encode the certificates into PEM format.
+func encodeX509(certs ...*x509.Certificate) []byte {
 	certPem := bytes.NewBuffer([]byte{})
 	for _, cert := range certs {
-		err := pem.Encode(certPem, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
-		if err != nil {
-			return nil, err
-		}
-	}
-	return certPem.Bytes(), nil
-}
-
-// generateSubject returns the first SAN that is not 127.0.0.1 or localhost. The
-// CSRs generated by the Certificate resource have always those SANs. If no SANs
-// are available `step-issuer-certificate` will be used as a subject is always
-// required.
-func generateSubject(sans []string) string {
-	if len(sans) == 0 {
-		return "step-issuer-certificate"
-	}
-	for _, s := range sans {
-		if s != "127.0.0.1" && s != "localhost" {
-			return s
-		}
+		_ = pem.Encode(certPem, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
 	}
-	return sans[0]
+	return certPem.Bytes()
 }
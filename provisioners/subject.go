@@ -0,0 +1,146 @@
+package provisioners
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	"go.step.sm/crypto/x509util"
+)
+
+// defaultSubject is the subject of the tokens for the requests without one,
+// as a subject is always required.
+const defaultSubject = "step-issuer-certificate"
+
+// ErrEmptySubject is returned when the subject strategy of an issuer does not
+// select a subject for a request, and it rejects the requests without one.
+var ErrEmptySubject = errors.New("certificate request has no subject")
+
+// subjectConfig configures the selection of the subject of the tokens.
+type subjectConfig struct {
+	strategy    api.SubjectStrategyType
+	template    *template.Template
+	rejectEmpty bool
+}
+
+// subjectTemplateData is the data available to the subject templates.
+type subjectTemplateData struct {
+	CommonName     string
+	DNSNames       []string
+	IPAddresses    []string
+	URIs           []string
+	EmailAddresses []string
+	SANs           []string
+
+	Namespace   string
+	Name        string
+	Certificate string
+	Annotations map[string]string
+}
+
+// newSubjectConfig returns the subject configuration of an issuer, parsing
+// the template of the Template strategy.
+func newSubjectConfig(strategy api.SubjectStrategyType, text string, rejectEmpty bool) (*subjectConfig, error) {
+	c := &subjectConfig{strategy: strategy, rejectEmpty: rejectEmpty}
+	if strategy == api.SubjectStrategyTemplate {
+		t, err := ParseSubjectTemplate(text)
+		if err != nil {
+			return nil, err
+		}
+		c.template = t
+	}
+	return c, nil
+}
+
+// ParseSubjectTemplate parses the template of the Template subject strategy.
+func ParseSubjectTemplate(text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("spec.subjectStrategy.template cannot be empty")
+	}
+	t, err := template.New("subject").Funcs(x509util.GetFuncMap()).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing spec.subjectStrategy.template: %w", err)
+	}
+	return t, nil
+}
+
+// subject returns the subject of the token for the request, selected by the
+// subject strategy of the issuer.
+func (s *Step) subject(cr *certmanager.CertificateRequest, csr *x509.CertificateRequest, sans []string) (string, error) {
+	c := s.subjectConfig
+	if c == nil {
+		c = &subjectConfig{}
+	}
+
+	var subject string
+	switch c.strategy {
+	case api.SubjectStrategyCommonName:
+		subject = csr.Subject.CommonName
+	case api.SubjectStrategyFirstDNSName:
+		if len(csr.DNSNames) > 0 {
+			subject = csr.DNSNames[0]
+		}
+	case api.SubjectStrategyFirstURI:
+		if len(csr.URIs) > 0 {
+			subject = csr.URIs[0].String()
+		}
+	case api.SubjectStrategyTemplate:
+		var buf bytes.Buffer
+		if err := c.template.Execute(&buf, &subjectTemplateData{
+			CommonName:     csr.Subject.CommonName,
+			DNSNames:       csr.DNSNames,
+			IPAddresses:    ipStrings(csr.IPAddresses),
+			URIs:           uriStrings(csr.URIs),
+			EmailAddresses: csr.EmailAddresses,
+			SANs:           sans,
+			Namespace:      cr.Namespace,
+			Name:           cr.Name,
+			Certificate:    certificateName(cr),
+			Annotations:    cr.Annotations,
+		}); err != nil {
+			return "", fmt.Errorf("error executing spec.subjectStrategy.template: %w", err)
+		}
+		subject = strings.TrimSpace(buf.String())
+	default:
+		subject = csr.Subject.CommonName
+		if subject == "" {
+			subject = generateSubject(sans)
+		}
+	}
+
+	if subject == "" {
+		if c.rejectEmpty {
+			return "", fmt.Errorf("%w: the %s subject strategy did not select one", ErrEmptySubject, strategyName(c.strategy))
+		}
+		return defaultSubject, nil
+	}
+	return subject, nil
+}
+
+// strategyName returns the name of a subject strategy for the error messages.
+func strategyName(strategy api.SubjectStrategyType) api.SubjectStrategyType {
+	if strategy == "" {
+		return api.SubjectStrategyDefault
+	}
+	return strategy
+}
+
+// generateSubject returns the first SAN that is not 127.0.0.1 or localhost, or
+// an empty string if there are no SANs. The CSRs generated by the Certificate
+// resource used to have always those SANs.
+func generateSubject(sans []string) string {
+	if len(sans) == 0 {
+		return ""
+	}
+	for _, s := range sans {
+		if s != "127.0.0.1" && s != "localhost" {
+			return s
+		}
+	}
+	return sans[0]
+}
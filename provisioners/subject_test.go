@@ -0,0 +1,111 @@
+package provisioners
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/url"
+	"testing"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStepSubject(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spiffeID, err := url.Parse("spiffe://cluster.local/ns/default/sa/web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newCSR := func(template *x509.CertificateRequest) *x509.CertificateRequest {
+		der, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return csr
+	}
+	full := newCSR(&x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "web"},
+		DNSNames: []string{"localhost", "web.default.svc"},
+		URIs:     []*url.URL{spiffeID},
+	})
+	empty := newCSR(&x509.CertificateRequest{})
+	noCN := newCSR(&x509.CertificateRequest{DNSNames: []string{"localhost", "web.default.svc"}})
+
+	tests := []struct {
+		name        string
+		strategy    api.SubjectStrategyType
+		template    string
+		rejectEmpty bool
+		csr         *x509.CertificateRequest
+		want        string
+		wantErr     bool
+	}{
+		{name: "default", csr: full, want: "web"},
+		{name: "default without common name", csr: noCN, want: "web.default.svc"},
+		{name: "default without names", csr: empty, want: "step-issuer-certificate"},
+		{name: "default rejected", rejectEmpty: true, csr: empty, wantErr: true},
+		{name: "common name", strategy: api.SubjectStrategyCommonName, csr: full, want: "web"},
+		{name: "common name empty", strategy: api.SubjectStrategyCommonName, csr: noCN, want: "step-issuer-certificate"},
+		{name: "common name rejected", strategy: api.SubjectStrategyCommonName, rejectEmpty: true, csr: noCN, wantErr: true},
+		{name: "first DNS name", strategy: api.SubjectStrategyFirstDNSName, csr: full, want: "localhost"},
+		{name: "first URI", strategy: api.SubjectStrategyFirstURI, csr: full, want: "spiffe://cluster.local/ns/default/sa/web"},
+		{name: "first URI rejected", strategy: api.SubjectStrategyFirstURI, rejectEmpty: true, csr: noCN, wantErr: true},
+		{name: "template", strategy: api.SubjectStrategyTemplate, csr: full,
+			template: `{{ .Namespace }}/{{ .Certificate }}/{{ index .DNSNames 1 }}`, want: "default/web-cert/web.default.svc"},
+		{name: "template empty", strategy: api.SubjectStrategyTemplate, template: `{{ .CommonName }}`, rejectEmpty: true, csr: empty, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := newSubjectConfig(tt.strategy, tt.template, tt.rejectEmpty)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := &Step{subjectConfig: cfg}
+			cr := &certmanager.CertificateRequest{ObjectMeta: metav1.ObjectMeta{
+				Name:        "web-cert-1",
+				Namespace:   "default",
+				Annotations: map[string]string{certmanager.CertificateNameKey: "web-cert"},
+			}}
+			var sans []string
+			sans = append(sans, tt.csr.DNSNames...)
+			sans = append(sans, uriStrings(tt.csr.URIs)...)
+
+			got, err := s.subject(cr, tt.csr, sans)
+			if tt.wantErr {
+				if !errors.Is(err, ErrEmptySubject) {
+					t.Fatalf("expected ErrEmptySubject, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseSubjectTemplate(t *testing.T) {
+	if _, err := ParseSubjectTemplate(`{{ first .URIs }}`); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, text := range []string{"", "{{ .CommonName", "{{ unknownFunc }}"} {
+		if _, err := ParseSubjectTemplate(text); err == nil {
+			t.Errorf("expected an error parsing %q", text)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package provisioners
+
+import (
+	"encoding/json"
+	"fmt"
+
+	apiutil "github.com/cert-manager/cert-manager/pkg/api/util"
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+)
+
+// templateDataConfig configures the CertificateRequest metadata sent as
+// template data.
+type templateDataConfig struct {
+	requestMetadata bool
+	annotations     []string
+}
+
+// templateData returns the data sent with the sign requests. step-ca makes it
+// available to the provisioner templates as .Insecure.User, for example
+// {{ .Insecure.User.isCA }}.
+func (s *Step) templateData(cr *certmanager.CertificateRequest) (json.RawMessage, error) {
+	for _, u := range cr.Spec.Usages {
+		if _, ok := apiutil.KeyUsageType(u); ok {
+			continue
+		}
+		if _, ok := apiutil.ExtKeyUsageType(u); ok {
+			continue
+		}
+		return nil, fmt.Errorf("unsupported key usage %q", u)
+	}
+
+	data := map[string]any{
+		"isCA": cr.Spec.IsCA,
+	}
+	if len(cr.Spec.Usages) > 0 {
+		data["usages"] = cr.Spec.Usages
+	}
+
+	if cfg := s.templateDataConfig; cfg != nil {
+		if cfg.requestMetadata {
+			data["namespace"] = cr.Namespace
+			data["name"] = cr.Name
+			data["username"] = cr.Spec.Username
+			data["groups"] = cr.Spec.Groups
+			if name := certificateName(cr); name != "" {
+				data["certificate"] = name
+			}
+		}
+		if len(cfg.annotations) > 0 {
+			annotations := map[string]string{}
+			for _, k := range cfg.annotations {
+				if v, ok := cr.Annotations[k]; ok {
+					annotations[k] = v
+				}
+			}
+			data["annotations"] = annotations
+		}
+	}
+
+	return json.Marshal(data)
+}
+
+// certificateName returns the name of the Certificate that owns the
+// CertificateRequest, or an empty string if it was not created for a
+// Certificate.
+func certificateName(cr *certmanager.CertificateRequest) string {
+	if name := cr.Annotations[certmanager.CertificateNameKey]; name != "" {
+		return name
+	}
+	for _, ref := range cr.OwnerReferences {
+		if ref.Kind == certmanager.CertificateKind && ref.APIVersion == certmanager.SchemeGroupVersion.String() {
+			return ref.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,87 @@
+package provisioners
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStepTemplateData(t *testing.T) {
+	cr := &certmanager.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example-1",
+			Namespace: "apps",
+			Annotations: map[string]string{
+				certmanager.CertificateNameKey: "example",
+				"example.com/team":             "payments",
+				"example.com/secret":           "do-not-send",
+			},
+		},
+		Spec: certmanager.CertificateRequestSpec{
+			Usages:   []certmanager.KeyUsage{certmanager.UsageServerAuth},
+			Username: "system:serviceaccount:cert-manager:cert-manager",
+			Groups:   []string{"system:serviceaccounts"},
+		},
+	}
+	owned := cr.DeepCopy()
+	owned.Annotations = nil
+	owned.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: "cert-manager.io/v1",
+		Kind:       "Certificate",
+		Name:       "owner",
+	}}
+
+	tests := []struct {
+		name string
+		cfg  *templateDataConfig
+		cr   *certmanager.CertificateRequest
+		want map[string]any
+	}{
+		{name: "default", cr: cr, want: map[string]any{
+			"isCA":   false,
+			"usages": []any{"server auth"},
+		}},
+		{name: "request metadata", cfg: &templateDataConfig{requestMetadata: true}, cr: cr, want: map[string]any{
+			"isCA":        false,
+			"usages":      []any{"server auth"},
+			"namespace":   "apps",
+			"name":        "example-1",
+			"certificate": "example",
+			"username":    "system:serviceaccount:cert-manager:cert-manager",
+			"groups":      []any{"system:serviceaccounts"},
+		}},
+		{name: "owner reference", cfg: &templateDataConfig{requestMetadata: true}, cr: owned, want: map[string]any{
+			"isCA":        false,
+			"usages":      []any{"server auth"},
+			"namespace":   "apps",
+			"name":        "example-1",
+			"certificate": "owner",
+			"username":    "system:serviceaccount:cert-manager:cert-manager",
+			"groups":      []any{"system:serviceaccounts"},
+		}},
+		{name: "annotations", cfg: &templateDataConfig{annotations: []string{"example.com/team", "example.com/missing"}}, cr: cr, want: map[string]any{
+			"isCA":        false,
+			"usages":      []any{"server auth"},
+			"annotations": map[string]any{"example.com/team": "payments"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Step{templateDataConfig: tt.cfg}
+			b, err := s.templateData(tt.cr)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got map[string]any
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("templateData() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package provisioners
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/smallstep/certificates/ca"
+	"github.com/smallstep/cli-utils/token"
+	"github.com/smallstep/cli-utils/token/provision"
+	"go.step.sm/crypto/randutil"
+)
+
+// tokenLifetime is the validity of the one-time tokens created by step-issuer,
+// it matches the lifetime used by the step certificates client.
+const tokenLifetime = 5 * time.Minute
+
+// signToken creates a one-time token for the given subject and SANs, signed
+// with the given key. Additional options, like the kid or x5c headers, can be
+// passed to identify the key.
+func signToken(subject string, sans []string, issuer, audience, fingerprint, alg string, key interface{}, opts ...token.Options) (string, error) {
+	if len(sans) == 0 {
+		sans = []string{subject}
+	}
+
+	// A random jwt id will be used to identify duplicated tokens
+	jwtID, err := randutil.Hex(64) // 256 bits
+	if err != nil {
+		return "", err
+	}
+
+	notBefore := time.Now()
+	tokOptions := []token.Options{
+		token.WithJWTID(jwtID),
+		token.WithIssuer(issuer),
+		token.WithAudience(audience),
+		token.WithValidity(notBefore, notBefore.Add(tokenLifetime)),
+		token.WithSANS(sans),
+	}
+	if fingerprint != "" {
+		tokOptions = append(tokOptions, token.WithSHA(fingerprint))
+	}
+	tokOptions = append(tokOptions, opts...)
+
+	tok, err := provision.New(subject, tokOptions...)
+	if err != nil {
+		return "", err
+	}
+	return tok.SignedString(alg, key)
+}
+
+// signAudience returns the audience of the tokens used to sign X.509
+// certificates with the given client.
+func signAudience(client *ca.Client) (string, error) {
+	return endpointAudience(client, "/1.0/sign")
+}
+
+// revokeAudience returns the audience of the tokens used to revoke X.509
+// certificates with the given client.
+func revokeAudience(client *ca.Client) (string, error) {
+	return endpointAudience(client, "/1.0/revoke")
+}
+
+func endpointAudience(client *ca.Client, path string) (string, error) {
+	u, err := url.Parse(client.GetCaURL())
+	if err != nil {
+		return "", fmt.Errorf("error parsing CA URL: %w", err)
+	}
+	return u.ResolveReference(&url.URL{Path: path}).String(), nil
+}
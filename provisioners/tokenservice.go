@@ -0,0 +1,135 @@
+package provisioners
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/smallstep/certificates/ca"
+)
+
+// tokenServiceConfig contains the configuration of an external token service.
+type tokenServiceConfig struct {
+	url      string
+	caBundle []byte
+}
+
+// tokenServiceRequest is the body of the requests to a token service.
+type tokenServiceRequest struct {
+	Provisioner string   `json:"provisioner"`
+	Audience    string   `json:"audience"`
+	Subject     string   `json:"subject"`
+	SANs        []string `json:"sans"`
+	Namespace   string   `json:"namespace,omitempty"`
+}
+
+// tokenServiceResponse is the body of the responses of a token service.
+type tokenServiceResponse struct {
+	Token string `json:"token"`
+}
+
+// tokenServiceSource gets the one-time tokens from an external service that
+// holds the provisioner keys.
+type tokenServiceSource struct {
+	client      *http.Client
+	url         string
+	provisioner string
+	audience    string
+	bearerToken string
+}
+
+// newTokenServiceSource returns a token source for the given service. The
+// service is authenticated with a bearer token, a client certificate, or
+// both.
+func newTokenServiceSource(client *ca.Client, provisioner string, cfg *tokenServiceConfig, bearerToken, certPEM, keyPEM []byte) (*tokenServiceSource, error) {
+	u, err := url.Parse(cfg.url)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing token service URL: %w", err)
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("token service URL %s must use https", cfg.url)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if len(cfg.caBundle) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(cfg.caBundle) {
+			return nil, fmt.Errorf("error parsing token service caBundle")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(certPEM) > 0 {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing token service client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	audience, err := signAudience(client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tokenServiceSource{
+		client:      &http.Client{Transport: transport, Timeout: 30 * time.Second},
+		url:         cfg.url,
+		provisioner: provisioner,
+		audience:    audience,
+		bearerToken: strings.TrimSpace(string(bearerToken)),
+	}, nil
+}
+
+// Token requests a token for the subject and SANs to the token service.
+func (s *tokenServiceSource) Token(ctx context.Context, req *tokenRequest) (string, error) {
+	body, err := json.Marshal(&tokenServiceRequest{
+		Provisioner: s.provisioner,
+		Audience:    s.audience,
+		Subject:     req.subject,
+		SANs:        req.sans,
+		Namespace:   req.namespace,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	if s.bearerToken != "" {
+		r.Header.Set("Authorization", "Bearer "+s.bearerToken)
+	}
+
+	resp, err := s.client.Do(r)
+	if err != nil {
+		return "", fmt.Errorf("error requesting token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("token service returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var tr tokenServiceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return "", fmt.Errorf("error decoding token service response: %w", err)
+	}
+	if tr.Token == "" {
+		return "", fmt.Errorf("token service response does not contain a token")
+	}
+	return tr.Token, nil
+}
@@ -0,0 +1,151 @@
+package provisioners
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.step.sm/crypto/minica"
+	"go.step.sm/crypto/pemutil"
+)
+
+// newTokenService starts a token service that accepts the bearer token
+// "s3cr3t" or a client certificate signed by the returned CA.
+func newTokenService(t *testing.T) (*httptest.Server, *minica.CA) {
+	t.Helper()
+	mca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(mca.Root)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cr3t" && len(r.TLS.VerifiedChains) == 0 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var req tokenServiceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Subject == "denied.example.com" {
+			http.Error(w, "subject not allowed", http.StatusForbidden)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(tokenServiceResponse{
+			Token: req.Provisioner + "|" + req.Audience + "|" + req.Subject + "|" + req.Namespace,
+		})
+	}))
+	srv.TLS = &tls.Config{
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  pool,
+		MinVersion: tls.VersionTLS12,
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv, mca
+}
+
+// newTestClientCertificate returns a PEM encoded client certificate and key
+// signed by the given CA.
+func newTestClientCertificate(t *testing.T, mca *minica.CA) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := mca.Sign(&x509.Certificate{
+		Subject:     mca.Root.Subject,
+		PublicKey:   key.Public(),
+		NotBefore:   time.Now(),
+		NotAfter:    time.Now().Add(time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := pemutil.Serialize(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})
+	certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mca.Intermediate.Raw})...)
+	return certPEM, pem.EncodeToMemory(block)
+}
+
+func TestTokenServiceSource(t *testing.T) {
+	srv, mca := newTokenService(t)
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	certPEM, keyPEM := newTestClientCertificate(t, mca)
+	client := newTestClient(t)
+	audience, err := signAudience(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		url         string
+		bearerToken string
+		certPEM     []byte
+		keyPEM      []byte
+		subject     string
+		want        string
+		wantNewErr  bool
+		wantErr     bool
+	}{
+		{name: "bearer token", url: srv.URL, bearerToken: "s3cr3t\n", subject: "example.com",
+			want: "admin|" + audience + "|example.com|default"},
+		{name: "client certificate", url: srv.URL, certPEM: certPEM, keyPEM: keyPEM, subject: "example.com",
+			want: "admin|" + audience + "|example.com|default"},
+		{name: "wrong bearer token", url: srv.URL, bearerToken: "wrong", subject: "example.com", wantErr: true},
+		{name: "denied", url: srv.URL, bearerToken: "s3cr3t", subject: "denied.example.com", wantErr: true},
+		{name: "http", url: "http://" + srv.Listener.Addr().String(), bearerToken: "s3cr3t", wantNewErr: true},
+		{name: "bad client certificate", url: srv.URL, certPEM: certPEM, keyPEM: []byte("bad"), wantNewErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newTokenServiceSource(client, "admin", &tokenServiceConfig{url: tt.url, caBundle: caBundle},
+				[]byte(tt.bearerToken), tt.certPEM, tt.keyPEM)
+			if tt.wantNewErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, err := s.Token(context.Background(), &tokenRequest{
+				subject:   tt.subject,
+				sans:      []string{tt.subject},
+				namespace: "default",
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package provisioners
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"slices"
+
+	apiutil "github.com/cert-manager/cert-manager/pkg/api/util"
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+)
+
+// ErrCertificateMismatch is returned when the certificate issued by the CA
+// does not have the key usages or basic constraints in the request.
+var ErrCertificateMismatch = errors.New("issued certificate does not match the request")
+
+// verifyUsages checks that the certificate has all the requested usages, and
+// that it is a CA certificate only if it was requested as one. Certificates
+// can have usages that were not requested, as the CA templates usually add
+// them. The key encipherment usage, in the cert-manager defaults, is only
+// required for RSA keys, as the default step-ca templates do not add it for
+// other keys, which cannot use it.
+func verifyUsages(crt *x509.Certificate, cr *certmanager.CertificateRequest) error {
+	if crt.IsCA != cr.Spec.IsCA {
+		return fmt.Errorf("%w: expected isCA %t, got %t", ErrCertificateMismatch, cr.Spec.IsCA, crt.IsCA)
+	}
+	if cr.Spec.IsCA && crt.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return fmt.Errorf("%w: missing key usage %s", ErrCertificateMismatch, certmanager.UsageCertSign)
+	}
+
+	for _, u := range cr.Spec.Usages {
+		if ku, ok := apiutil.KeyUsageType(u); ok {
+			if ku == x509.KeyUsageKeyEncipherment && !isRSAKey(crt.PublicKey) {
+				continue
+			}
+			if crt.KeyUsage&ku == 0 {
+				return fmt.Errorf("%w: missing key usage %s", ErrCertificateMismatch, u)
+			}
+			continue
+		}
+		if eku, ok := apiutil.ExtKeyUsageType(u); ok {
+			if !slices.Contains(crt.ExtKeyUsage, eku) && !slices.Contains(crt.ExtKeyUsage, x509.ExtKeyUsageAny) {
+				return fmt.Errorf("%w: missing extended key usage %s", ErrCertificateMismatch, u)
+			}
+		}
+	}
+	return nil
+}
+
+func isRSAKey(pub any) bool {
+	_, ok := pub.(*rsa.PublicKey)
+	return ok
+}
+
+// keyUsages returns the key usages and extended key usages of a certificate
+// for the request. Requests without usages get the cert-manager defaults, and
+// CA certificates can always sign certificates.
+func keyUsages(cr *certmanager.CertificateRequest) (x509.KeyUsage, []x509.ExtKeyUsage, error) {
+	usages := cr.Spec.Usages
+	if len(usages) == 0 {
+		usages = certmanager.DefaultKeyUsages()
+	}
+
+	var ku x509.KeyUsage
+	var eku []x509.ExtKeyUsage
+	if cr.Spec.IsCA {
+		ku |= x509.KeyUsageCertSign
+	}
+	for _, u := range usages {
+		if k, ok := apiutil.KeyUsageType(u); ok {
+			ku |= k
+		} else if e, ok := apiutil.ExtKeyUsageType(u); ok {
+			eku = append(eku, e)
+		} else {
+			return 0, nil, fmt.Errorf("unsupported key usage %q", u)
+		}
+	}
+	return ku, eku, nil
+}
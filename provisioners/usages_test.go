@@ -0,0 +1,146 @@
+package provisioners
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"testing"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/smallstep/certificates/authority/provisioner"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// usagesTemplate issues CA certificates if requested in the template data.
+const usagesTemplate = `{
+	"subject": {{ toJson .Subject }},
+	"sans": {{ toJson .SANs }},
+{{- if .Insecure.User.isCA }}
+	"basicConstraints": {"isCA": true, "maxPathLen": 0},
+	"keyUsage": ["certSign", "crlSign"]
+{{- else }}
+	"keyUsage": ["digitalSignature"],
+	"extKeyUsage": ["serverAuth", "clientAuth"]
+{{- end }}
+}`
+
+func TestStepSignUsages(t *testing.T) {
+	jwk, key := newTestJWKProvisioner(t, "admin")
+	jwk.Options = &provisioner.Options{
+		X509: &provisioner.X509Options{Template: usagesTemplate},
+	}
+	testCA := newTestCA(t, provisioner.List{jwk})
+
+	s, err := NewFromStepIssuer(context.Background(), &api.StepIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "issuer", Namespace: "default"},
+		Spec: api.StepIssuerSpec{
+			URL:      testCA.URL,
+			CABundle: testCA.RootPEM,
+			Provisioner: api.StepProvisioner{
+				Name:   "admin",
+				KeyID:  jwk.Key.KeyID,
+				KeyRef: &api.StepIssuerSecretKeySelector{},
+			},
+		},
+	}, Credentials{JWKKey: key})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		usages       []certmanager.KeyUsage
+		isCA         bool
+		wantMismatch bool
+		wantErr      bool
+	}{
+		{name: "default usages"},
+		{name: "client auth", usages: []certmanager.KeyUsage{certmanager.UsageDigitalSignature, certmanager.UsageClientAuth}},
+		{name: "ca", usages: []certmanager.KeyUsage{certmanager.UsageCertSign}, isCA: true},
+		{name: "missing key usage", usages: []certmanager.KeyUsage{certmanager.UsageContentCommitment}, wantMismatch: true},
+		// The ECDSA certificates do not get key encipherment, and it is
+		// requested by the cert-manager default usages.
+		{name: "key encipherment with ECDSA", usages: []certmanager.KeyUsage{
+			certmanager.UsageDigitalSignature, certmanager.UsageKeyEncipherment, certmanager.UsageServerAuth,
+		}},
+		{name: "missing ext key usage", usages: []certmanager.KeyUsage{certmanager.UsageCodeSigning}, wantMismatch: true},
+		{name: "unknown usage", usages: []certmanager.KeyUsage{"foo"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chainPEM, _, err := s.Sign(context.Background(), &certmanager.CertificateRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "cr", Namespace: "default"},
+				Spec: certmanager.CertificateRequestSpec{
+					Request: newTestCSR(t, "example.com"),
+					Usages:  tt.usages,
+					IsCA:    tt.isCA,
+				},
+			})
+			switch {
+			case tt.wantMismatch:
+				if !errors.Is(err, ErrCertificateMismatch) {
+					t.Fatalf("expected ErrCertificateMismatch, got %v", err)
+				}
+			case tt.wantErr:
+				if err == nil || errors.Is(err, ErrCertificateMismatch) {
+					t.Fatalf("expected an error before signing, got %v", err)
+				}
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case len(chainPEM) == 0:
+				t.Fatal("expected a certificate chain")
+			}
+		})
+	}
+}
+
+func TestVerifyUsages(t *testing.T) {
+	leaf := &x509.Certificate{
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	ca := &x509.Certificate{
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	rsaLeaf := &x509.Certificate{
+		PublicKey:   &rsa.PublicKey{},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	anyEKU := &x509.Certificate{
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+
+	tests := []struct {
+		name    string
+		crt     *x509.Certificate
+		usages  []certmanager.KeyUsage
+		isCA    bool
+		wantErr bool
+	}{
+		{name: "leaf", crt: leaf, usages: []certmanager.KeyUsage{certmanager.UsageDigitalSignature, certmanager.UsageServerAuth}},
+		{name: "leaf missing client auth", crt: leaf, usages: []certmanager.KeyUsage{certmanager.UsageClientAuth}, wantErr: true},
+		{name: "leaf key encipherment", crt: leaf, usages: []certmanager.KeyUsage{certmanager.UsageKeyEncipherment}},
+		{name: "rsa leaf missing key encipherment", crt: rsaLeaf, usages: []certmanager.KeyUsage{certmanager.UsageKeyEncipherment}, wantErr: true},
+		{name: "leaf requested as ca", crt: leaf, isCA: true, wantErr: true},
+		{name: "ca", crt: ca, isCA: true},
+		{name: "ca requested as leaf", crt: ca, wantErr: true},
+		{name: "ca without cert sign", crt: &x509.Certificate{IsCA: true}, isCA: true, wantErr: true},
+		{name: "any ext key usage", crt: anyEKU, usages: []certmanager.KeyUsage{certmanager.UsageClientAuth}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyUsages(tt.crt, &certmanager.CertificateRequest{
+				Spec: certmanager.CertificateRequestSpec{Usages: tt.usages, IsCA: tt.isCA},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyUsages() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package provisioners
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ErrDurationRejected is returned when the duration of a CertificateRequest is
+// outside of the range allowed by its issuer.
+var ErrDurationRejected = errors.New("certificate duration rejected by the issuer")
+
+// Validity configures the duration of the certificates of an issuer.
+type Validity struct {
+	defaultDuration time.Duration
+	minDuration     time.Duration
+	maxDuration     time.Duration
+	reject          bool
+}
+
+// NewValidityFromStepIssuer returns the validity settings of a StepIssuer, or
+// nil if it does not have them.
+func NewValidityFromStepIssuer(iss *api.StepIssuer) *Validity {
+	v := iss.Spec.Validity
+	if v == nil {
+		return nil
+	}
+	return newValidity(v.DefaultDuration, v.MinDuration, v.MaxDuration, v.Mode)
+}
+
+// NewValidityFromStepClusterIssuer returns the validity settings of a
+// StepClusterIssuer, or nil if it does not have them.
+func NewValidityFromStepClusterIssuer(iss *api.StepClusterIssuer) *Validity {
+	v := iss.Spec.Validity
+	if v == nil {
+		return nil
+	}
+	return newValidity(v.DefaultDuration, v.MinDuration, v.MaxDuration, v.Mode)
+}
+
+func newValidity(defaultDuration, minDuration, maxDuration *metav1.Duration, mode api.ValidityMode) *Validity {
+	v := &Validity{reject: mode == api.ValidityModeReject}
+	if defaultDuration != nil {
+		v.defaultDuration = defaultDuration.Duration
+	}
+	if minDuration != nil {
+		v.minDuration = minDuration.Duration
+	}
+	if maxDuration != nil {
+		v.maxDuration = maxDuration.Duration
+	}
+	return v
+}
+
+// Duration returns the effective duration of the certificate for the
+// request, and a description of how it was chosen. The duration is zero if
+// the request and the issuer do not set one, and the CA default is used. In
+// reject mode, it returns an error wrapping ErrDurationRejected if the
+// requested duration is not allowed.
+func (v *Validity) Duration(cr *certmanager.CertificateRequest) (time.Duration, string, error) {
+	if cr.Spec.Duration == nil {
+		if v.defaultDuration == 0 {
+			return 0, "the default duration of the provisioner", nil
+		}
+		return v.defaultDuration, "spec.validity.defaultDuration", nil
+	}
+
+	d := cr.Spec.Duration.Duration
+	switch {
+	case v.minDuration > 0 && d < v.minDuration:
+		if v.reject {
+			return 0, "", fmt.Errorf("%w: duration %s is shorter than spec.validity.minDuration %s", ErrDurationRejected, d, v.minDuration)
+		}
+		return v.minDuration, fmt.Sprintf("the requested duration %s clamped to spec.validity.minDuration", d), nil
+	case v.maxDuration > 0 && d > v.maxDuration:
+		if v.reject {
+			return 0, "", fmt.Errorf("%w: duration %s is longer than spec.validity.maxDuration %s", ErrDurationRejected, d, v.maxDuration)
+		}
+		return v.maxDuration, fmt.Sprintf("the requested duration %s clamped to spec.validity.maxDuration", d), nil
+	default:
+		return d, "the requested duration", nil
+	}
+}
@@ -0,0 +1,111 @@
+package provisioners
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/smallstep/certificates/authority/provisioner"
+	api "github.com/smallstep/step-issuer/api/v1beta1"
+	"go.step.sm/crypto/pemutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidityDuration(t *testing.T) {
+	validity := func(mode api.ValidityMode, defaultDuration time.Duration) *Validity {
+		v := &api.StepValidity{
+			MinDuration: &metav1.Duration{Duration: time.Hour},
+			MaxDuration: &metav1.Duration{Duration: 24 * time.Hour},
+			Mode:        mode,
+		}
+		if defaultDuration > 0 {
+			v.DefaultDuration = &metav1.Duration{Duration: defaultDuration}
+		}
+		return NewValidityFromStepIssuer(&api.StepIssuer{Spec: api.StepIssuerSpec{Validity: v}})
+	}
+
+	tests := []struct {
+		name      string
+		validity  *Validity
+		requested time.Duration
+		want      time.Duration
+		wantErr   bool
+	}{
+		{name: "default", validity: validity(api.ValidityModeClamp, 8*time.Hour), want: 8 * time.Hour},
+		{name: "provisioner default", validity: validity(api.ValidityModeClamp, 0), want: 0},
+		{name: "requested", validity: validity(api.ValidityModeClamp, 8*time.Hour), requested: 2 * time.Hour, want: 2 * time.Hour},
+		{name: "clamp to min", validity: validity(api.ValidityModeClamp, 0), requested: time.Minute, want: time.Hour},
+		{name: "clamp to max", validity: validity("", 0), requested: 48 * time.Hour, want: 24 * time.Hour},
+		{name: "reject shorter", validity: validity(api.ValidityModeReject, 0), requested: time.Minute, wantErr: true},
+		{name: "reject longer", validity: validity(api.ValidityModeReject, 0), requested: 48 * time.Hour, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr := &certmanager.CertificateRequest{}
+			if tt.requested > 0 {
+				cr.Spec.Duration = &metav1.Duration{Duration: tt.requested}
+			}
+			got, _, err := tt.validity.Duration(cr)
+			if tt.wantErr {
+				if !errors.Is(err, ErrDurationRejected) {
+					t.Fatalf("expected ErrDurationRejected, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	if v := NewValidityFromStepClusterIssuer(&api.StepClusterIssuer{}); v != nil {
+		t.Errorf("expected no validity settings, got %v", v)
+	}
+}
+
+func TestStepSignBackdate(t *testing.T) {
+	jwk, key := newTestJWKProvisioner(t, "admin")
+	testCA := newTestCA(t, provisioner.List{jwk})
+
+	s, err := NewFromStepIssuer(context.Background(), &api.StepIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "issuer", Namespace: "default"},
+		Spec: api.StepIssuerSpec{
+			URL:      testCA.URL,
+			CABundle: testCA.RootPEM,
+			Provisioner: api.StepProvisioner{
+				Name:   "admin",
+				KeyID:  jwk.Key.KeyID,
+				KeyRef: &api.StepIssuerSecretKeySelector{},
+			},
+			Validity: &api.StepValidity{Backdate: &metav1.Duration{Duration: 10 * time.Minute}},
+		},
+	}, Credentials{JWKKey: key})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	chainPEM, _, err := s.Sign(context.Background(), &certmanager.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "cr", Namespace: "default"},
+		Spec: certmanager.CertificateRequestSpec{
+			Request:  newTestCSR(t, "example.com"),
+			Duration: &metav1.Duration{Duration: time.Hour},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	chain, err := pemutil.ParseCertificateBundle(chainPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(chain[0].NotBefore); d > -9*time.Minute || d < -11*time.Minute {
+		t.Errorf("expected the certificate to be valid from 10 minutes ago, got %s", chain[0].NotBefore)
+	}
+	if got := chain[0].NotAfter.Sub(chain[0].NotBefore); got != time.Hour {
+		t.Errorf("expected a duration of 1h, got %s", got)
+	}
+}
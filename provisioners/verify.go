@@ -0,0 +1,117 @@
+package provisioners
+
+import (
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"go.step.sm/crypto/pemutil"
+)
+
+var (
+	// ErrPublicKeyMismatch is returned when the certificate issued by the CA
+	// does not have the public key of the request.
+	ErrPublicKeyMismatch = errors.New("issued certificate public key does not match the request")
+
+	// ErrSANMismatch is returned when the certificate issued by the CA does
+	// not have the SANs of the request.
+	ErrSANMismatch = errors.New("issued certificate SANs do not match the request")
+
+	// ErrUntrustedChain is returned when the certificate issued by the CA does
+	// not chain to the roots of the issuer.
+	ErrUntrustedChain = errors.New("issued certificate does not chain to the issuer roots")
+
+	// ErrCertificateExpired is returned when the certificate issued by the CA,
+	// or one of the certificates in its chain, is already expired.
+	ErrCertificateExpired = errors.New("issued certificate is expired")
+)
+
+// verifyCertificate checks the certificate chain issued by the CA for the
+// request, with the SANs sent in the token. The chain must verify to the
+// certificates in the caBundle of the issuer, or to the roots read from the
+// CA.
+func (s *Step) verifyCertificate(chain, roots []*x509.Certificate, csr *x509.CertificateRequest, sans *requestSANs, now time.Time) error {
+	if len(chain) == 0 {
+		return fmt.Errorf("CA did not return any certificate")
+	}
+	leaf := chain[0]
+
+	if pub, ok := leaf.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !pub.Equal(csr.PublicKey) {
+		return ErrPublicKeyMismatch
+	}
+
+	requested := subjectAltNames(sans.dnsNames, sans.ipAddresses, sans.uris, sans.emailAddresses)
+	issued := subjectAltNames(leaf.DNSNames, ipStrings(leaf.IPAddresses), uriStrings(leaf.URIs), leaf.EmailAddresses)
+	if s.exactSANs {
+		if !sameStrings(requested, issued) {
+			return fmt.Errorf("%w: expected exactly %v, got %v", ErrSANMismatch, requested, issued)
+		}
+	} else {
+		for _, san := range requested {
+			if !slices.Contains(issued, san) {
+				return fmt.Errorf("%w: missing %s", ErrSANMismatch, san)
+			}
+		}
+	}
+
+	for _, crt := range chain {
+		if now.After(crt.NotAfter) {
+			return fmt.Errorf("%w: certificate %q expired at %s", ErrCertificateExpired, crt.Subject, crt.NotAfter.UTC().Format(time.RFC3339))
+		}
+	}
+
+	trusted := slices.Clone(roots)
+	if len(s.caBundle) > 0 {
+		certs, err := pemutil.ParseCertificateBundle(s.caBundle)
+		if err != nil {
+			return fmt.Errorf("%w: error parsing caBundle: %w", ErrUntrustedChain, err)
+		}
+		trusted = append(trusted, certs...)
+	}
+	if _, err := issuingRoot(trusted, chain); err != nil {
+		return fmt.Errorf("%w: %w", ErrUntrustedChain, err)
+	}
+	return nil
+}
+
+// subjectAltNames returns the SANs with a prefix with their type, so SANs of
+// different types with the same value are not confused. DNS names are
+// compared ignoring case.
+func subjectAltNames(dnsNames, ips, uris, emails []string) []string {
+	var sans []string
+	for _, name := range dnsNames {
+		sans = append(sans, "dns:"+strings.ToLower(name))
+	}
+	for _, ip := range ips {
+		sans = append(sans, "ip:"+ip)
+	}
+	for _, uri := range uris {
+		sans = append(sans, "uri:"+uri)
+	}
+	for _, email := range emails {
+		sans = append(sans, "email:"+email)
+	}
+	return sans
+}
+
+func ipStrings(addresses []net.IP) []string {
+	ips := make([]string, len(addresses))
+	for i, ip := range addresses {
+		ips[i] = ip.String()
+	}
+	return ips
+}
+
+func uriStrings(urls []*url.URL) []string {
+	uris := make([]string, len(urls))
+	for i, u := range urls {
+		uris[i] = u.String()
+	}
+	return uris
+}
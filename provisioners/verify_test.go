@@ -0,0 +1,85 @@
+package provisioners
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"go.step.sm/crypto/minica"
+)
+
+func TestVerifyCertificate(t *testing.T) {
+	mca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := DecodeCSR(newTestCSRWithKey(t, key, "example.com", "www.example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	sign := func(ca *minica.CA, template *x509.Certificate) []*x509.Certificate {
+		if template.PublicKey == nil {
+			template.PublicKey = key.Public()
+		}
+		crt, err := ca.Sign(template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []*x509.Certificate{crt, ca.Intermediate}
+	}
+
+	tests := []struct {
+		name      string
+		chain     []*x509.Certificate
+		exactSANs bool
+		wantErr   error
+	}{
+		{name: "ok", chain: sign(mca, &x509.Certificate{DNSNames: []string{"www.example.com", "Example.com"}})},
+		{name: "extra SANs", chain: sign(mca, &x509.Certificate{
+			DNSNames: []string{"example.com", "www.example.com"}, IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+		})},
+		{name: "exact SANs", chain: sign(mca, &x509.Certificate{DNSNames: []string{"example.com", "www.example.com"}}), exactSANs: true},
+		{name: "extra SANs with exact", chain: sign(mca, &x509.Certificate{
+			DNSNames: []string{"example.com", "www.example.com"}, IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+		}), exactSANs: true, wantErr: ErrSANMismatch},
+		{name: "missing SAN", chain: sign(mca, &x509.Certificate{DNSNames: []string{"example.com"}}), wantErr: ErrSANMismatch},
+		{name: "other key", chain: sign(mca, &x509.Certificate{
+			DNSNames: []string{"example.com", "www.example.com"}, PublicKey: otherKey.Public(),
+		}), wantErr: ErrPublicKeyMismatch},
+		{name: "other CA", chain: sign(other, &x509.Certificate{DNSNames: []string{"example.com", "www.example.com"}}), wantErr: ErrUntrustedChain},
+		{name: "expired", chain: sign(mca, &x509.Certificate{
+			DNSNames: []string{"example.com", "www.example.com"}, NotBefore: now.Add(-2 * time.Hour), NotAfter: now.Add(-time.Hour),
+		}), wantErr: ErrCertificateExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Step{caBundle: encodeX509(mca.Root), exactSANs: tt.exactSANs}
+			err := s.verifyCertificate(tt.chain, nil, csr, newRequestSANs(csr), now)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
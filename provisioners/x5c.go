@@ -0,0 +1,75 @@
+package provisioners
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/smallstep/certificates/ca"
+	"github.com/smallstep/cli-utils/token"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/pemutil"
+)
+
+// x5cTokenSource creates tokens for an X5C provisioner. The tokens are signed
+// with the private key of a certificate and carry its chain in the x5c header.
+type x5cTokenSource struct {
+	name           string
+	audience       string
+	revokeAudience string
+	fingerprint    string
+	chain          []string
+	key            *jose.JSONWebKey
+}
+
+// newX5CTokenSource returns a token source for the given PEM encoded
+// certificate chain and private key.
+func newX5CTokenSource(client *ca.Client, name string, chainPEM, keyPEM []byte) (*x5cTokenSource, error) {
+	certs, err := pemutil.ParseCertificateBundle(chainPEM)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing x5c certificate chain: %w", err)
+	}
+	if time.Now().After(certs[0].NotAfter) {
+		return nil, fmt.Errorf("x5c certificate expired on %s", certs[0].NotAfter.Format(time.RFC3339))
+	}
+	key, err := jose.ParseKey(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing x5c private key: %w", err)
+	}
+	chain, err := jose.ValidateX5C(certs, key.Key)
+	if err != nil {
+		return nil, fmt.Errorf("error validating x5c certificate chain and key: %w", err)
+	}
+
+	audience, err := signAudience(client)
+	if err != nil {
+		return nil, err
+	}
+	revokeAudience, err := revokeAudience(client)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := client.RootFingerprint()
+	if err != nil {
+		return nil, err
+	}
+
+	return &x5cTokenSource{
+		name:           name,
+		audience:       audience,
+		revokeAudience: revokeAudience,
+		fingerprint:    fingerprint,
+		chain:          chain,
+		key:            key,
+	}, nil
+}
+
+func (s *x5cTokenSource) Token(_ context.Context, req *tokenRequest) (string, error) {
+	return signToken(req.subject, req.sans, s.name, s.audience, s.fingerprint, s.key.Algorithm, s.key.Key,
+		token.WithX5CCerts(s.chain))
+}
+
+func (s *x5cTokenSource) RevokeToken(_ context.Context, serial string) (string, error) {
+	return signToken(serial, nil, s.name, s.revokeAudience, s.fingerprint, s.key.Algorithm, s.key.Key,
+		token.WithX5CCerts(s.chain))
+}
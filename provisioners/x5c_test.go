@@ -0,0 +1,131 @@
+package provisioners
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/smallstep/certificates/ca"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/minica"
+	"go.step.sm/crypto/pemutil"
+)
+
+// newTestClient returns a step certificates client connected to a TLS server
+// that only answers the health endpoint.
+func newTestClient(t *testing.T) *ca.Client {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := ca.NewClient(srv.URL, ca.WithTransport(srv.Client().Transport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// newTestX5C returns a PEM encoded certificate chain and private key signed by
+// a new test CA, and the root of the CA.
+func newTestX5C(t *testing.T, notAfter time.Time) (chainPEM, keyPEM []byte, root *x509.Certificate) {
+	t.Helper()
+	mca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := mca.Sign(&x509.Certificate{
+		DNSNames:  []string{"step-issuer"},
+		KeyUsage:  x509.KeyUsageDigitalSignature,
+		PublicKey: key.Public(),
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter:  notAfter,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, crt := range []*x509.Certificate{leaf, mca.Intermediate} {
+		chainPEM = append(chainPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})...)
+	}
+	block, err := pemutil.Serialize(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chainPEM, pem.EncodeToMemory(block), mca.Root
+}
+
+func TestX5CTokenSource(t *testing.T) {
+	client := newTestClient(t)
+	chainPEM, keyPEM, root := newTestX5C(t, time.Now().Add(time.Hour))
+
+	s, err := newX5CTokenSource(client, "x5c-provisioner", chainPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tok, err := s.Token(context.Background(), &tokenRequest{
+		subject: "foo.example.com",
+		sans:    []string{"foo.example.com", "10.0.0.1"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	jwt, err := jose.ParseSigned(tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	chains, err := jwt.Headers[0].Certificates(x509.VerifyOptions{Roots: roots})
+	if err != nil {
+		t.Fatalf("failed to verify x5c header: %v", err)
+	}
+	leaf := chains[0][0]
+	var claims struct {
+		jose.Claims
+		SANs []string `json:"sans"`
+	}
+	if err := jwt.Claims(leaf.PublicKey, &claims); err != nil {
+		t.Fatalf("failed to verify token: %v", err)
+	}
+	if claims.Issuer != "x5c-provisioner" || claims.Subject != "foo.example.com" {
+		t.Fatalf("unexpected issuer %q or subject %q", claims.Issuer, claims.Subject)
+	}
+	if want := client.GetCaURL() + "/1.0/sign"; len(claims.Audience) != 1 || claims.Audience[0] != want {
+		t.Fatalf("expected audience %s, got %v", want, claims.Audience)
+	}
+	if len(claims.SANs) != 2 {
+		t.Fatalf("unexpected sans %v", claims.SANs)
+	}
+}
+
+func TestX5CTokenSourceExpired(t *testing.T) {
+	client := newTestClient(t)
+	chainPEM, keyPEM, _ := newTestX5C(t, time.Now().Add(-time.Minute))
+
+	if _, err := newX5CTokenSource(client, "x5c-provisioner", chainPEM, keyPEM); err == nil {
+		t.Fatal("expected an error with an expired certificate")
+	}
+}
+
+func TestX5CTokenSourceKeyMismatch(t *testing.T) {
+	client := newTestClient(t)
+	chainPEM, _, _ := newTestX5C(t, time.Now().Add(time.Hour))
+	_, otherKeyPEM, _ := newTestX5C(t, time.Now().Add(time.Hour))
+
+	if _, err := newX5CTokenSource(client, "x5c-provisioner", chainPEM, otherKeyPEM); err == nil {
+		t.Fatal("expected an error when the key does not match the certificate")
+	}
+}